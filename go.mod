@@ -3,6 +3,7 @@ module github.com/GoogleCloudPlatform/marketplace-tools
 go 1.23
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/GoogleContainerTools/kpt v0.33.0
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/hashicorp/hcl/v2 v2.16.1
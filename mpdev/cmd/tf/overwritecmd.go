@@ -15,8 +15,10 @@
 package tf
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/marketplace-tools/mpdev/internal/docs"
 	"github.com/GoogleCloudPlatform/marketplace-tools/mpdev/internal/tf"
@@ -25,43 +27,94 @@ import (
 
 // GetOverwriteCommand returns `overwrite` command used to create mpdev resources.
 func GetOverwriteCommand() *cobra.Command {
+	var c overwriteCommand
 	cmd := &cobra.Command{
-		Use:     "overwrite",
+		Use:     "overwrite [--config FILENAME] [--format FORMAT] [--dir DIR] [--dry-run] [--check]",
 		Short:   docs.OverwriteShort,
 		Long:    docs.OverwriteLong,
 		Example: docs.OverwriteExamples,
-		RunE:    overwriteRunE,
+		RunE:    c.overwriteRunE,
 	}
 	cmd.SilenceUsage = true
 
+	cmd.Flags().StringVar(&c.ConfigFile, "config", c.ConfigFile, "file that contains the overwrite config. If not set or -, the config is read from stdin")
+	cmd.Flags().StringVar(&c.Format, "format", c.Format, "format of the overwrite config: json, yaml or toml. If not set, a --config file ending in .toml is read as TOML, and JSON or YAML otherwise")
+	cmd.Flags().StringVar(&c.Dir, "dir", c.Dir, "directory of the Terraform module. Defaults to the current directory")
+	cmd.Flags().BoolVar(&c.DryRun, "dry-run", c.DryRun, "if set, prints the files that would change and a diff of the changes without writing them")
+	cmd.Flags().BoolVar(&c.Check, "check", c.Check, "if set, fails without writing files if any file would change")
+
 	return cmd
 }
 
-func overwriteRunE(_ *cobra.Command, _ []string) (err error) {
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
+type overwriteCommand struct {
+	ConfigFile string
+	Format     string
+	Dir        string
+	DryRun     bool
+	Check      bool
+}
+
+func (c *overwriteCommand) overwriteRunE(cmd *cobra.Command, _ []string) (err error) {
+	dir := c.Dir
+	if dir == "" {
+		dir, err = os.Getwd()
+		if err != nil {
+			return err
+		}
 	}
 
-	bytes, err := io.ReadAll(os.Stdin)
+	var bytes []byte
+	if c.ConfigFile != "" && c.ConfigFile != "-" {
+		bytes, err = os.ReadFile(c.ConfigFile)
+	} else {
+		bytes, err = io.ReadAll(cmd.InOrStdin())
+	}
 	if err != nil {
 		return err
 	}
 
-	config, err := tf.GetOverwriteConfig(bytes)
-	if err != nil {
-		return err
+	format := c.Format
+	if format == "" && strings.HasSuffix(c.ConfigFile, ".toml") {
+		format = "toml"
 	}
 
-	err = tf.OverwriteTf(config, dir)
+	getConfig := tf.GetOverwriteConfig
+	switch format {
+	case "":
+	case "json":
+		getConfig = tf.GetOverwriteConfigJSON
+	case "yaml":
+		getConfig = tf.GetOverwriteConfigYAML
+	case "toml":
+		getConfig = tf.GetOverwriteConfigTOML
+	default:
+		return fmt.Errorf("unsupported config format: %s. Must be json, yaml or toml", format)
+	}
+	config, err := getConfig(bytes)
 	if err != nil {
 		return err
 	}
+	if c.DryRun {
+		config.DryRun = true
+	}
+	if c.Check {
+		config.CheckOnly = true
+	}
 
-	err = tf.OverwriteMetadata(config, dir)
+	result, err := tf.OverwriteAll(config, dir)
 	if err != nil {
 		return err
 	}
 
-	return tf.OverwriteDisplay(config, dir)
+	status := "Changed"
+	if config.DryRun {
+		status = "Would change"
+	}
+	for _, filename := range result.ChangedFiles() {
+		fmt.Printf("%s: %s\n", status, filename)
+	}
+	if config.DryRun {
+		fmt.Print(tf.RenderDiff(result))
+	}
+	return nil
 }
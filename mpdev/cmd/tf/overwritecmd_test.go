@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const overwriteTf = `variable "source_image" {
+  type    = string
+  default = "old-image"
+}
+`
+
+const overwrittenTf = `variable "source_image" {
+  type    = string
+  default = "new-image"
+}
+`
+
+func TestOverwriteCommand(t *testing.T) {
+	testcases := []struct {
+		name          string
+		args          []string
+		config        string
+		configFile    string
+		errorContains string
+	}{{
+		name:   "Read JSON config from stdin with --config -",
+		args:   []string{"--config", "-"},
+		config: `{"newValues": {"source_image": "new-image"}}`,
+	}, {
+		name:   "Read YAML config from stdin without --config",
+		config: "newValues:\n  source_image: new-image\n",
+	}, {
+		name:   "Read JSON config with --format json",
+		args:   []string{"--format", "json"},
+		config: `{"newValues": {"source_image": "new-image"}}`,
+	}, {
+		name:          "Fail to read YAML config with --format json",
+		args:          []string{"--format", "json"},
+		config:        "newValues:\n  source_image: new-image\n",
+		errorContains: "failure parsing overwrite config",
+	}, {
+		name:   "Read YAML config with --format yaml",
+		args:   []string{"--format", "yaml"},
+		config: "newValues:\n  source_image: new-image\n",
+	}, {
+		name:   "Read TOML config from stdin with --format toml",
+		args:   []string{"--config", "-", "--format", "toml"},
+		config: "[newValues]\nsource_image = \"new-image\"\n",
+	}, {
+		name:       "Read TOML config from a file ending in .toml",
+		config:     "[newValues]\nsource_image = \"new-image\"\n",
+		configFile: "overwrites.toml",
+	}, {
+		name:          "Fail to read TOML config with --format yaml",
+		args:          []string{"--format", "yaml"},
+		config:        "[newValues]\nsource_image = \"new-image\"\n",
+		errorContains: "failure parsing overwrite config as YAML",
+	}, {
+		name:          "Fail with unsupported format",
+		args:          []string{"--format", "ini"},
+		config:        `{"newValues": {"source_image": "new-image"}}`,
+		errorContains: "unsupported config format: ini",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			mainTf := path.Join(tmpDir, "main.tf")
+			err = os.WriteFile(mainTf, []byte(overwriteTf), 0600)
+			assert.NoError(t, err)
+
+			args := append([]string{"--dir", tmpDir}, tc.args...)
+			if tc.configFile != "" {
+				configFile := path.Join(tmpDir, tc.configFile)
+				err = os.WriteFile(configFile, []byte(tc.config), 0600)
+				assert.NoError(t, err)
+				args = append(args, "--config", configFile)
+			}
+
+			cmd := GetOverwriteCommand()
+			cmd.SetArgs(args)
+			cmd.SetIn(strings.NewReader(tc.config))
+			err = cmd.Execute()
+
+			b, readErr := os.ReadFile(mainTf)
+			assert.NoError(t, readErr)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, overwrittenTf, string(b))
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+				assert.Equal(t, overwriteTf, string(b))
+			}
+		})
+	}
+}
@@ -93,6 +93,13 @@ const OverwriteLong = `Overwrites variable values in a Terraform module
 
 Used internally by Google Marketplace to replace references to Partner owned images
 with Marketplace owned images
+
+The overwrite config is read from the --config file, or stdin if not set or set
+to -, and may be written in JSON, YAML or TOML. --format json, yaml or toml
+parses the config in only that format, where YAML includes JSON. If --format is
+not set, a --config file ending in .toml is read as TOML, and any other config
+as JSON or YAML. References to environment variables, such as ${RELEASE_IMAGE},
+in the values of newValues and replacements are expanded.
 `
 
 // OverwriteExamples contains examples for tf overwrite command
@@ -111,4 +118,19 @@ cat > /tmp/overwrites.json << EOF
 EOF
 
 cat /tmp/overwrites.json | mpdev tf overwrite
+
+# read an overwrite config generated by jq from stdin
+jq -n '{newValues: {dbImage: env.DB_IMAGE}}' | mpdev tf overwrite --config -
+
+# read an overwrite config written in TOML
+mpdev tf overwrite --config /tmp/overwrites.toml
+
+# read an overwrite config written in TOML from stdin
+cat /tmp/overwrites.toml | mpdev tf overwrite --format toml
+
+# overwrite the module in ./module, printing the files that would change
+mpdev tf overwrite --config /tmp/overwrites.json --dir ./module --dry-run
+
+# fail if the module in ./module is not already overwritten
+mpdev tf overwrite --config /tmp/overwrites.json --dir ./module --check
 `
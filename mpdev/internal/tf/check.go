@@ -0,0 +1,190 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// DiscrepancyKind describes how a variable differs between the Terraform
+// module and Blueprints Metadata
+type DiscrepancyKind string
+
+const (
+	// MissingFromMetadata means the variable is declared in the Terraform
+	// module but not in Blueprints Metadata
+	MissingFromMetadata DiscrepancyKind = "MissingFromMetadata"
+	// MissingFromTf means the variable is declared in Blueprints Metadata but
+	// not in the Terraform module
+	MissingFromTf DiscrepancyKind = "MissingFromTf"
+	// TypeMismatch means the Terraform type and metadata varType differ
+	TypeMismatch DiscrepancyKind = "TypeMismatch"
+)
+
+// Discrepancy is a difference in a variable between the Terraform module and
+// Blueprints Metadata
+type Discrepancy struct {
+	Variable     string          `json:"variable"`
+	Kind         DiscrepancyKind `json:"kind"`
+	TfType       string          `json:"tfType,omitempty"`
+	MetadataType string          `json:"metadataType,omitempty"`
+}
+
+func (d Discrepancy) String() string {
+	switch d.Kind {
+	case MissingFromMetadata:
+		return fmt.Sprintf("variable: %s is missing from %s", d.Variable, metadataFile)
+	case MissingFromTf:
+		return fmt.Sprintf("variable: %s is missing from the terraform module", d.Variable)
+	default:
+		return fmt.Sprintf("variable: %s has type: %s in the terraform module but varType: %s in %s",
+			d.Variable, d.TfType, d.MetadataType, metadataFile)
+	}
+}
+
+// CrossCheckVariables reports variables declared in the Terraform module but
+// not in Blueprints Metadata and vice versa, as well as variables whose types
+// differ. Modules without a metadata file have no discrepancies.
+func CrossCheckVariables(dir string) ([]Discrepancy, error) {
+	module, diag := tfconfig.LoadModule(dir)
+	if diag.HasErrors() {
+		return nil, fmt.Errorf("failure parsing terraform module: %w", newModuleParseError(diag))
+	}
+
+	data, err := os.ReadFile(path.Join(dir, metadataFile))
+	if err != nil {
+		// CLI only modules will not have a metadata file. Ignore file not found errors
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	metadataVariables, err := getMetadataVariables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var discrepancies []Discrepancy
+	metadataNames := map[string]bool{}
+	for _, metadataVar := range metadataVariables {
+		metadataNames[metadataVar.Name] = true
+
+		tfVar, ok := module.Variables[metadataVar.Name]
+		if !ok {
+			discrepancies = append(discrepancies, Discrepancy{
+				Variable:     metadataVar.Name,
+				Kind:         MissingFromTf,
+				MetadataType: metadataVar.VarType,
+			})
+			continue
+		}
+
+		// Variables without a declared type or varType accept any type
+		if tfVar.Type != "" && metadataVar.VarType != "" &&
+			normalizeType(tfVar.Type) != normalizeType(metadataVar.VarType) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Variable:     metadataVar.Name,
+				Kind:         TypeMismatch,
+				TfType:       tfVar.Type,
+				MetadataType: metadataVar.VarType,
+			})
+		}
+	}
+
+	for name, tfVar := range module.Variables {
+		if !metadataNames[name] {
+			discrepancies = append(discrepancies, Discrepancy{
+				Variable: name,
+				Kind:     MissingFromMetadata,
+				TfType:   tfVar.Type,
+			})
+		}
+	}
+
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		return discrepancies[i].Variable < discrepancies[j].Variable
+	})
+	return discrepancies, nil
+}
+
+// normalizeType removes whitespace from a type expression so that types
+// spanning multiple lines can be compared
+func normalizeType(varType string) string {
+	return strings.Join(strings.Fields(varType), "")
+}
+
+// ValidateConfigAgainstModule checks that config applies to the module in dir
+// without writing any files, and returns every problem found rather than only
+// the first. Each variable of Variables or NewValues is staged on its own, so
+// that a variable that is not found, has no default, has the wrong type or
+// has no replacement does not hide the problems of the others. The options
+// that apply to the whole module are then staged once without variables.
+func ValidateConfigAgainstModule(config *overwriteConfig, dir string) []error {
+	err := config.Validate()
+	if err != nil {
+		return []error{err}
+	}
+
+	var configs []*overwriteConfig
+	if config.NewValues != nil {
+		for _, name := range sortedKeys(config.NewValues) {
+			single := *config
+			single.NewValues = map[string]string{name: config.NewValues[name]}
+			single.Expected = nil
+			if expected, ok := config.Expected[name]; ok {
+				single.Expected = map[string]string{name: expected}
+			}
+			configs = append(configs, &single)
+		}
+		moduleWide := *config
+		moduleWide.NewValues = map[string]string{}
+		moduleWide.Expected = nil
+		configs = append(configs, &moduleWide)
+	} else {
+		for _, name := range config.Variables {
+			single := *config
+			single.Variables = []string{name}
+			single.VariableTypes = nil
+			configs = append(configs, &single)
+		}
+		moduleWide := *config
+		moduleWide.Variables = nil
+		configs = append(configs, &moduleWide)
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for _, stagedConfig := range configs {
+		result := newOverwriteResult()
+		err := stageAll(context.Background(), result, stagedConfig, dir)
+		if err == nil {
+			err = result.validate()
+		}
+		// Problems with the whole module are found for every variable
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossCheckVariables(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		files                 map[string]string
+		expectedDiscrepancies []Discrepancy
+		errorContains         string
+	}{{
+		name: "No discrepancies",
+		files: map[string]string{
+			"variables.tf":  tfCrossCheck,
+			"metadata.yaml": metadataCrossCheck,
+		},
+	}, {
+		name: "No discrepancies without metadata",
+		files: map[string]string{
+			"variables.tf": tfCrossCheck,
+		},
+	}, {
+		name: "Report missing variables and type mismatches",
+		files: map[string]string{
+			"variables.tf":  tfCrossCheck,
+			"metadata.yaml": metadataCrossCheckDrifted,
+		},
+		expectedDiscrepancies: []Discrepancy{{
+			Variable:     "disk_size",
+			Kind:         TypeMismatch,
+			TfType:       "number",
+			MetadataType: "string",
+		}, {
+			Variable:     "source_image",
+			Kind:         MissingFromTf,
+			MetadataType: "string",
+		}, {
+			Variable: "source_image_name",
+			Kind:     MissingFromMetadata,
+			TfType:   "string",
+		}},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		files: map[string]string{
+			"variables.tf": "this is broken",
+		},
+		errorContains: "failure parsing terraform module",
+	}, {
+		name: "Invalid metadata shows parsing error",
+		files: map[string]string{
+			"variables.tf":  tfCrossCheck,
+			"metadata.yaml": "- not validyaml\ninvalid-",
+		},
+		errorContains: "failure parsing metadata.yaml",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			discrepancies, err := CrossCheckVariables(tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedDiscrepancies, discrepancies)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestValidateConfigAgainstModule(t *testing.T) {
+	testcases := []struct {
+		name            string
+		overwriteConfig overwriteConfig
+		expectedErrs    []string
+	}{{
+		name: "Valid config",
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"old-image": "new-image",
+			},
+		},
+	}, {
+		name: "Report every problem with Variables",
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "missing_variable", "no_default", "disk_size", "other_image"},
+			Replacements: map[string]string{
+				"old-image": "new-image",
+			},
+		},
+		expectedErrs: []string{
+			"variable: missing_variable not found in module.",
+			"image variable: no_default must have default value",
+			"image variable: disk_size must be type string",
+			"default value: other-image of variable: other_image not found in replacements",
+		},
+	}, {
+		name: "Report every problem with NewValues",
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image":     "new-image",
+				"missing_variable": "new-image",
+				"other_image":      "new-image",
+			},
+			Expected: map[string]string{
+				"other_image": "older-image",
+			},
+			DisplayTitles: map[string]string{
+				"missing_variable": "Missing",
+			},
+		},
+		expectedErrs: []string{
+			"variable: missing_variable not found in module.",
+			"current value: other-image of variable: other_image does not match expected value: older-image",
+			"missing valid display info for variable: missing_variable",
+		},
+	}, {
+		name: "Report invalid config",
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+			Expected: map[string]string{
+				"other_image": "older-image",
+			},
+		},
+		expectedErrs: []string{
+			"invalid overwrite config: expected value of: other_image has no entry in newValues",
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			files := map[string]string{
+				"main.tf":               tfValidateConfig,
+				"metadata.display.yaml": metadataDisplayNoEnums,
+			}
+			for file, content := range files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			errs := ValidateConfigAgainstModule(&tc.overwriteConfig, tmpDir)
+			assert.Len(t, errs, len(tc.expectedErrs))
+			for i, expectedErr := range tc.expectedErrs {
+				if i < len(errs) {
+					assert.ErrorContains(t, errs[i], expectedErr)
+				}
+			}
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, files, actualContents)
+		})
+	}
+}
+
+var tfValidateConfig string = `
+variable "source_image" {
+  type    = string
+  default = "old-image"
+}
+
+variable "other_image" {
+  type    = string
+  default = "other-image"
+}
+
+variable "no_default" {
+  type = string
+}
+
+variable "disk_size" {
+  type    = number
+  default = 10
+}
+`
+
+var tfCrossCheck string = `
+variable "source_image_name" {
+  type    = string
+  default = "old-image"
+}
+
+variable "disk_size" {
+  type    = number
+  default = 10
+}
+
+variable "zones" {
+  type = list( string )
+}
+`
+
+var metadataCrossCheck string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image_name
+      varType: string
+    - name: disk_size
+      varType: number
+    - name: zones
+      varType: list(string)
+`
+
+var metadataCrossCheckDrifted string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+    - name: disk_size
+      varType: string
+    - name: zones
+      varType: list(string)
+`
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// getCollectionStrings returns the elements of a list default or the values of
+// a map default. ok is false if the default is not a list or map of strings.
+func getCollectionStrings(value interface{}) (elements []string, ok bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			s, ok := element.(string)
+			if !ok {
+				return nil, false
+			}
+			elements = append(elements, s)
+		}
+		return elements, true
+	case map[string]interface{}:
+		for _, element := range v {
+			s, ok := element.(string)
+			if !ok {
+				return nil, false
+			}
+			elements = append(elements, s)
+		}
+		sort.Strings(elements)
+		return elements, true
+	default:
+		return nil, false
+	}
+}
+
+// replaceCollectionStrings returns a copy of a list or map default with the
+// replaced elements
+func replaceCollectionStrings(value interface{}, replacements map[string]string) interface{} {
+	replace := func(element interface{}) interface{} {
+		if replaceVal, ok := replacements[element.(string)]; ok {
+			return replaceVal
+		}
+		return element
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		var replaced []interface{}
+		for _, element := range v {
+			replaced = append(replaced, replace(element))
+		}
+		return replaced
+	case map[string]interface{}:
+		replaced := map[string]interface{}{}
+		for key, element := range v {
+			replaced[key] = replace(element)
+		}
+		return replaced
+	default:
+		return value
+	}
+}
+
+// overwriteCollectionValue runs each string in a list or map default through
+// the replacements. Strings without a replacement are left as is, unless
+// Strict is set.
+func overwriteCollectionValue(edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, moduleVal *moduleValue, elements []string) error {
+	replacements := map[string]string{}
+	for _, element := range elements {
+		replaceVal, ok, err := config.replacementFor(moduleVal.Name, element)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if replaceVal != element {
+				replacements[element] = replaceVal
+			}
+			continue
+		}
+		if !config.isReplacementTarget(element) && config.Strict {
+			return newVariableError(ErrReplacementNotFound, moduleVal.Name,
+				"element: %s of default value of %s: %s not found in replacements",
+				element, moduleVal.kind(), moduleVal.Name)
+		}
+	}
+
+	if len(replacements) == 0 {
+		report.Skipped = append(report.Skipped, moduleVal.Name)
+		return nil
+	}
+
+	edits.add(moduleVal.Filename, func(result *OverwriteResult) error {
+		if isTfJSONFile(moduleVal.Filename) {
+			return overwriteJSONCollectionFile(result, moduleVal.Filename, moduleVal.Name, replacements)
+		}
+		return overwriteCollectionFile(result, moduleVal.Filename, moduleVal.Name, replacements)
+	})
+
+	report.Changes = append(report.Changes, VariableChange{
+		File:       moduleVal.Filename,
+		Variable:   moduleVal.Name,
+		OldDefault: moduleVal.Default,
+		NewDefault: replaceCollectionStrings(moduleVal.Default, replacements),
+	})
+	return nil
+}
+
+// overwriteCollectionFile replaces string literals within the default value of
+// a variable, leaving the formatting of the list or map untouched. Map keys are
+// never replaced.
+func overwriteCollectionFile(result *OverwriteResult, filename string, varname string,
+	replacements map[string]string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	block := file.Body().FirstMatchingBlock("variable", []string{varname})
+	if block == nil {
+		return fmt.Errorf("did not find block with variable: %s", varname)
+	}
+	attribute := block.Body().GetAttribute("default")
+	if attribute == nil {
+		return fmt.Errorf("did not find default value of variable: %s", varname)
+	}
+
+	tokens := attribute.Expr().BuildTokens(nil)
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].Type != hclsyntax.TokenOQuote ||
+			tokens[i+1].Type != hclsyntax.TokenQuotedLit ||
+			tokens[i+2].Type != hclsyntax.TokenCQuote {
+			continue
+		}
+		if isObjectKey(tokens[i+3:]) {
+			continue
+		}
+		if replaceVal, ok := replacements[string(tokens[i+1].Bytes)]; ok {
+			tokens[i+1].Bytes = []byte(replaceVal)
+		}
+	}
+	block.Body().SetAttributeRaw("default", tokens)
+
+	result.stageFile(filename, b, file.BuildTokens(nil).Bytes())
+	return nil
+}
+
+// isObjectKey returns whether the tokens following a quoted string start with
+// an equals sign or colon, i.e. whether the string is the key of an object item
+func isObjectKey(tokens hclwrite.Tokens) bool {
+	for _, token := range tokens {
+		if token.Type == hclsyntax.TokenNewline {
+			continue
+		}
+		return token.Type == hclsyntax.TokenEqual || token.Type == hclsyntax.TokenColon
+	}
+	return false
+}
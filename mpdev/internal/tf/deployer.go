@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overwriteDeployerImage replaces the deployer image reference at the
+// DeployerImagePath of Blueprints Metadata using the replacements. References
+// that already equal a replacement value are left as is.
+func overwriteDeployerImage(config *overwriteConfig, data []byte, filename string) ([]byte, error) {
+	if config.DeployerImagePath == "" {
+		return data, nil
+	}
+
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing %s error: %w", filename, err)
+	}
+
+	node := findYAMLPathNode(&root, strings.Split(config.DeployerImagePath, "."))
+	if node == nil {
+		return nil, fmt.Errorf("deployer image path: %s not found in %s",
+			config.DeployerImagePath, filename)
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("deployer image path: %s in %s must be a string",
+			config.DeployerImagePath, filename)
+	}
+
+	replaceVal, ok, err := config.replacementFor(config.DeployerImagePath, node.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && config.isReplacementTarget(node.Value) {
+		return data, nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("deployer image: %s at %s in %s not found in replacements",
+			node.Value, config.DeployerImagePath, filename)
+	}
+	if replaceVal == node.Value {
+		return data, nil
+	}
+
+	fmt.Printf("Replacing the deployer image: %s at %s in %s\n",
+		node.Value, config.DeployerImagePath, filename)
+	return setYAMLScalar(data, &root, node, replaceVal)
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/tidwall/gjson"
+	"sigs.k8s.io/yaml"
+)
+
+// DiffKind describes how an overwritable value differs between two modules
+type DiffKind string
+
+const (
+	// Added means the value only exists in the new module
+	Added DiffKind = "Added"
+	// Removed means the value only exists in the old module
+	Removed DiffKind = "Removed"
+	// Changed means the value differs between the modules
+	Changed DiffKind = "Changed"
+)
+
+// ValueDiff is a difference in the overwritable value of a variable between
+// two modules
+type ValueDiff struct {
+	Variable string      `json:"variable"`
+	Kind     DiffKind    `json:"kind"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+func (d ValueDiff) String() string {
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("variable: %s was added with value: %v", d.Variable, d.NewValue)
+	case Removed:
+		return fmt.Sprintf("variable: %s with value: %v was removed", d.Variable, d.OldValue)
+	default:
+		return fmt.Sprintf("variable: %s changed from: %v to: %v", d.Variable, d.OldValue, d.NewValue)
+	}
+}
+
+// ModuleDiff contains the differences in overwritable values between two
+// modules, sorted by variable
+type ModuleDiff struct {
+	// Defaults are the differences in Terraform variable defaults
+	Defaults []ValueDiff `json:"defaults"`
+	// MetadataDefaults are the differences in Blueprints Metadata defaultValues
+	MetadataDefaults []ValueDiff `json:"metadataDefaults"`
+	// DisplayEnums are the differences in the enum values of display variables
+	DisplayEnums []ValueDiff `json:"displayEnums"`
+}
+
+// Empty returns whether the modules have no differences
+func (d *ModuleDiff) Empty() bool {
+	return len(d.Defaults) == 0 && len(d.MetadataDefaults) == 0 && len(d.DisplayEnums) == 0
+}
+
+// DiffModules reports the variable defaults, Blueprints Metadata defaultValues
+// and display enum values that were added, removed or changed between the
+// modules in oldDir and newDir. Missing metadata files have no values.
+func DiffModules(oldDir, newDir string) (*ModuleDiff, error) {
+	oldValues, err := getOverwritableValues(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := getOverwritableValues(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ModuleDiff{
+		Defaults:         diffValues(oldValues.defaults, newValues.defaults),
+		MetadataDefaults: diffValues(oldValues.metadataDefaults, newValues.metadataDefaults),
+		DisplayEnums:     diffValues(oldValues.displayEnums, newValues.displayEnums),
+	}, nil
+}
+
+// overwritableValues are the values of a module by variable name
+type overwritableValues struct {
+	defaults         map[string]interface{}
+	metadataDefaults map[string]interface{}
+	displayEnums     map[string]interface{}
+}
+
+func getOverwritableValues(dir string) (*overwritableValues, error) {
+	values := &overwritableValues{
+		defaults:         map[string]interface{}{},
+		metadataDefaults: map[string]interface{}{},
+		displayEnums:     map[string]interface{}{},
+	}
+
+	variables, err := ParseModuleVariables(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, variable := range variables {
+		if variable.HasDefault {
+			values.defaults[variable.Name] = variable.Default
+		}
+	}
+
+	data, err := os.ReadFile(path.Join(dir, metadataFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		metadataVariables, err := getMetadataVariables(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, metadataVar := range metadataVariables {
+			if metadataVar.DefaultValue != nil {
+				values.metadataDefaults[metadataVar.Name] = metadataVar.DefaultValue
+			}
+		}
+	}
+
+	data, err = os.ReadFile(path.Join(dir, metadataDisplayFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		json, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failure parsing %s error: %w", metadataDisplayFile, err)
+		}
+		gjson.GetBytes(json, "spec.ui.input.variables").ForEach(func(key, variableInfo gjson.Result) bool {
+			var enumValues []string
+			for _, enumValueLabel := range variableInfo.Get("enumValueLabels").Array() {
+				enumValues = append(enumValues, enumValueLabel.Get("value").String())
+			}
+			if len(enumValues) > 0 {
+				values.displayEnums[key.String()] = enumValues
+			}
+			return true
+		})
+	}
+
+	return values, nil
+}
+
+// diffValues returns the differences between the values of two modules,
+// sorted by variable
+func diffValues(oldValues, newValues map[string]interface{}) []ValueDiff {
+	var diffs []ValueDiff
+	for name, oldValue := range oldValues {
+		newValue, ok := newValues[name]
+		if !ok {
+			diffs = append(diffs, ValueDiff{Variable: name, Kind: Removed, OldValue: oldValue})
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			diffs = append(diffs, ValueDiff{Variable: name, Kind: Changed, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	for name, newValue := range newValues {
+		if _, ok := oldValues[name]; !ok {
+			diffs = append(diffs, ValueDiff{Variable: name, Kind: Added, NewValue: newValue})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Variable < diffs[j].Variable
+	})
+	return diffs
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffModules(t *testing.T) {
+	testcases := []struct {
+		name          string
+		oldFiles      map[string]string
+		newFiles      map[string]string
+		expectedDiff  *ModuleDiff
+		expectedEmpty bool
+		errorContains string
+	}{{
+		name: "No differences",
+		oldFiles: map[string]string{
+			"main.tf":               mainTf,
+			"metadata.yaml":         metadata,
+			"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+		},
+		newFiles: map[string]string{
+			"main.tf":               mainTf,
+			"metadata.yaml":         metadata,
+			"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+		},
+		expectedDiff:  &ModuleDiff{},
+		expectedEmpty: true,
+	}, {
+		name: "Report added, removed and changed values",
+		oldFiles: map[string]string{
+			"main.tf":               mainTf,
+			"anyfilename.tf":        otherTf,
+			"metadata.yaml":         metadata,
+			"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+		},
+		newFiles: map[string]string{
+			"main.tf":               mainTfReplaced,
+			"metadata.yaml":         metadataReplaced,
+			"metadata.display.yaml": metadataDisplayWithEnumsSingleReplaced,
+			"images.tf":             tfCollections,
+		},
+		expectedDiff: &ModuleDiff{
+			Defaults: []ValueDiff{{
+				Variable: "another_variable",
+				Kind:     Removed,
+				OldValue: "oldest-value",
+			}, {
+				Variable: "images",
+				Kind:     Added,
+				NewValue: []interface{}{"old-image-a", "old-image-b", "other-image"},
+			}, {
+				Variable: "images_by_zone",
+				Kind:     Added,
+				NewValue: map[string]interface{}{"us-east1-b": "old-image-a", "us-central1-a": "old-image-b"},
+			}, {
+				Variable: "other_value_to_replace",
+				Kind:     Changed,
+				OldValue: "old-value",
+				NewValue: "newer-value",
+			}, {
+				Variable: "value_to_replace",
+				Kind:     Changed,
+				OldValue: "original-value",
+				NewValue: "new-value",
+			}},
+			MetadataDefaults: []ValueDiff{{
+				Variable: "another_image",
+				Kind:     Changed,
+				OldValue: "older-image",
+				NewValue: "newer-image",
+			}, {
+				Variable: "source_image",
+				Kind:     Changed,
+				OldValue: "old-image",
+				NewValue: "new-image",
+			}},
+			DisplayEnums: []ValueDiff{{
+				Variable: "another_image",
+				Kind:     Removed,
+				OldValue: []string{"projects/click-to-deploy-images/global/images/wordpress-3"},
+			}, {
+				Variable: "source_image",
+				Kind:     Changed,
+				OldValue: []string{
+					"projects/click-to-deploy-images/global/images/wordpress-1",
+					"projects/click-to-deploy-images/global/images/wordpress-2",
+				},
+				NewValue: []string{"projects/replacement/global/images/wordpress-1-new"},
+			}},
+		},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		oldFiles: map[string]string{
+			"main.tf": mainTf,
+		},
+		newFiles: map[string]string{
+			"main.tf": "this is broken",
+		},
+		errorContains: "failure parsing terraform module",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(oldDir)
+			newDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(newDir)
+
+			for file, content := range tc.oldFiles {
+				err = os.WriteFile(path.Join(oldDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+			for file, content := range tc.newFiles {
+				err = os.WriteFile(path.Join(newDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			diff, err := DiffModules(oldDir, newDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedDiff, diff)
+				assert.Equal(t, tc.expectedEmpty, diff.Empty())
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"regexp"
+	"strings"
+)
+
+// digestPattern matches the digest of a container image reference, such as
+// sha256:<hex>
+var digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+
+// splitImageReference splits a container image reference such as
+// gcr.io/proj/app:1.2.3@sha256:abc into its repository, tag and digest. The tag
+// and digest are empty if they are not set.
+func splitImageReference(value string) (repository string, tag string, digest string) {
+	repository = value
+	if i := strings.LastIndex(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	// A colon after the last slash separates the tag. A colon before it
+	// separates the port of the registry host.
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+// digestReplacementFor returns value with its digest replaced by the digest in
+// Digests for its repository. Only references already pinned to a digest are
+// replaced, so the tag and repository are always kept.
+func (config *overwriteConfig) digestReplacementFor(value string) (string, bool) {
+	repository, tag, digest := splitImageReference(value)
+	newDigest, ok := config.Digests[repository]
+	if !ok || digest == "" || !digestPattern.MatchString(digest) {
+		return "", false
+	}
+
+	replaced := repository
+	if tag != "" {
+		replaced += ":" + tag
+	}
+	return replaced + "@" + newDigest, true
+}
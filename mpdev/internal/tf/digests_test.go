@@ -0,0 +1,59 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitImageReference(t *testing.T) {
+	testcases := []struct {
+		name               string
+		value              string
+		expectedRepository string
+		expectedTag        string
+		expectedDigest     string
+	}{{
+		name:               "Repository only",
+		value:              "gcr.io/proj/app",
+		expectedRepository: "gcr.io/proj/app",
+	}, {
+		name:               "Tag",
+		value:              "gcr.io/proj/app:1.2.3",
+		expectedRepository: "gcr.io/proj/app",
+		expectedTag:        "1.2.3",
+	}, {
+		name:               "Tag and digest",
+		value:              "gcr.io/proj/app:1.2.3@sha256:abc",
+		expectedRepository: "gcr.io/proj/app",
+		expectedTag:        "1.2.3",
+		expectedDigest:     "sha256:abc",
+	}, {
+		name:               "Registry port and digest",
+		value:              "localhost:5000/proj/app@sha256:abc",
+		expectedRepository: "localhost:5000/proj/app",
+		expectedDigest:     "sha256:abc",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			repository, tag, digest := splitImageReference(tc.value)
+			assert.Equal(t, tc.expectedRepository, repository)
+			assert.Equal(t, tc.expectedTag, tag)
+			assert.Equal(t, tc.expectedDigest, digest)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OverwriteTfDirs applies the same overwrite config to the Terraform modules
+// in each of dirs, such as the independent modules of a release. Every
+// directory is overwritten even if another fails. The returned error joins
+// the errors of the failed directories, each identifying its directory.
+func OverwriteTfDirs(config *overwriteConfig, dirs []string) error {
+	return overwriteDirs(config, dirs, func(config *overwriteConfig, dir string) error {
+		_, err := OverwriteTf(config, dir)
+		return err
+	})
+}
+
+// OverwriteMetadataDirs is like OverwriteTfDirs for the Blueprints Metadata of
+// each of dirs
+func OverwriteMetadataDirs(config *overwriteConfig, dirs []string) error {
+	return overwriteDirs(config, dirs, func(config *overwriteConfig, dir string) error {
+		_, err := OverwriteMetadata(config, dir)
+		return err
+	})
+}
+
+// OverwriteDisplayDirs is like OverwriteTfDirs for the Blueprints Metadata
+// display file of each of dirs
+func OverwriteDisplayDirs(config *overwriteConfig, dirs []string) error {
+	return overwriteDirs(config, dirs, func(config *overwriteConfig, dir string) error {
+		_, err := OverwriteDisplay(config, dir)
+		return err
+	})
+}
+
+func overwriteDirs(config *overwriteConfig, dirs []string,
+	overwrite func(*overwriteConfig, string) error) error {
+	var errs []error
+	for _, dir := range dirs {
+		err := overwrite(config, dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failure overwriting %s: %w", dir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
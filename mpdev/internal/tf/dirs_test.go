@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteTfDirs(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"solutions/web/main.tf":   mainTf,
+		"solutions/db/main.tf":    otherTf,
+		"solutions/cache/main.tf": mainTf,
+	}
+	for file, content := range files {
+		err = os.MkdirAll(path.Dir(path.Join(tmpDir, file)), 0700)
+		assert.NoError(t, err)
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	dirs := []string{
+		path.Join(tmpDir, "solutions/web"),
+		path.Join(tmpDir, "solutions/db"),
+		path.Join(tmpDir, "solutions/cache"),
+	}
+	err = OverwriteTfDirs(&overwriteConfig{
+		NewValues: map[string]string{
+			"value_to_replace":       "new-value",
+			"other_value_to_replace": "newer-value",
+		},
+	}, dirs)
+
+	// The failure of one directory does not stop the others
+	assert.ErrorIs(t, err, ErrVariableNotFound)
+	assert.ErrorContains(t, err, "failure overwriting "+path.Join(tmpDir, "solutions/db")+
+		": variable: other_value_to_replace not found")
+	assert.NotContains(t, err.Error(), path.Join(tmpDir, "solutions/web"))
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"solutions/web/main.tf":   mainTfReplaced,
+		"solutions/db/main.tf":    otherTf,
+		"solutions/cache/main.tf": mainTfReplaced,
+	}, actualContents)
+}
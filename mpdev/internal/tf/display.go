@@ -0,0 +1,526 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"sigs.k8s.io/yaml"
+)
+
+const gceDiskImageType = "ET_GCE_DISK_IMAGE"
+
+// gceImagePattern matches GCE image URIs of the form
+// projects/{project}/global/images/{image}, including image families
+var gceImagePattern = regexp.MustCompile(
+	`^projects/[^/\s]+/global/images/(family/)?[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// validateImageURIs checks that the enum values of a display variable with type
+// ET_GCE_DISK_IMAGE are well-formed GCE image URIs, if ValidateImageURIs is set
+func validateImageURIs(config *overwriteConfig, json []byte, varname string, filename string) error {
+	if !config.ValidateImageURIs {
+		return nil
+	}
+	variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, escapeJSONPath(varname))
+	if gjson.GetBytes(json, variableQuery+".xGoogleProperty.type").String() != gceDiskImageType {
+		return nil
+	}
+
+	for _, value := range gjson.GetBytes(json, variableQuery+".enumValueLabels.#.value").Array() {
+		if !gceImagePattern.MatchString(value.String()) {
+			return newVariableError(ErrInvalidImageURI, varname,
+				"enum value: %s of variable: %s in %s is not a GCE image URI of the form"+
+					" projects/{project}/global/images/{image}", value.String(), varname, filename)
+		}
+	}
+	return nil
+}
+
+// overwriteDiskImageProperty replaces image values within the xGoogleProperty of
+// a display variable with type ET_GCE_DISK_IMAGE. Newer display files express
+// image choices under xGoogleProperty rather than enumValueLabels. Since the
+// subtree may contain other settings, only string values with a replacement are
+// overwritten.
+func overwriteDiskImageProperty(config *overwriteConfig, json []byte, varname string) ([]byte, error) {
+	propertyQuery := fmt.Sprintf(`spec.ui.input.variables.%s.xGoogleProperty`, escapeJSONPath(varname))
+	property := gjson.GetBytes(json, propertyQuery)
+	if property.Get("type").String() != gceDiskImageType {
+		return json, nil
+	}
+
+	propertyMap, ok := property.Value().(map[string]interface{})
+	if !ok {
+		return json, nil
+	}
+
+	changed := false
+	for key, value := range propertyMap {
+		if key == "type" {
+			continue
+		}
+		replaced, replacedChanged, err := replaceStringValues(config, varname, value)
+		if err != nil {
+			return nil, err
+		}
+		if replacedChanged {
+			propertyMap[key] = replaced
+			changed = true
+		}
+	}
+	if !changed {
+		return json, nil
+	}
+
+	json, err := sjson.SetBytes(json, propertyQuery, propertyMap)
+	if err != nil {
+		return nil, fmt.Errorf("error setting xGoogleProperty of variable: %s. error: %w",
+			varname, err)
+	}
+	return json, nil
+}
+
+// overwriteDisplayLabels sets the titles in DisplayTitles and the enum labels in
+// DisplayLabels of display variables. Enum entries are addressed by their
+// current label, and entries already carrying the new label are left as is.
+func overwriteDisplayLabels(config *overwriteConfig, json []byte, filename string) ([]byte, error) {
+	var err error
+	for _, varName := range sortedKeys(config.DisplayTitles) {
+		variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, escapeJSONPath(varName))
+		if !gjson.GetBytes(json, variableQuery).Exists() {
+			return nil, newVariableError(ErrVariableNotFound, varName,
+				"missing valid display info for variable: %s in %s",
+				varName, filename)
+		}
+		json, err = sjson.SetBytes(json, variableQuery+".title", config.DisplayTitles[varName])
+		if err != nil {
+			return nil, fmt.Errorf("error setting title of variable: %s. error: %w",
+				varName, err)
+		}
+	}
+
+	varNames := make([]string, 0, len(config.DisplayLabels))
+	for varName := range config.DisplayLabels {
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	for _, varName := range varNames {
+		variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, escapeJSONPath(varName))
+		if !gjson.GetBytes(json, variableQuery).Exists() {
+			return nil, newVariableError(ErrVariableNotFound, varName,
+				"missing valid display info for variable: %s in %s",
+				varName, filename)
+		}
+
+		labels := config.DisplayLabels[varName]
+		enumValueLabels := gjson.GetBytes(json, variableQuery+".enumValueLabels").Array()
+		for _, currLabel := range sortedKeys(labels) {
+			newLabel := labels[currLabel]
+			found := false
+			for i, enumValueLabel := range enumValueLabels {
+				label := enumValueLabel.Get("label").String()
+				if label == newLabel {
+					found = true
+				}
+				if label != currLabel {
+					continue
+				}
+				found = true
+				labelQuery := fmt.Sprintf("%s.enumValueLabels.%d.label", variableQuery, i)
+				json, err = sjson.SetBytes(json, labelQuery, newLabel)
+				if err != nil {
+					return nil, fmt.Errorf("error setting enum label of variable: %s. error: %w",
+						varName, err)
+				}
+			}
+			if !found {
+				return nil, newVariableError(ErrReplacementNotFound, varName,
+					"enum label: %s of variable: %s not found in %s",
+					currLabel, varName, filename)
+			}
+		}
+	}
+	return json, nil
+}
+
+// overwriteDisplayProperties sets the properties in DisplayProperties of display
+// variables, such as required or xGoogleProperty.type. Only the named
+// properties are touched. A named variable or property that is absent from the
+// display file, or a new value whose type differs from the current value, is
+// an error.
+func overwriteDisplayProperties(config *overwriteConfig, json []byte, filename string) ([]byte, error) {
+	varNames := make([]string, 0, len(config.DisplayProperties))
+	for varName := range config.DisplayProperties {
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	var err error
+	for _, varName := range varNames {
+		variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, escapeJSONPath(varName))
+		if !gjson.GetBytes(json, variableQuery).Exists() {
+			return nil, newVariableError(ErrVariableNotFound, varName,
+				"missing valid display info for variable: %s in %s",
+				varName, filename)
+		}
+
+		properties := config.DisplayProperties[varName]
+		propertyNames := make([]string, 0, len(properties))
+		for property := range properties {
+			propertyNames = append(propertyNames, property)
+		}
+		sort.Strings(propertyNames)
+
+		for _, property := range propertyNames {
+			var escaped []string
+			for _, key := range strings.Split(property, ".") {
+				escaped = append(escaped, escapeJSONPath(key))
+			}
+			propertyQuery := variableQuery + "." + strings.Join(escaped, ".")
+
+			currValue := gjson.GetBytes(json, propertyQuery)
+			if !currValue.Exists() {
+				return nil, newVariableError(ErrVariableNotFound, varName,
+					"display property: %s of variable: %s not found in %s",
+					property, varName, filename)
+			}
+			newValue := properties[property]
+			if !isDisplayPropertyType(newValue, currValue) {
+				return nil, newVariableError(ErrWrongType, varName,
+					"new value: %v of display property: %s of variable: %s in %s must have"+
+						" the type of its current value: %s", newValue, property, varName, filename, currValue.Raw)
+			}
+
+			json, err = sjson.SetBytes(json, propertyQuery, newValue)
+			if err != nil {
+				return nil, fmt.Errorf("error setting display property: %s of variable: %s. error: %w",
+					property, varName, err)
+			}
+		}
+	}
+	return json, nil
+}
+
+// isDisplayPropertyType returns whether a new display property value is a
+// bool, string or number like the current value
+func isDisplayPropertyType(value interface{}, currValue gjson.Result) bool {
+	switch value.(type) {
+	case bool:
+		return currValue.IsBool()
+	case string:
+		return currValue.Type == gjson.String
+	case float64, int:
+		return currValue.Type == gjson.Number
+	default:
+		return false
+	}
+}
+
+// displayNumberProperties are the numeric properties within the xGoogleProperty
+// of display variables that DisplayNumbers can set
+var displayNumberProperties = []string{"default", "max", "min"}
+
+func isDisplayNumberProperty(property string) bool {
+	for _, numberProperty := range displayNumberProperties {
+		if property == numberProperty {
+			return true
+		}
+	}
+	return false
+}
+
+// overwriteDisplayNumbers sets the numeric default and bounds in DisplayNumbers
+// within the xGoogleProperty of display variables. A property that is absent is
+// added, while a current value that is not a number is an error. The default
+// must then lie within the bounds.
+func overwriteDisplayNumbers(config *overwriteConfig, json []byte, filename string) ([]byte, error) {
+	varNames := make([]string, 0, len(config.DisplayNumbers))
+	for varName := range config.DisplayNumbers {
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	var err error
+	for _, varName := range varNames {
+		propertyQuery := fmt.Sprintf(`spec.ui.input.variables.%s.xGoogleProperty`, escapeJSONPath(varName))
+		if !gjson.GetBytes(json, propertyQuery).IsObject() {
+			return nil, newVariableError(ErrVariableNotFound, varName,
+				"missing xGoogleProperty of display variable: %s in %s", varName, filename)
+		}
+
+		numbers := config.DisplayNumbers[varName]
+		for _, property := range displayNumberProperties {
+			newValue, ok := numbers[property]
+			if !ok {
+				continue
+			}
+			currValue := gjson.GetBytes(json, propertyQuery+"."+property)
+			if currValue.Exists() && currValue.Type != gjson.Number {
+				return nil, newVariableError(ErrWrongType, varName,
+					"display property: xGoogleProperty.%s of variable: %s in %s must be a number: %s",
+					property, varName, filename, currValue.Raw)
+			}
+
+			json, err = sjson.SetBytes(json, propertyQuery+"."+property, newValue)
+			if err != nil {
+				return nil, fmt.Errorf("error setting display property: xGoogleProperty.%s of variable: %s."+
+					" error: %w", property, varName, err)
+			}
+		}
+
+		property := gjson.GetBytes(json, propertyQuery)
+		minValue, maxValue, defaultValue := property.Get("min"), property.Get("max"), property.Get("default")
+		if minValue.Exists() && maxValue.Exists() && minValue.Num > maxValue.Num {
+			return nil, newVariableError(ErrOutOfBounds, varName,
+				"min: %s of display variable: %s in %s is greater than max: %s",
+				minValue.Raw, varName, filename, maxValue.Raw)
+		}
+		if defaultValue.Type != gjson.Number {
+			continue
+		}
+		if (minValue.Exists() && defaultValue.Num < minValue.Num) ||
+			(maxValue.Exists() && defaultValue.Num > maxValue.Num) {
+			return nil, newVariableError(ErrOutOfBounds, varName,
+				"default: %s of display variable: %s in %s is not within min: %s and max: %s",
+				defaultValue.Raw, varName, filename, minValue.Raw, maxValue.Raw)
+		}
+	}
+	return json, nil
+}
+
+// overwriteImageProjects repoints the xGoogleProperty imageProject of every
+// display variable with type ET_GCE_DISK_IMAGE using ImageProjects. An image
+// project without an entry is an error, unless it is already one of the new
+// projects. The enum values of a repointed variable must then be images in its
+// new image project.
+func overwriteImageProjects(config *overwriteConfig, json []byte, filename string) ([]byte, error) {
+	if len(config.ImageProjects) == 0 {
+		return json, nil
+	}
+
+	var varNames []string
+	gjson.GetBytes(json, "spec.ui.input.variables").ForEach(func(key, variableInfo gjson.Result) bool {
+		if variableInfo.Get("xGoogleProperty.type").String() == gceDiskImageType &&
+			variableInfo.Get("xGoogleProperty.imageProject").Exists() {
+			varNames = append(varNames, key.String())
+		}
+		return true
+	})
+
+	var err error
+	for _, varName := range varNames {
+		variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, escapeJSONPath(varName))
+		currProject := gjson.GetBytes(json, variableQuery+".xGoogleProperty.imageProject").String()
+		newProject, ok := config.ImageProjects[currProject]
+		if !ok && !config.isImageProjectTarget(currProject) {
+			return nil, newVariableError(ErrReplacementNotFound, varName,
+				"image project: %s of variable: %s in %s not found in imageProjects",
+				currProject, varName, filename)
+		}
+		if !ok {
+			newProject = currProject
+		}
+
+		json, err = sjson.SetBytes(json, variableQuery+".xGoogleProperty.imageProject", newProject)
+		if err != nil {
+			return nil, fmt.Errorf("error setting image project of variable: %s. error: %w",
+				varName, err)
+		}
+
+		for _, value := range gjson.GetBytes(json, variableQuery+".enumValueLabels.#.value").Array() {
+			if !gceImagePattern.MatchString(value.String()) {
+				continue
+			}
+			project := strings.Split(value.String(), "/")[1]
+			if project != newProject {
+				return nil, newVariableError(ErrImageProjectMismatch, varName,
+					"enum value: %s of variable: %s in %s is not in image project: %s",
+					value.String(), varName, filename, newProject)
+			}
+		}
+	}
+	return json, nil
+}
+
+// isImageProjectTarget returns whether a project is one of the new projects of
+// ImageProjects
+func (config *overwriteConfig) isImageProjectTarget(project string) bool {
+	for _, newProject := range config.ImageProjects {
+		if newProject == project {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceStringValues replaces the string values nested in maps and lists that
+// have a replacement, and returns whether any value was replaced.
+func replaceStringValues(config *overwriteConfig, varName string, value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		replaceVal, ok, err := config.replacementFor(varName, v)
+		if err != nil || !ok {
+			return v, false, err
+		}
+		return replaceVal, replaceVal != v, nil
+	case map[string]interface{}:
+		changed := false
+		for key, element := range v {
+			replaced, elementChanged, err := replaceStringValues(config, varName, element)
+			if err != nil {
+				return nil, false, err
+			}
+			v[key] = replaced
+			changed = changed || elementChanged
+		}
+		return v, changed, nil
+	case []interface{}:
+		changed := false
+		for i, element := range v {
+			replaced, elementChanged, err := replaceStringValues(config, varName, element)
+			if err != nil {
+				return nil, false, err
+			}
+			v[i] = replaced
+			changed = changed || elementChanged
+		}
+		return v, changed, nil
+	default:
+		return v, false, nil
+	}
+}
+
+// ParseDisplayEnums returns the enum values of each variable with
+// enumValueLabels in the Blueprints Metadata display file in dir, in the order
+// they are listed. Variables without enum values are omitted. Callers can use
+// the values to build the Replacements of an overwrite config.
+func ParseDisplayEnums(dir string) (map[string][]string, error) {
+	data, err := os.ReadFile(path.Join(dir, metadataDisplayFile))
+	if err != nil {
+		return nil, err
+	}
+
+	json, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing %s error: %w", metadataDisplayFile, err)
+	}
+
+	enums := map[string][]string{}
+	gjson.GetBytes(json, "spec.ui.input.variables").ForEach(func(key, variableInfo gjson.Result) bool {
+		for _, enumValueLabel := range variableInfo.Get("enumValueLabels").Array() {
+			enums[key.String()] = append(enums[key.String()], enumValueLabel.Get("value").String())
+		}
+		return true
+	})
+	return enums, nil
+}
+
+// validateEnums checks that the metadata default value of each display variable
+// with enumValueLabels is one of the enum values. Every metadata file of config
+// is validated against every display file, since they describe the variables of
+// the same module. If Recursive is set, the files of every subdirectory with a
+// metadata file are validated alike. Staged contents in result are validated in
+// place of the files on disk.
+func validateEnums(result *OverwriteResult, config *overwriteConfig, dir string) error {
+	metadataDirs, err := getMetadataDirs(result.fsys, dir, config.Recursive, config.metadataFilenames())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, metadataDir := range metadataDirs {
+		relDir, err := filepath.Rel(dir, metadataDir)
+		if err != nil {
+			return err
+		}
+		for _, metadataName := range config.metadataFilenames() {
+			for _, displayName := range config.displayFilenames() {
+				err := validateEnumsFile(result, dir,
+					path.Join(relDir, metadataName), path.Join(relDir, displayName))
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateEnumsFile checks the metadata default values of one metadata file
+// against the enum values of one display file, both relative to dir. Missing
+// files are skipped.
+func validateEnumsFile(result *OverwriteResult, dir string, metadataName string, displayName string) error {
+	metadataData, err := result.readFile(path.Join(dir, metadataName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	displayData, err := result.readFile(path.Join(dir, displayName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	metadataJson, err := yaml.YAMLToJSON(metadataData)
+	if err != nil {
+		return fmt.Errorf("failure parsing %s error: %w", metadataName, err)
+	}
+	displayJson, err := yaml.YAMLToJSON(displayData)
+	if err != nil {
+		return fmt.Errorf("failure parsing %s error: %w", displayName, err)
+	}
+
+	var errs []error
+	gjson.GetBytes(displayJson, "spec.ui.input.variables").ForEach(func(key, variableInfo gjson.Result) bool {
+		enumValueLabels := variableInfo.Get("enumValueLabels").Array()
+		if len(enumValueLabels) == 0 {
+			return true
+		}
+
+		query := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s").defaultValue`, key.String())
+		defaultVal := gjson.GetBytes(metadataJson, query)
+		if !defaultVal.Exists() {
+			return true
+		}
+
+		for _, enumValueLabel := range enumValueLabels {
+			if enumValueLabel.Get("value").String() == defaultVal.String() {
+				return true
+			}
+		}
+		errs = append(errs, fmt.Errorf("default value: %s of variable: %s in %s is not one of the"+
+			" enum values in %s", defaultVal.String(), key.String(), metadataName, displayName))
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// equalJSON returns whether two JSON documents hold the same values, regardless
+// of formatting and the order of keys
+func equalJSON(a []byte, b []byte) bool {
+	return reflect.DeepEqual(gjson.ParseBytes(a).Value(), gjson.ParseBytes(b).Value())
+}
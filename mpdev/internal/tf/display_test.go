@@ -0,0 +1,75 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDisplayEnums(t *testing.T) {
+	testcases := []struct {
+		name          string
+		displayFile   string
+		expectedEnums map[string][]string
+		errorContains string
+	}{{
+		name:        "Parse enum values of each variable",
+		displayFile: metadataDisplayWithEnumsDouble,
+		expectedEnums: map[string][]string{
+			"source_image": {
+				"projects/click-to-deploy-images/global/images/wordpress-1",
+				"projects/click-to-deploy-images/global/images/wordpress-2",
+			},
+			"another_image": {
+				"projects/click-to-deploy-images/global/images/wordpress-3",
+			},
+		},
+	}, {
+		name:          "Omit variables without enum values",
+		displayFile:   metadataDisplayNoEnums,
+		expectedEnums: map[string][]string{},
+	}, {
+		name:          "Invalid YAML shows parsing error",
+		displayFile:   "spec: [",
+		errorContains: "failure parsing metadata.display.yaml",
+	}, {
+		name:          "Fail when there is no display file",
+		errorContains: "no such file or directory",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			if tc.displayFile != "" {
+				err = os.WriteFile(path.Join(tmpDir, metadataDisplayFile), []byte(tc.displayFile), 0600)
+				assert.NoError(t, err)
+			}
+
+			enums, err := ParseDisplayEnums(tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedEnums, enums)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"errors"
+)
+
+// fileEdits collects the edits to Terraform files so that the files can be
+// rewritten concurrently. The edits to a single file are applied in the order
+// they were added.
+type fileEdits struct {
+	filenames []string
+	edits     map[string][]func(*OverwriteResult) error
+}
+
+func newFileEdits() *fileEdits {
+	return &fileEdits{edits: map[string][]func(*OverwriteResult) error{}}
+}
+
+func (e *fileEdits) add(filename string, edit func(*OverwriteResult) error) {
+	if _, ok := e.edits[filename]; !ok {
+		e.filenames = append(e.filenames, filename)
+	}
+	e.edits[filename] = append(e.edits[filename], edit)
+}
+
+// apply stages the edits of each file using at most GOMAXPROCS goroutines. The
+// errors of all files are returned in the order the files were first edited.
+func (e *fileEdits) apply(ctx context.Context, result *OverwriteResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	errs := make([]error, len(e.filenames))
+	runConcurrently(len(e.filenames), func(i int) {
+		for _, edit := range e.edits[e.filenames[i]] {
+			if err := edit(result); err != nil {
+				errs[i] = err
+				return
+			}
+		}
+	})
+
+	var fileErrs []error
+	for _, err := range errs {
+		if err != nil {
+			fileErrs = append(fileErrs, err)
+		}
+	}
+	if len(fileErrs) == 1 {
+		return fileErrs[0]
+	}
+	return errors.Join(fileErrs...)
+}
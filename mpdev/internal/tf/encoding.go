@@ -0,0 +1,69 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// checkModuleUTF8 returns an error wrapping ErrInvalidUTF8 naming the first
+// Terraform file of the module directories that is not valid UTF-8 text, if
+// RequireUTF8 is set
+func checkModuleUTF8(result *OverwriteResult, config *overwriteConfig, moduleDirs []string) error {
+	if !config.RequireUTF8 {
+		return nil
+	}
+
+	for _, moduleDir := range moduleDirs {
+		filenames, err := getModuleFilenames(result.fsys, moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, filename := range filenames {
+			b, err := result.readFile(filename)
+			if err != nil {
+				return err
+			}
+			err = checkUTF8(config, filename, b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkUTF8 returns an error wrapping ErrInvalidUTF8 if RequireUTF8 is set and
+// the contents of a file are not valid UTF-8 text. NUL bytes are rejected too,
+// since they only occur in binary files.
+func checkUTF8(config *overwriteConfig, filename string, b []byte) error {
+	if !config.RequireUTF8 {
+		return nil
+	}
+
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return fmt.Errorf("%w: %s contains a NUL byte at offset: %d", ErrInvalidUTF8, filename, i)
+	}
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("%w: %s contains an invalid byte at offset: %d", ErrInvalidUTF8, filename, i)
+		}
+		i += size
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteRequireUTF8(t *testing.T) {
+	binary := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	testcases := []struct {
+		name          string
+		files         map[string]string
+		requireUTF8   bool
+		errorContains string
+	}{{
+		name: "Overwrite valid UTF-8 files",
+		files: map[string]string{
+			"main.tf":               tfSourceImage + "# Überschreiben ✓\n",
+			"metadata.yaml":         metadata,
+			"metadata.display.yaml": metadataDisplayWithEnumsSingle,
+		},
+		requireUTF8: true,
+	}, {
+		name: "Fail on binary Terraform file",
+		files: map[string]string{
+			"main.tf":  tfSourceImage,
+			"image.tf": binary,
+		},
+		requireUTF8:   true,
+		errorContains: "image.tf contains a NUL byte at offset: 8",
+	}, {
+		name: "Fail on invalid UTF-8 Terraform file",
+		files: map[string]string{
+			"main.tf": tfSourceImage + "# \xff\xfe\n",
+		},
+		requireUTF8:   true,
+		errorContains: "main.tf contains an invalid byte at offset: 74",
+	}, {
+		name: "Fail on invalid UTF-8 metadata file",
+		files: map[string]string{
+			"main.tf":       tfSourceImage,
+			"metadata.yaml": metadata + "# \xc3\x28\n",
+		},
+		requireUTF8:   true,
+		errorContains: "metadata.yaml contains an invalid byte at offset:",
+	}, {
+		name: "Fail on binary metadata display file",
+		files: map[string]string{
+			"main.tf":               tfSourceImage,
+			"metadata.display.yaml": binary,
+		},
+		requireUTF8:   true,
+		errorContains: "metadata.display.yaml contains a NUL byte",
+	}, {
+		name: "Binary files fail to parse without the preflight",
+		files: map[string]string{
+			"main.tf":  tfSourceImage,
+			"image.tf": binary,
+		},
+		errorContains: "failure parsing terraform module",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			config := &overwriteConfig{
+				RequireUTF8: tc.requireUTF8,
+				Variables:   []string{"source_image"},
+				Replacements: map[string]string{
+					"old-image": "new-image",
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				},
+			}
+			_, err = OverwriteAll(config, tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.errorContains)
+			if tc.requireUTF8 {
+				assert.ErrorIs(t, err, ErrInvalidUTF8)
+			}
+
+			// No file is written
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.files, actualContents)
+		})
+	}
+}
+
+var tfSourceImage string = `
+variable "source_image" {
+  type    = string
+  default = "old-image"
+}
+`
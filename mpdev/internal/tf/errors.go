@@ -0,0 +1,172 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+var (
+	// ErrVariableNotFound is returned when a variable in the overwrite config
+	// is not declared in a file
+	ErrVariableNotFound = errors.New("variable not found")
+	// ErrMissingDefault is returned when a variable has no default value to
+	// replace
+	ErrMissingDefault = errors.New("missing default value")
+	// ErrWrongType is returned when a value does not have a type that can be
+	// overwritten
+	ErrWrongType = errors.New("wrong type")
+	// ErrReplacementNotFound is returned when a value is not found in the
+	// replacements
+	ErrReplacementNotFound = errors.New("replacement not found")
+	// ErrSensitiveVariable is returned when the default value of a variable
+	// marked sensitive would be overwritten
+	ErrSensitiveVariable = errors.New("sensitive variable")
+	// ErrUnexpectedValue is returned when the current value of a variable
+	// differs from its expected value
+	ErrUnexpectedValue = errors.New("unexpected value")
+	// ErrInvalidImageURI is returned when a replaced image value is not a
+	// well-formed GCE image URI
+	ErrInvalidImageURI = errors.New("invalid image URI")
+	// ErrNonLiteralDefault is returned when the default value of a variable is
+	// an expression, such as a conditional, that cannot be overwritten
+	ErrNonLiteralDefault = errors.New("non-literal default")
+	// ErrImageProjectMismatch is returned when an image enum value is not in
+	// the image project of its display variable
+	ErrImageProjectMismatch = errors.New("image project mismatch")
+	// ErrValidationFailed is returned when a new default fails a validation
+	// condition of its variable
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrWouldChange is returned if CheckOnly is set and the overwrite would
+	// change files
+	ErrWouldChange = errors.New("overwrite would change files")
+	// ErrLockTimeout is returned if Lock is set and the lock file of the
+	// module is not acquired within the LockTimeout
+	ErrLockTimeout = errors.New("lock timeout")
+	// ErrModifiedConcurrently is returned if Lock is set and a file was
+	// changed since it was read, such as by a concurrent overwrite. The
+	// overwrite can be retried.
+	ErrModifiedConcurrently = errors.New("file modified since it was read")
+	// ErrOutOfBounds is returned if a numeric display default does not lie
+	// within its min and max
+	ErrOutOfBounds = errors.New("out of bounds")
+	// ErrHookFailed is returned if the PreHook or PostHook fails or exits with
+	// a non-zero status
+	ErrHookFailed = errors.New("hook failed")
+	// ErrInvalidUTF8 is returned if RequireUTF8 is set and a Terraform or
+	// Blueprints Metadata file is not valid UTF-8 text
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+)
+
+// VariableError is an error concerning a single variable. It wraps one of the
+// sentinel errors above, so that callers can use errors.Is and errors.As
+// rather than matching on the message.
+type VariableError struct {
+	Variable string
+	Err      error
+
+	message error
+}
+
+func (e *VariableError) Error() string {
+	return e.message.Error()
+}
+
+func (e *VariableError) Unwrap() []error {
+	return []error{e.Err, e.message}
+}
+
+// newVariableError returns a VariableError wrapping err with the message
+// formatted by fmt.Errorf
+func newVariableError(err error, variable string, format string, args ...interface{}) error {
+	return &VariableError{
+		Variable: variable,
+		Err:      err,
+		message:  fmt.Errorf(format, args...),
+	}
+}
+
+// ParseError is returned when a Terraform file cannot be parsed. Unlike the
+// message of hcl.Diagnostics, which only describes the first diagnostic, its
+// message lists the file, line, column and summary of every error.
+type ParseError struct {
+	Diagnostics hcl.Diagnostics
+}
+
+func (e *ParseError) Error() string {
+	var messages []string
+	for _, diag := range e.Diagnostics {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		message := diag.Summary
+		if diag.Detail != "" {
+			message += "; " + diag.Detail
+		}
+		if diag.Subject != nil {
+			message = fmt.Sprintf("%s: %s", formatPos(*diag.Subject), message)
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Diagnostics
+}
+
+// newParseError returns a ParseError for the diagnostics of a parse
+func newParseError(diags hcl.Diagnostics) error {
+	return &ParseError{Diagnostics: diags}
+}
+
+// newModuleParseError returns a ParseError for the diagnostics of
+// tfconfig.LoadModule. tfconfig only keeps the line of each problem, so the
+// column is left out.
+func newModuleParseError(diags tfconfig.Diagnostics) error {
+	var hclDiags hcl.Diagnostics
+	for _, diag := range diags {
+		hclDiag := &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+		if diag.Severity == tfconfig.DiagError {
+			hclDiag.Severity = hcl.DiagError
+		}
+		if diag.Pos != nil {
+			hclDiag.Subject = &hcl.Range{
+				Filename: diag.Pos.Filename,
+				Start:    hcl.Pos{Line: diag.Pos.Line},
+			}
+		}
+		hclDiags = append(hclDiags, hclDiag)
+	}
+	return newParseError(hclDiags)
+}
+
+// formatPos returns the `file:line:column` of the start of a range, leaving
+// out the column if it is unknown
+func formatPos(rng hcl.Range) string {
+	if rng.Start.Column == 0 {
+		return fmt.Sprintf("%s:%d", rng.Filename, rng.Start.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", rng.Filename, rng.Start.Line, rng.Start.Column)
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariableErrors(t *testing.T) {
+	testcases := []struct {
+		name             string
+		files            map[string]string
+		overwriteConfig  overwriteConfig
+		expectedErr      error
+		expectedVariable string
+		expectedMessage  string
+	}{{
+		name:  "Variable not found",
+		files: map[string]string{"main.tf": mainTf},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"missing_variable"},
+		},
+		expectedErr:      ErrVariableNotFound,
+		expectedVariable: "missing_variable",
+		expectedMessage:  "variable: missing_variable not found in module.",
+	}, {
+		name:  "Missing default",
+		files: map[string]string{"main.tf": tfNoDefault},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+		},
+		expectedErr:      ErrMissingDefault,
+		expectedVariable: "value_to_replace",
+		expectedMessage:  "image variable: value_to_replace must have default value",
+	}, {
+		name:  "Wrong type",
+		files: map[string]string{"main.tf": tfDefaultWrongType},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+		},
+		expectedErr:      ErrWrongType,
+		expectedVariable: "value_to_replace",
+		expectedMessage:  "image variable: value_to_replace must be type string",
+	}, {
+		name:  "Replacement not found",
+		files: map[string]string{"main.tf": mainTf},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+		},
+		expectedErr:      ErrReplacementNotFound,
+		expectedVariable: "value_to_replace",
+		expectedMessage:  "default value: original-value of variable: value_to_replace not found in replacements",
+	}, {
+		name: "Replacement not found in metadata",
+		files: map[string]string{
+			"main.tf":       tfMetadataImages,
+			"metadata.yaml": metadata,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"tf-image": "new-tf-image",
+			},
+		},
+		expectedErr:      ErrReplacementNotFound,
+		expectedVariable: "source_image",
+		expectedMessage:  "default value: old-image of variable: source_image in metadata.yaml not found in replacements",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteAll(&tc.overwriteConfig, tmpDir)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.ErrorContains(t, err, tc.expectedMessage)
+
+			var variableErr *VariableError
+			assert.True(t, errors.As(err, &variableErr))
+			assert.Equal(t, tc.expectedVariable, variableErr.Variable)
+		})
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	testcases := []struct {
+		name             string
+		collectErrors    bool
+		expectedErrs     []error
+		unexpectedErrs   []error
+		expectedMessages []string
+	}{{
+		name:          "Collect every problem",
+		collectErrors: true,
+		expectedErrs: []error{
+			ErrVariableNotFound, ErrMissingDefault, ErrWrongType, ErrReplacementNotFound,
+		},
+		expectedMessages: []string{
+			"variable: missing_variable not found in module.",
+			"image variable: no_default must have default value",
+			"image variable: disk_size must be type string",
+			"default value: other-image of variable: other_image not found in replacements",
+		},
+	}, {
+		name:             "Fail fast by default",
+		expectedErrs:     []error{ErrVariableNotFound},
+		unexpectedErrs:   []error{ErrMissingDefault, ErrWrongType, ErrReplacementNotFound},
+		expectedMessages: []string{"variable: missing_variable not found in module."},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(tfValidateConfig), 0600)
+			assert.NoError(t, err)
+
+			_, err = OverwriteTf(&overwriteConfig{
+				CollectErrors: tc.collectErrors,
+				Variables:     []string{"source_image", "missing_variable", "no_default", "disk_size", "other_image"},
+				Replacements: map[string]string{
+					"old-image": "new-image",
+				},
+			}, tmpDir)
+			for _, expectedErr := range tc.expectedErrs {
+				assert.ErrorIs(t, err, expectedErr)
+			}
+			for _, unexpectedErr := range tc.unexpectedErrs {
+				assert.NotErrorIs(t, err, unexpectedErr)
+			}
+			for _, message := range tc.expectedMessages {
+				assert.ErrorContains(t, err, message)
+			}
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"main.tf": tfValidateConfig}, actualContents)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []struct {
+		name             string
+		files            map[string]string
+		expectedMessages []string
+	}{{
+		name: "Invalid block",
+		files: map[string]string{
+			"main.tf": "this is broken",
+		},
+		expectedMessages: []string{
+			"failure parsing terraform module",
+			"main.tf:1:15: Invalid block definition",
+		},
+	}, {
+		name: "Every error is listed",
+		files: map[string]string{
+			"main.tf":      "this is broken",
+			"variables.tf": tfParseError,
+		},
+		expectedMessages: []string{
+			"main.tf:1:15: Invalid block definition",
+			"variables.tf:3:17: Missing newline after argument",
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteTf(&overwriteConfig{Variables: []string{"value_to_replace"}}, tmpDir)
+			for _, message := range tc.expectedMessages {
+				assert.ErrorContains(t, err, message)
+			}
+
+			var parseErr *ParseError
+			assert.True(t, errors.As(err, &parseErr))
+		})
+	}
+}
+
+var tfParseError string = `
+variable "project_id" {
+  type = string string
+}
+`
+
+var tfMetadataImages string = `
+variable "source_image" {
+  type    = string
+  default = "tf-image"
+}
+`
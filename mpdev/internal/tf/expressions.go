@@ -0,0 +1,209 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var variableBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+}
+
+var variableDefaultSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "default"}},
+}
+
+// getNonLiteralDefaults returns the ranges of the variable defaults in a file
+// that cannot be evaluated without a context, such as conditionals or function
+// calls, by variable name
+func getNonLiteralDefaults(file *hcl.File) map[string]hcl.Range {
+	ranges := map[string]hcl.Range{}
+	content, _, _ := file.Body.PartialContent(variableBlockSchema)
+	if content == nil {
+		return ranges
+	}
+	for _, block := range content.Blocks {
+		attributes, _, _ := block.Body.PartialContent(variableDefaultSchema)
+		if attributes == nil {
+			continue
+		}
+		attribute, ok := attributes.Attributes["default"]
+		if !ok {
+			continue
+		}
+		_, diag := attribute.Expr.Value(nil)
+		if diag.HasErrors() {
+			ranges[block.Labels[0]] = attribute.Expr.Range()
+		}
+	}
+	return ranges
+}
+
+// withoutDiagnosticsIn returns the diagnostics whose subject is not within any
+// of the ranges
+func withoutDiagnosticsIn(diags hcl.Diagnostics, ranges map[string]hcl.Range) hcl.Diagnostics {
+	var filtered hcl.Diagnostics
+	for _, diag := range diags {
+		within := false
+		for _, r := range ranges {
+			if diag.Subject != nil && diag.Subject.Filename == r.Filename &&
+				diag.Subject.Start.Byte >= r.Start.Byte && diag.Subject.End.Byte <= r.End.Byte {
+				within = true
+				break
+			}
+		}
+		if !within {
+			filtered = append(filtered, diag)
+		}
+	}
+	return filtered
+}
+
+// stringLiteral is a quoted string literal within an expression
+type stringLiteral struct {
+	Value string
+	Range hcl.Range
+}
+
+// getDefaultStringLiterals returns the quoted string literals within the
+// default expression of a variable, in the order they appear
+func getDefaultStringLiterals(b []byte, filename string, varname string) ([]stringLiteral, error) {
+	file, diag := hclsyntax.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return nil, diag
+	}
+
+	var attribute *hclsyntax.Attribute
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type == "variable" && len(block.Labels) == 1 && block.Labels[0] == varname {
+			attribute = block.Body.Attributes["default"]
+			break
+		}
+	}
+	if attribute == nil {
+		return nil, fmt.Errorf("did not find default value of variable: %s", varname)
+	}
+
+	var literals []stringLiteral
+	hclsyntax.VisitAll(attribute.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		template, ok := node.(*hclsyntax.TemplateExpr)
+		if !ok || !template.IsStringLiteral() || b[template.SrcRange.Start.Byte] != '"' {
+			return nil
+		}
+		value, diag := template.Value(nil)
+		if diag.HasErrors() || value.Type() != cty.String || value.IsNull() {
+			return nil
+		}
+		literals = append(literals, stringLiteral{Value: value.AsString(), Range: template.SrcRange})
+		return nil
+	})
+	return literals, nil
+}
+
+// overwriteNonLiteralValue replaces the string literals with a replacement
+// within a default expression that is not a literal, such as
+// `var.use_beta ? "beta-image" : "stable-image"`. The rest of the expression is
+// left as is. It is an error if no literal has a replacement, unless all of
+// them were already replaced by a previous run.
+func overwriteNonLiteralValue(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, moduleVal *moduleValue) error {
+	nonLiteralErr := newVariableError(ErrNonLiteralDefault, moduleVal.Name,
+		"variable: %s has a non-literal default, cannot overwrite", moduleVal.Name)
+	if isTfJSONFile(moduleVal.Filename) {
+		return nonLiteralErr
+	}
+
+	b, err := result.readFile(moduleVal.Filename)
+	if err != nil {
+		return err
+	}
+	literals, err := getDefaultStringLiterals(b, moduleVal.Filename, moduleVal.Name)
+	if err != nil {
+		return err
+	}
+
+	replacements := map[string]string{}
+	var changes []VariableChange
+	alreadyReplaced := false
+	for _, literal := range literals {
+		replaceVal, ok, err := config.replacementFor(moduleVal.Name, literal.Value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			alreadyReplaced = alreadyReplaced || config.isReplacementTarget(literal.Value)
+			continue
+		}
+		replacements[literal.Value] = replaceVal
+		changes = append(changes, VariableChange{
+			File:       moduleVal.Filename,
+			Variable:   moduleVal.Name,
+			OldDefault: literal.Value,
+			NewDefault: replaceVal,
+		})
+	}
+	if len(replacements) == 0 && alreadyReplaced {
+		report.Skipped = append(report.Skipped, moduleVal.Name)
+		return nil
+	}
+	if len(replacements) == 0 {
+		return nonLiteralErr
+	}
+
+	edits.add(moduleVal.Filename, func(result *OverwriteResult) error {
+		return overwriteDefaultLiteralsFile(result, moduleVal.Filename, moduleVal.Name, replacements)
+	})
+	report.Changes = append(report.Changes, changes...)
+	return nil
+}
+
+// overwriteDefaultLiteralsFile replaces the string literals within the default
+// expression of a variable that have an entry in replacements. Only the bytes
+// of the replaced literals are rewritten.
+func overwriteDefaultLiteralsFile(result *OverwriteResult, filename string, varname string,
+	replacements map[string]string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	literals, err := getDefaultStringLiterals(b, filename, varname)
+	if err != nil {
+		return err
+	}
+
+	// Splice from the end of the file so that earlier offsets stay valid
+	sort.Slice(literals, func(i, j int) bool {
+		return literals[i].Range.Start.Byte > literals[j].Range.Start.Byte
+	})
+	proposed := b
+	for _, literal := range literals {
+		replaceVal, ok := replacements[literal.Value]
+		if !ok {
+			continue
+		}
+		valueBytes := hclwrite.TokensForValue(cty.StringVal(replaceVal)).Bytes()
+		proposed = spliceBytes(proposed, literal.Range.Start.Byte, literal.Range.End.Byte, valueBytes)
+	}
+
+	result.stageFile(filename, b, proposed)
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// moduleFS reads the files of a module. Overwrites read from disk, or from
+// memory for OverwriteTfFiles and OverwriteAllFiles.
+type moduleFS interface {
+	ReadFile(filename string) ([]byte, error)
+	ReadDir(dir string) ([]fs.DirEntry, error)
+	Stat(filename string) (fs.FileInfo, error)
+}
+
+// osFS reads files from disk
+type osFS struct{}
+
+func (osFS) ReadFile(filename string) ([]byte, error) {
+	return os.ReadFile(filename)
+}
+
+func (osFS) ReadDir(dir string) ([]fs.DirEntry, error) {
+	return os.ReadDir(dir)
+}
+
+func (osFS) Stat(filename string) (fs.FileInfo, error) {
+	return os.Stat(filename)
+}
+
+// memoryFS holds the files of a module in memory keyed by cleaned filename.
+// Directories are implied by the filenames.
+type memoryFS map[string][]byte
+
+func newMemoryFS(files map[string][]byte) memoryFS {
+	m := memoryFS{}
+	for filename, contents := range files {
+		m[path.Clean(filepath.ToSlash(filename))] = contents
+	}
+	return m
+}
+
+// ReadFile returns a copy of the contents of a file, so that staged changes
+// never modify the caller's files
+func (m memoryFS) ReadFile(filename string) ([]byte, error) {
+	contents, ok := m[path.Clean(filepath.ToSlash(filename))]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: filename, Err: fs.ErrNotExist}
+	}
+	return append([]byte{}, contents...), nil
+}
+
+// Stat returns the info of a file. Files in memory have no modification time.
+func (m memoryFS) Stat(filename string) (fs.FileInfo, error) {
+	clean := path.Clean(filepath.ToSlash(filename))
+	if _, ok := m[clean]; !ok {
+		return nil, &fs.PathError{Op: "stat", Path: filename, Err: fs.ErrNotExist}
+	}
+	return memoryDirEntry{name: path.Base(clean)}, nil
+}
+
+// ReadDir returns the files and directories directly within dir, sorted by
+// name
+func (m memoryFS) ReadDir(dir string) ([]fs.DirEntry, error) {
+	dir = path.Clean(filepath.ToSlash(dir))
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+
+	entries := map[string]memoryDirEntry{}
+	for filename := range m {
+		if !strings.HasPrefix(filename, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(filename, prefix)
+		if i := strings.Index(name, "/"); i >= 0 {
+			entries[name[:i]] = memoryDirEntry{name: name[:i], dir: true}
+		} else {
+			entries[name] = memoryDirEntry{name: name}
+		}
+	}
+	if len(entries) == 0 && dir != "." {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	var sorted []fs.DirEntry
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name() < sorted[j].Name()
+	})
+	return sorted, nil
+}
+
+// memoryDirEntry is a file or directory of a memoryFS. It is its own FileInfo.
+type memoryDirEntry struct {
+	name string
+	dir  bool
+}
+
+func (e memoryDirEntry) Name() string               { return e.name }
+func (e memoryDirEntry) IsDir() bool                { return e.dir }
+func (e memoryDirEntry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e memoryDirEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e memoryDirEntry) Size() int64                { return 0 }
+func (e memoryDirEntry) ModTime() time.Time         { return time.Time{} }
+func (e memoryDirEntry) Sys() interface{}           { return nil }
+
+func (e memoryDirEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// OverwriteTfFiles is like OverwriteTf, but reads the module from files, which
+// maps filenames relative to the module root to their contents, instead of
+// from disk. It returns the contents of every file with the overwrites applied,
+// keyed by cleaned filename. Nothing is written and files is not modified.
+func OverwriteTfFiles(config *overwriteConfig, files map[string][]byte) (map[string][]byte, error) {
+	return overwriteFiles(files, func(result *OverwriteResult) error {
+		return stageTf(context.Background(), result, &ChangeReport{}, config, ".")
+	})
+}
+
+// OverwriteAllFiles is like OverwriteAll, but reads the module from files
+// instead of from disk. See OverwriteTfFiles.
+func OverwriteAllFiles(config *overwriteConfig, files map[string][]byte) (map[string][]byte, error) {
+	return overwriteFiles(files, func(result *OverwriteResult) error {
+		return stageAll(context.Background(), result, config, ".")
+	})
+}
+
+// overwriteFiles stages an overwrite of the in-memory module files and returns
+// the files with the proposed contents applied
+func overwriteFiles(files map[string][]byte, stage func(*OverwriteResult) error) (map[string][]byte, error) {
+	fsys := newMemoryFS(files)
+	result := newOverwriteResult()
+	result.fsys = fsys
+
+	err := stage(result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := map[string][]byte{}
+	for filename, contents := range fsys {
+		rewritten[filename] = contents
+	}
+	for filename, change := range result.Files {
+		rewritten[path.Clean(filepath.ToSlash(filename))] = change.Proposed
+	}
+	return rewritten, nil
+}
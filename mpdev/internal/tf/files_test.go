@@ -0,0 +1,131 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteTfFiles(t *testing.T) {
+	config := overwriteConfig{
+		Recursive: true,
+		Variables: []string{"value_to_replace", "other_value_to_replace"},
+		Replacements: map[string]string{
+			"original-value": "new-value",
+			"old-value":      "newer-value",
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		files         map[string]string
+		expectedFiles map[string]string
+		errorContains string
+	}{{
+		name: "Overwrite nested modules, leaving other files untouched",
+		files: map[string]string{
+			"main.tf":            mainTf,
+			"./modules/db/db.tf": mainTf,
+			"README.md":          "# Module\n",
+		},
+		expectedFiles: map[string]string{
+			"main.tf":          mainTfReplaced,
+			"modules/db/db.tf": mainTfReplaced,
+			"README.md":        "# Module\n",
+		},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		files: map[string]string{
+			"main.tf": "this is broken",
+		},
+		errorContains: "failure parsing terraform module",
+	}, {
+		name: "Fail when there are no terraform files",
+		files: map[string]string{
+			"README.md": "# Module\n",
+		},
+		errorContains: "no terraform files found in .",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string][]byte{}
+			for filename, content := range tc.files {
+				files[filename] = []byte(content)
+			}
+
+			rewritten, err := OverwriteTfFiles(&config, files)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				actualFiles := map[string]string{}
+				for filename, content := range rewritten {
+					actualFiles[filename] = string(content)
+				}
+				assert.Equal(t, tc.expectedFiles, actualFiles)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+
+			// The files passed in are never modified
+			for filename, content := range tc.files {
+				assert.Equal(t, content, string(files[filename]))
+			}
+		})
+	}
+}
+
+func TestOverwriteAllFiles(t *testing.T) {
+	config := overwriteConfig{
+		NewValues: map[string]string{
+			"source_image":  "new-image",
+			"another_image": "newer-image",
+		},
+	}
+	files := map[string][]byte{
+		"main.tf":       []byte(tfImages),
+		"metadata.yaml": []byte(metadata),
+	}
+
+	rewritten, err := OverwriteAllFiles(&config, files)
+	assert.NoError(t, err)
+	assert.Equal(t, tfImagesReplaced, string(rewritten["main.tf"]))
+	assert.Equal(t, metadataReplaced, string(rewritten["metadata.yaml"]))
+	assert.Equal(t, metadata, string(files["metadata.yaml"]))
+}
+
+var tfImages string = `
+variable "source_image" {
+  type    = string
+  default = "old-image"
+}
+
+variable "another_image" {
+  type    = string
+  default = "older-image"
+}
+`
+
+var tfImagesReplaced string = `
+variable "source_image" {
+  type    = string
+  default = "new-image"
+}
+
+variable "another_image" {
+  type    = string
+  default = "newer-image"
+}
+`
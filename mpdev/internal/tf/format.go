@@ -0,0 +1,54 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// FormatModule rewrites the Terraform files in dir in the canonical HCL
+// format, like `terraform fmt`, without overwriting any values. Files that
+// are already formatted are left untouched. Files that do not parse are an
+// error, and no files are written in that case.
+func FormatModule(dir string) error {
+	result := newOverwriteResult()
+	filenames, err := getTfFilenames(result.fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		b, err := result.readFile(filename)
+		if err != nil {
+			return err
+		}
+		_, diag := hclsyntax.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+		if diag.HasErrors() {
+			return fmt.Errorf("failure parsing %s error: %w", filename, newParseError(diag))
+		}
+
+		formatted := hclwrite.Format(b)
+		if string(formatted) != string(b) {
+			fmt.Printf("Formatting %s\n", filename)
+			result.stageFile(filename, b, formatted)
+		}
+	}
+
+	return result.commit(&overwriteConfig{}, dir)
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatModule(t *testing.T) {
+	testcases := []struct {
+		name            string
+		tfFiles         map[string]string
+		expectedTfFiles map[string]string
+		errorContains   string
+	}{{
+		name: "Format unformatted files",
+		tfFiles: map[string]string{
+			"main.tf":      tfUnformatted,
+			"variables.tf": mainTf,
+			"README.md":    "variable  =  \"not terraform\"\n",
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf":      tfFormatted,
+			"variables.tf": mainTf,
+			"README.md":    "variable  =  \"not terraform\"\n",
+		},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		tfFiles: map[string]string{
+			"main.tf":  tfUnformatted,
+			"other.tf": "this is broken",
+		},
+		errorContains: "failure parsing",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.tfFiles {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			err = FormatModule(tmpDir)
+
+			actualContents, contentsErr := getDirContents(tmpDir)
+			assert.NoError(t, contentsErr)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedTfFiles, actualContents)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+				assert.Equal(t, tc.tfFiles, actualContents)
+			}
+		})
+	}
+}
+
+var tfUnformatted string = `
+variable "source_image" {
+    type = string
+  default =   "old-image" # the image
+  description="The image"
+}
+`
+
+var tfFormatted string = `
+variable "source_image" {
+  type        = string
+  default     = "old-image" # the image
+  description = "The image"
+}
+`
@@ -0,0 +1,142 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// malformedMetadata are valid YAML documents with unexpected shapes of
+// Blueprints Metadata
+var malformedMetadata = []string{
+	"",
+	"null",
+	"[]",
+	"spec: []",
+	"spec:\n  interfaces: value",
+	"spec:\n  interfaces:\n    variables:\n      source_image: old-image",
+	"spec:\n  interfaces:\n    variables: old-image",
+	"spec:\n  interfaces:\n    variables:\n    - old-image\n    - [source_image]",
+	"spec:\n  interfaces:\n    variables:\n    - name: [source_image]\n      defaultValue: old-image",
+	"spec:\n  interfaces:\n    variables:\n    - name: source_image\n      defaultValue: {image: old-image}",
+	"spec:\n  interfaces:\n    variables:\n    - name: source_image\n      defaultValue: [old-image]\n      varType: list",
+	"spec:\n  interfaces:\n    variables:\n    - name: source_image\n    - name: source_image\n      defaultValue: old-image",
+	"spec:\n  interfaces:\n    variables:\n    - &entry\n      name: source_image\n      defaultValue: old-image\n    - *entry",
+	"spec:\n  interfaces:\n    variables: {name: source_image, defaultValue: old-image}\n    outputs: {name: out}",
+	"--- a\n--- b",
+}
+
+// malformedDisplay are valid YAML documents with unexpected shapes of
+// Blueprints Metadata display files
+var malformedDisplay = []string{
+	"",
+	"spec:\n  ui: []",
+	"spec:\n  ui:\n    input:\n      variables: [source_image]",
+	"spec:\n  ui:\n    input:\n      variables:\n        source_image: old-image",
+	"spec:\n  ui:\n    input:\n      variables:\n        source_image:\n          enumValueLabels: old-image",
+	"spec:\n  ui:\n    input:\n      variables:\n        source_image:\n          enumValueLabels:\n          - old-image\n          - value: [old-image]",
+	"spec:\n  ui:\n    input:\n      variables:\n        source_image:\n          xGoogleProperty: [ET_GCE_DISK_IMAGE]",
+	"spec:\n  ui:\n    input:\n      variables:\n        source_image:\n          xGoogleProperty:\n            type: ET_GCE_DISK_IMAGE\n            imageProject: [old-project]",
+}
+
+// fuzzConfig returns a config exercising most of the metadata and display
+// overwrites
+func fuzzConfig(newValues bool) *overwriteConfig {
+	config := &overwriteConfig{
+		Variables:         []string{"source_image"},
+		Replacements:      map[string]string{"old-image": "new-image"},
+		Descriptions:      map[string]string{"source_image": "The image."},
+		VarTypes:          map[string]string{"source_image": "string"},
+		Outputs:           []string{"out"},
+		MetadataFields:    map[string]string{"spec.info.version": "1.0.0"},
+		DisplayTitles:     map[string]string{"source_image": "Image"},
+		DisplayProperties: map[string]map[string]interface{}{"source_image": {"required": true}},
+		DisplayNumbers:    map[string]map[string]float64{"source_image": {"default": 1}},
+		ImageProjects:     map[string]string{"old-project": "new-project"},
+		AddMissing:        true,
+		ValidateImageURIs: true,
+	}
+	if newValues {
+		config.NewValues = map[string]string{"source_image": "new-image", "added_image": "new-image"}
+	}
+	return config
+}
+
+func TestOverwriteMalformedMetadata(t *testing.T) {
+	for _, data := range malformedMetadata {
+		t.Run(data, func(t *testing.T) {
+			result := newOverwriteResult()
+			result.fsys = newMemoryFS(map[string][]byte{metadataFile: []byte(data)})
+			var err error
+			assert.NotPanics(t, func() {
+				err = stageMetadata(context.Background(), result, fuzzConfig(true), ".")
+			})
+			assert.Error(t, err)
+		})
+	}
+
+	for _, data := range malformedDisplay {
+		t.Run(data, func(t *testing.T) {
+			result := newOverwriteResult()
+			result.fsys = newMemoryFS(map[string][]byte{metadataDisplayFile: []byte(data)})
+			var err error
+			assert.NotPanics(t, func() {
+				err = stageDisplay(context.Background(), result, fuzzConfig(false), ".")
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+// FuzzOverwriteMetadata checks that overwriting malformed Blueprints Metadata
+// returns an error rather than panicking
+func FuzzOverwriteMetadata(f *testing.F) {
+	for _, seed := range append(malformedMetadata, metadata, metadataWithComments, metadataWithAPIVersion) {
+		f.Add(seed, true)
+		f.Add(seed, false)
+	}
+
+	f.Fuzz(func(t *testing.T, data string, newValues bool) {
+		result := newOverwriteResult()
+		result.fsys = newMemoryFS(map[string][]byte{metadataFile: []byte(data)})
+		// Only panics fail the test
+		_ = stageMetadata(context.Background(), result, fuzzConfig(newValues), ".")
+		_, _, _ = removeMetadataVariableField([]byte(data), "defaultValue")
+		_, _ = getMetadataVariables([]byte(data))
+	})
+}
+
+// FuzzOverwriteDisplay checks that overwriting malformed Blueprints Metadata
+// display files returns an error rather than panicking
+func FuzzOverwriteDisplay(f *testing.F) {
+	for _, seed := range append(malformedDisplay, metadataDisplayWithEnumsSingle, metadataDisplayWithNumbers) {
+		f.Add(seed, true)
+		f.Add(seed, false)
+	}
+
+	f.Fuzz(func(t *testing.T, data string, newValues bool) {
+		result := newOverwriteResult()
+		result.fsys = newMemoryFS(map[string][]byte{
+			metadataFile:        []byte(metadata),
+			metadataDisplayFile: []byte(data),
+		})
+		config := fuzzConfig(newValues)
+		_ = stageDisplay(context.Background(), result, config, ".")
+		_ = validateEnums(result, config, ".")
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const helmValuesFile = "chart/values.yaml"
+
+// OverwriteValuesYaml replaces values in the Helm values files of dir, which
+// default to chart/values.yaml. Values are addressed by dotted key paths such
+// as `image.repository`. If NewValues is set, the value at each key path is set
+// to the new value. Otherwise, the values at the key paths in Variables are
+// replaced using Replacements. Key paths not found in a values file, or whose
+// value is not a scalar, are skipped, so that the same config can name
+// Terraform variables, unless Strict is set.
+func OverwriteValuesYaml(config *overwriteConfig, dir string) error {
+	err := config.runPreHook(dir)
+	if err != nil {
+		return err
+	}
+
+	result := newOverwriteResult()
+	err = stageValuesYaml(result, config, dir)
+	if err != nil {
+		return err
+	}
+
+	return result.commit(config, dir)
+}
+
+func stageValuesYaml(result *OverwriteResult, config *overwriteConfig, dir string) error {
+	for _, name := range config.valuesFilenames() {
+		filename := filepath.Join(dir, name)
+		_, err := result.readFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("No %s found in %s. Skipping Helm values\n", name, dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = stageValuesYamlFile(result, config, filename)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stageValuesYamlFile(result *OverwriteResult, config *overwriteConfig, filename string) error {
+	if config.NewValues != nil {
+		fmt.Printf("Replacing the values of the key paths: %s in %s\n", config.NewValues, filename)
+
+		for _, keyPath := range sortedKeys(config.NewValues) {
+			err := overwriteValuesYamlKey(result, config, filename, keyPath,
+				func(string) (string, error) {
+					return config.NewValues[keyPath], nil
+				})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Printf("Replacing the values of the key paths: %s in %s\n", config.Variables, filename)
+
+	for _, keyPath := range config.Variables {
+		err := overwriteValuesYamlKey(result, config, filename, keyPath,
+			func(currValue string) (string, error) {
+				replaceVal, ok, err := config.replacementFor(keyPath, currValue)
+				if err != nil {
+					return "", err
+				}
+				if !ok && config.isReplacementTarget(currValue) {
+					// Already replaced by a previous run
+					return currValue, nil
+				}
+				if !ok {
+					return "", newVariableError(ErrReplacementNotFound, keyPath,
+						"value: %s of key path: %s in %s not found in replacements",
+						currValue, keyPath, filename)
+				}
+				return replaceVal, nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overwriteValuesYamlKey sets the scalar at a key path of a values file to the
+// value returned by newValue for the current value. As with the metadata
+// files, only the bytes of the value are replaced where possible.
+func overwriteValuesYamlKey(result *OverwriteResult, config *overwriteConfig, filename string,
+	keyPath string, newValue func(string) (string, error)) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	var root yaml.Node
+	err = yaml.Unmarshal(b, &root)
+	if err != nil {
+		return fmt.Errorf("failure parsing %s error: %w", filename, err)
+	}
+
+	node := findYAMLPathNode(&root, strings.Split(keyPath, "."))
+	if node == nil {
+		if config.Strict {
+			return newVariableError(ErrVariableNotFound, keyPath,
+				"key path: %s not found in %s", keyPath, filename)
+		}
+		fmt.Printf("Key path: %s not found in %s. Skipping\n", keyPath, filename)
+		return nil
+	}
+	if node.Kind != yaml.ScalarNode {
+		if config.Strict {
+			return newVariableError(ErrWrongType, keyPath,
+				"value of key path: %s in %s must be a scalar", keyPath, filename)
+		}
+		fmt.Printf("Value of key path: %s in %s is not a scalar. Skipping\n", keyPath, filename)
+		return nil
+	}
+
+	value, err := newValue(node.Value)
+	if err != nil {
+		return err
+	}
+	if value == node.Value {
+		return nil
+	}
+
+	proposed, err := setYAMLScalar(b, &root, node, value)
+	if err != nil {
+		return err
+	}
+	result.stageFile(filename, b, proposed)
+	return nil
+}
+
+// valuesFilenames returns the ValuesFiles, or the default Helm values file if
+// none are set
+func (config *overwriteConfig) valuesFilenames() []string {
+	if len(config.ValuesFiles) == 0 {
+		return []string{helmValuesFile}
+	}
+	return config.ValuesFiles
+}
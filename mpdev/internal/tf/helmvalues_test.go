@@ -0,0 +1,201 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteValuesYaml(t *testing.T) {
+	testcases := []struct {
+		name            string
+		files           map[string]string
+		expectedFiles   map[string]string
+		overwriteConfig overwriteConfig
+		errorContains   string
+	}{{
+		name: "Overwrite Helm values by key path",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		expectedFiles: map[string]string{
+			"chart/values.yaml": helmValuesReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"image.repository":         "gcr.io/mpi/app",
+				"sidecar.image.repository": "gcr.io/mpi/sidecar",
+				"source_image":             "projects/mpi/global/images/web-1",
+			},
+		},
+	}, {
+		name: "Overwrite Helm values by replacement",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		expectedFiles: map[string]string{
+			"chart/values.yaml": helmValuesReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"image.repository", "sidecar.image.repository"},
+			Replacements: map[string]string{
+				"gcr.io/partner/app":     "gcr.io/mpi/app",
+				"gcr.io/partner/sidecar": "gcr.io/mpi/sidecar",
+			},
+		},
+	}, {
+		name: "Re-running overwrite on replaced Helm values is a no-op",
+		files: map[string]string{
+			"chart/values.yaml": helmValuesReplaced,
+		},
+		expectedFiles: map[string]string{
+			"chart/values.yaml": helmValuesReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"image.repository"},
+			Replacements: map[string]string{
+				"gcr.io/partner/app": "gcr.io/mpi/app",
+			},
+		},
+	}, {
+		name: "Overwrite custom Helm values files",
+		files: map[string]string{
+			"values.yaml": helmValues,
+		},
+		expectedFiles: map[string]string{
+			"values.yaml": helmValuesReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			ValuesFiles: []string{"values.yaml"},
+			NewValues: map[string]string{
+				"image.repository":         "gcr.io/mpi/app",
+				"sidecar.image.repository": "gcr.io/mpi/sidecar",
+			},
+		},
+	}, {
+		name:          "Skip missing Helm values file",
+		files:         map[string]string{},
+		expectedFiles: map[string]string{},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"image.repository": "gcr.io/mpi/app",
+			},
+		},
+	}, {
+		name: "Fail when key path is not found with Strict",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		overwriteConfig: overwriteConfig{
+			Strict: true,
+			NewValues: map[string]string{
+				"image.missing": "new-value",
+			},
+		},
+		errorContains: "key path: image.missing not found in",
+	}, {
+		name: "Skip Terraform variable whose key path is not a scalar",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		expectedFiles: map[string]string{
+			"chart/values.yaml": helmValuesReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"image":                    "projects/mpi/global/images/web-1",
+				"image.repository":         "gcr.io/mpi/app",
+				"sidecar.image.repository": "gcr.io/mpi/sidecar",
+				"source_image":             "projects/mpi/global/images/web-1",
+			},
+		},
+	}, {
+		name: "Fail when key path is not a scalar with Strict",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		overwriteConfig: overwriteConfig{
+			Strict: true,
+			NewValues: map[string]string{
+				"image": "new-value",
+			},
+		},
+		errorContains: "value of key path: image in",
+	}, {
+		name: "Fail when Helm value is not in replacements",
+		files: map[string]string{
+			"chart/values.yaml": helmValues,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"image.repository"},
+			Replacements: map[string]string{
+				"non-existent": "new-value",
+			},
+		},
+		errorContains: "value: gcr.io/partner/app of key path: image.repository in",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.MkdirAll(filepath.Dir(path.Join(tmpDir, file)), 0700)
+				assert.NoError(t, err)
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			err = OverwriteValuesYaml(&tc.overwriteConfig, tmpDir)
+
+			actualFiles, dirErr := getDirContents(tmpDir)
+			assert.NoError(t, dirErr)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedFiles, actualFiles)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+				assert.Equal(t, tc.files, actualFiles)
+			}
+		})
+	}
+}
+
+var helmValues string = `# Default values for the chart
+image:
+  repository: gcr.io/partner/app # pinned by release
+  tag: "1.2.3"
+sidecar:
+  image:
+    repository: "gcr.io/partner/sidecar"
+replicas: 2
+`
+
+var helmValuesReplaced string = `# Default values for the chart
+image:
+  repository: gcr.io/mpi/app # pinned by release
+  tag: "1.2.3"
+sidecar:
+  image:
+    repository: "gcr.io/mpi/sidecar"
+replicas: 2
+`
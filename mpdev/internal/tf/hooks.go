@@ -0,0 +1,62 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runPreHook runs the PreHook in dir before the files of an overwrite are read.
+// The hook does not run if DryRun or CheckOnly is set, since it may change
+// files.
+func (config *overwriteConfig) runPreHook(dir string) error {
+	if config.DryRun || config.CheckOnly {
+		return nil
+	}
+	return runHook("pre-overwrite", config.PreHook, dir)
+}
+
+// runPostHook runs the PostHook in dir after the files of an overwrite are
+// written. Like the PreHook, it does not run if DryRun or CheckOnly is set.
+func (config *overwriteConfig) runPostHook(dir string) error {
+	if config.DryRun || config.CheckOnly {
+		return nil
+	}
+	return runHook("post-overwrite", config.PostHook, dir)
+}
+
+// runHook runs the command and arguments of a hook in dir, printing their
+// combined output. A command that fails or exits with a non-zero status is an
+// error including the output.
+func runHook(name string, hook []string, dir string) error {
+	if len(hook) == 0 {
+		return nil
+	}
+	fmt.Printf("Running %s hook: %s in %s\n", name, strings.Join(hook, " "), dir)
+
+	cmd := exec.Command(hook[0], hook[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		fmt.Printf("%s", output)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s hook: %s output: %s error: %v", ErrHookFailed, name,
+			strings.Join(hook, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
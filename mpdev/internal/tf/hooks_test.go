@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteHooks(t *testing.T) {
+	testcases := []struct {
+		name            string
+		overwriteConfig overwriteConfig
+		written         bool
+		expectedFiles   map[string]string
+		errorContains   string
+	}{{
+		name: "Pre hook runs before files are read and post hook after they are written",
+		overwriteConfig: overwriteConfig{
+			PreHook:   []string{"sh", "-c", "sed 's/original-value/pre-hook-value/' main.tf > main.tf.new && mv main.tf.new main.tf"},
+			PostHook:  []string{"sh", "-c", "grep -c new-value main.tf > post-hook.txt"},
+			Variables: []string{"value_to_replace"},
+			Replacements: map[string]string{
+				"pre-hook-value": "new-value",
+			},
+		},
+		written: true,
+		expectedFiles: map[string]string{
+			"post-hook.txt": "1\n",
+		},
+	}, {
+		name: "Fail when pre hook fails and do not write files",
+		overwriteConfig: overwriteConfig{
+			PreHook:  []string{"sh", "-c", "echo invalid module; exit 3"},
+			PostHook: []string{"sh", "-c", "touch post-hook.txt"},
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+		errorContains: "hook failed: pre-overwrite hook: sh -c echo invalid module; exit 3 output: invalid module error: exit status 3",
+	}, {
+		name: "Fail when post hook fails after files are written",
+		overwriteConfig: overwriteConfig{
+			PostHook: []string{"sh", "-c", "echo validation failed >&2; exit 1"},
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+		written:       true,
+		errorContains: "post-overwrite hook: sh -c echo validation failed >&2; exit 1 output: validation failed",
+	}, {
+		name: "Fail when hook command is not found",
+		overwriteConfig: overwriteConfig{
+			PreHook: []string{"mpdev-missing-hook-command"},
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+		errorContains: "pre-overwrite hook: mpdev-missing-hook-command output:  error:",
+	}, {
+		name: "Hooks do not run on dry run",
+		overwriteConfig: overwriteConfig{
+			DryRun:   true,
+			PreHook:  []string{"sh", "-c", "touch pre-hook.txt"},
+			PostHook: []string{"sh", "-c", "touch post-hook.txt"},
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+			assert.NoError(t, err)
+
+			_, err = OverwriteTf(&tc.overwriteConfig, tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrHookFailed)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+
+			// Only value_to_replace is overwritten
+			expectedFiles := map[string]string{"main.tf": mainTf}
+			if tc.written {
+				expectedFiles["main.tf"] = strings.Replace(mainTf, "original-value", "new-value", 1)
+			}
+			for filename, content := range tc.expectedFiles {
+				expectedFiles[filename] = content
+			}
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFiles, actualContents)
+		})
+	}
+}
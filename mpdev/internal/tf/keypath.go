@@ -0,0 +1,176 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// splitKeyPath splits a NewValues name such as `infra.image` into the variable
+// name and the key path within its object or map default. Variable names
+// cannot contain dots, so names without a dot have an empty key path.
+func splitKeyPath(name string) (string, []string) {
+	parts := strings.Split(name, ".")
+	return parts[0], parts[1:]
+}
+
+// getKeyPathValue returns the value at a key path within an object or map
+// default
+func getKeyPathValue(value interface{}, keyPath []string) (interface{}, bool) {
+	for _, key := range keyPath {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// overwriteKeyPathValue adds an edit overwriting the string at a key path
+// within the default value of a variable, and records the change in the report.
+// Values that already equal the new value are skipped.
+func overwriteKeyPathValue(edits *fileEdits, report *ChangeReport, moduleVal *moduleValue,
+	keyPath []string, value string) error {
+	name := strings.Join(append([]string{moduleVal.Name}, keyPath...), ".")
+	oldValue, ok := getKeyPathValue(moduleVal.Default, keyPath)
+	if !ok {
+		return newVariableError(ErrVariableNotFound, moduleVal.Name,
+			"key path: %s not found in default value of %s: %s",
+			strings.Join(keyPath, "."), moduleVal.kind(), moduleVal.Name)
+	}
+	if _, ok := oldValue.(string); !ok {
+		return newVariableError(ErrWrongType, moduleVal.Name,
+			"value at key path: %s of %s: %s must be type string",
+			strings.Join(keyPath, "."), moduleVal.kind(), moduleVal.Name)
+	}
+	if oldValue == value {
+		report.Skipped = append(report.Skipped, name)
+		return nil
+	}
+
+	edits.add(moduleVal.Filename, func(result *OverwriteResult) error {
+		if isTfJSONFile(moduleVal.Filename) {
+			return overwriteJSONKeyPathFile(result, moduleVal.Filename, moduleVal.Name, keyPath, value)
+		}
+		return overwriteKeyPathFile(result, moduleVal.Filename, moduleVal.Name, keyPath, value)
+	})
+
+	report.Changes = append(report.Changes, VariableChange{
+		File:       moduleVal.Filename,
+		Variable:   name,
+		OldDefault: oldValue,
+		NewDefault: value,
+	})
+	return nil
+}
+
+// overwriteKeyPathFile replaces the value at a key path within the object or
+// map default of a variable. Only the bytes of that value are rewritten, so the
+// rest of the object is untouched.
+func overwriteKeyPathFile(result *OverwriteResult, filename string, varname string,
+	keyPath []string, value string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclsyntax.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	var attribute *hclsyntax.Attribute
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type == "variable" && len(block.Labels) == 1 && block.Labels[0] == varname {
+			attribute = block.Body.Attributes["default"]
+			break
+		}
+	}
+	if attribute == nil {
+		return fmt.Errorf("did not find default value of variable: %s", varname)
+	}
+
+	expr := attribute.Expr
+	for _, key := range keyPath {
+		expr = getObjectItemValue(expr, key)
+		if expr == nil {
+			return fmt.Errorf("key path: %s not found in default value of variable: %s",
+				strings.Join(keyPath, "."), varname)
+		}
+	}
+
+	valueRange := expr.Range()
+	valueBytes := hclwrite.TokensForValue(cty.StringVal(value)).Bytes()
+	result.stageFile(filename, b, spliceBytes(b, valueRange.Start.Byte, valueRange.End.Byte, valueBytes))
+	return nil
+}
+
+// getObjectItemValue returns the value expression of the item with the given
+// key in an object expression, or nil if there is no such item
+func getObjectItemValue(expr hclsyntax.Expression, key string) hclsyntax.Expression {
+	object, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil
+	}
+	for _, item := range object.Items {
+		keyValue, diag := item.KeyExpr.Value(nil)
+		if diag.HasErrors() || keyValue.Type() != cty.String || keyValue.IsNull() {
+			continue
+		}
+		if keyValue.AsString() == key {
+			return item.ValueExpr
+		}
+	}
+	return nil
+}
+
+// overwriteJSONKeyPathFile sets the value at a key path within the default
+// value of a variable in a .tf.json file
+func overwriteJSONKeyPathFile(result *OverwriteResult, filename string, varname string,
+	keyPath []string, value string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	variableQuery, _ := getJSONVariableQuery(b, varname)
+	query := variableQuery + ".default"
+	for _, key := range keyPath {
+		query += "." + escapeJSONPath(key)
+	}
+	if !gjson.GetBytes(b, query).Exists() {
+		return fmt.Errorf("key path: %s not found in default value of variable: %s",
+			strings.Join(keyPath, "."), varname)
+	}
+
+	proposed, err := sjson.SetBytes(b, query, value)
+	if err != nil {
+		return fmt.Errorf("error setting default value of variable: %s. error: %w", varname, err)
+	}
+
+	result.stageFile(filename, b, proposed)
+	return nil
+}
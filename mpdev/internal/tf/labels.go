@@ -0,0 +1,603 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const defaultLabelsConst = "default_labels"
+const consumerLabelConst = "goog-partner-solution"
+
+// Inserts consumer labels under every `provider "google"` block, including
+// aliased providers, if they do not exist. If no Terraform file in the module
+// declares the provider, a `provider "google"` block is appended to the main file.
+// Labels cannot be inserted into .tf.json files, so an error is returned if the
+// provider is declared only in a .tf.json file.
+// The `dir` parameter is the path to the TF main file.
+// The `labels` parameter maps each label key, such as goog-partner-solution,
+// to the label value. If `force` is set, the values of existing labels are
+// replaced.
+func upsertConsumerLabel(result *OverwriteResult, dir string, labels map[string]string, force bool) error {
+	// If the parameter is not provided, do nothing.
+	// This is for backward-compatibility purpose.
+	if len(labels) == 0 {
+		fmt.Printf("No consumer label was passed as a parameter.\n")
+		return nil
+	}
+
+	for _, key := range sortedKeys(labels) {
+		fmt.Printf("Inserting the '%s' consumer label under the '%s' key.\n", labels[key], key)
+	}
+
+	filenames, err := getTfFilenames(result.fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	providerFound := false
+	for _, filename := range filenames {
+		b, err := result.readFile(filename)
+		if err != nil {
+			return err
+		}
+		parsedFile, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+		if diag.HasErrors() {
+			return newParseError(diag)
+		}
+
+		providerBlocks := getGoogleProviderBlocks(parsedFile)
+		if len(providerBlocks) == 0 {
+			continue
+		}
+		providerFound = true
+		fmt.Printf("'provider \"google\"' block detected in %s\n", filename)
+
+		var changedBlocks []*hclwrite.Block
+		for _, providerBlock := range providerBlocks {
+			if upsertLabels(providerBlock, filename, labels, force) {
+				changedBlocks = append(changedBlocks, providerBlock)
+			}
+		}
+		if len(changedBlocks) == 0 {
+			continue
+		}
+
+		rawBytes := parsedFile.BuildTokens(nil).Bytes()
+		for _, providerBlock := range changedBlocks {
+			rawBytes = formatBlock(rawBytes, providerBlock)
+		}
+		result.stageFile(filename, b, rawBytes)
+	}
+
+	if providerFound {
+		return nil
+	}
+
+	jsonFilename, err := findJSONGoogleProvider(result, dir)
+	if err != nil {
+		return err
+	}
+	if jsonFilename != "" {
+		return fmt.Errorf("'provider \"google\"' block found in %s. "+
+			"Consumer labels cannot be inserted into .tf.json files", jsonFilename)
+	}
+
+	mainTfFullPath := path.Join(dir, mainTfFile)
+	fmt.Printf("'provider \"google\"' block not found in %s. Appending to %s\n", dir, mainTfFullPath)
+
+	b, err := result.readFile(mainTfFullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	parsedFile, diag := hclwrite.ParseConfig(b, mainTfFullPath, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	if len(b) > 0 {
+		parsedFile.Body().AppendNewline()
+	}
+	providerBlock := parsedFile.Body().AppendNewBlock("provider", []string{"google"})
+	upsertLabels(providerBlock, mainTfFullPath, labels, force)
+
+	rawBytes := parsedFile.BuildTokens(nil).Bytes()
+	result.stageFile(mainTfFullPath, b, formatBlock(rawBytes, providerBlock))
+	return nil
+}
+
+// upsertLabels inserts the consumer labels into the default labels of a
+// provider block, and returns whether the block was changed. Labels that are
+// already present are not overwritten unless force is set, and other labels
+// are preserved.
+func upsertLabels(providerBlock *hclwrite.Block, filename string, labels map[string]string, force bool) bool {
+	defaultLabelsAttribute := providerBlock.Body().GetAttribute(defaultLabelsConst)
+	if defaultLabelsAttribute == nil {
+		if defaultLabelsBlock := providerBlock.Body().FirstMatchingBlock(defaultLabelsConst, nil); defaultLabelsBlock != nil {
+			return upsertBlockLabels(defaultLabelsBlock, filename, labels, force)
+		}
+
+		fmt.Printf("'%s' attribute not found in %s. Appending.\n", defaultLabelsConst, filename)
+
+		values := map[string]cty.Value{}
+		for key, value := range labels {
+			values[key] = cty.StringVal(value)
+		}
+		providerBlock.Body().SetAttributeValue(defaultLabelsConst, cty.MapVal(values))
+
+		fmt.Printf("Successfully upserted consumber label in %s\n", filename)
+		return true
+	}
+
+	tokens := defaultLabelsAttribute.Expr().BuildTokens(nil)
+	if tokens[0].Type != hclsyntax.TokenOBrace {
+		fmt.Printf("'%s' attribute detected in %s. Not overwriting.\n", defaultLabelsConst, filename)
+		return false
+	}
+
+	items := getObjectItems(tokens)
+	var missing []string
+	var replaced []objectItem
+	for _, key := range sortedKeys(labels) {
+		item := findObjectItem(items, key)
+		if item == nil {
+			missing = append(missing, key)
+		} else if force && !labelValueEquals(getObjectItemTokens(tokens, *item), labels[key]) {
+			replaced = append(replaced, *item)
+		}
+	}
+	if len(missing) == 0 && len(replaced) == 0 {
+		fmt.Printf("'%s' attribute detected in %s. Not overwriting.\n", defaultLabelsConst, filename)
+		return false
+	}
+
+	// Replace the values of existing labels from the last item backwards, so
+	// that the positions of the other items stay valid
+	sort.Slice(replaced, func(i, j int) bool {
+		return replaced[i].start > replaced[j].start
+	})
+	for _, item := range replaced {
+		fmt.Printf("'%s' attribute detected in %s. Replacing label: %s\n", defaultLabelsConst, filename, item.key)
+		valueStart, valueEnd := getObjectItemValueRange(tokens, item)
+		var updated hclwrite.Tokens
+		updated = append(updated, tokens[:valueStart]...)
+		updated = append(updated, getAttributeValueTokens(labels[item.key])...)
+		updated = append(updated, tokens[valueEnd:]...)
+		tokens = updated
+	}
+	if len(missing) == 0 {
+		providerBlock.Body().SetAttributeRaw(defaultLabelsConst, tokens)
+		fmt.Printf("Successfully upserted consumber label in %s\n", filename)
+		return true
+	}
+
+	fmt.Printf("'%s' attribute detected in %s. Adding labels: %s\n", defaultLabelsConst, filename, missing)
+
+	closeIndex := len(tokens) - 1
+	for closeIndex > 0 && tokens[closeIndex].Type != hclsyntax.TokenCBrace {
+		closeIndex--
+	}
+	multiline := false
+	for _, token := range tokens {
+		if token.Type == hclsyntax.TokenNewline {
+			multiline = true
+		}
+	}
+
+	var added hclwrite.Tokens
+	for i, key := range missing {
+		if !multiline && (i > 0 || (len(items) > 0 && !endsWithComma(tokens[:closeIndex]))) {
+			added = append(added, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+		}
+		added = append(added, getLabelItemTokens(key, labels[key])...)
+		if multiline {
+			added = append(added, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")})
+		}
+	}
+
+	var updated hclwrite.Tokens
+	updated = append(updated, tokens[:closeIndex]...)
+	updated = append(updated, added...)
+	updated = append(updated, tokens[closeIndex:]...)
+	providerBlock.Body().SetAttributeRaw(defaultLabelsConst, updated)
+
+	fmt.Printf("Successfully upserted consumber label in %s\n", filename)
+	return true
+}
+
+// upsertBlockLabels inserts the consumer labels into a `default_labels { ... }`
+// block, and returns whether the block was changed. Labels that are already
+// present are not overwritten unless force is set. Label keys that are not
+// valid identifiers cannot be block attributes, so they are skipped.
+func upsertBlockLabels(defaultLabelsBlock *hclwrite.Block, filename string, labels map[string]string, force bool) bool {
+	body := defaultLabelsBlock.Body()
+	var missing []string
+	for _, key := range sortedKeys(labels) {
+		if attribute := body.GetAttribute(key); attribute != nil {
+			if force && !labelValueEquals(attribute.Expr().BuildTokens(nil), labels[key]) {
+				missing = append(missing, key)
+			}
+			continue
+		}
+		if !hclsyntax.ValidIdentifier(key) {
+			fmt.Printf("Label key: %s is not a valid attribute name of the '%s' block in %s. Skipping\n",
+				key, defaultLabelsConst, filename)
+			continue
+		}
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		fmt.Printf("'%s' block detected in %s. Not overwriting.\n", defaultLabelsConst, filename)
+		return false
+	}
+
+	fmt.Printf("'%s' block detected in %s. Adding labels: %s\n", defaultLabelsConst, filename, missing)
+	for _, key := range missing {
+		body.SetAttributeValue(key, cty.StringVal(labels[key]))
+	}
+
+	fmt.Printf("Successfully upserted consumber label in %s\n", filename)
+	return true
+}
+
+// getLabelItemTokens returns the tokens of a `key = "value"` object item. Keys
+// that are not valid identifiers are quoted.
+func getLabelItemTokens(key string, value string) hclwrite.Tokens {
+	keyTokens := hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(key)}}
+	if !hclsyntax.ValidIdentifier(key) {
+		keyTokens = hclwrite.TokensForValue(cty.StringVal(key))
+	}
+	keyTokens[0].SpacesBefore = 1
+
+	itemTokens := append(keyTokens, &hclwrite.Token{Type: hclsyntax.TokenEqual, Bytes: []byte("="), SpacesBefore: 1})
+	return append(itemTokens, getAttributeValueTokens(value)...)
+}
+
+// endsWithComma returns whether the last token that is not a newline is a comma
+func endsWithComma(tokens hclwrite.Tokens) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Type != hclsyntax.TokenNewline {
+			return tokens[i].Type == hclsyntax.TokenComma
+		}
+	}
+	return false
+}
+
+// getGoogleProviderBlocks returns all `provider "google"` blocks in a file
+func getGoogleProviderBlocks(file *hclwrite.File) []*hclwrite.Block {
+	var blocks []*hclwrite.Block
+	for _, block := range file.Body().Blocks() {
+		labels := block.Labels()
+		if block.Type() == "provider" && len(labels) == 1 && labels[0] == "google" {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// getTfFilenames returns the Terraform files in dir, with the main file first
+func getTfFilenames(fsys moduleFS, dir string) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var filenames []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".tf") {
+			filenames = append(filenames, path.Join(dir, entry.Name()))
+		}
+	}
+
+	mainTfFullPath := path.Join(dir, mainTfFile)
+	sort.SliceStable(filenames, func(i, j int) bool {
+		return filenames[i] == mainTfFullPath && filenames[j] != mainTfFullPath
+	})
+	return filenames, nil
+}
+
+// findJSONGoogleProvider returns the first .tf.json file in dir that declares
+// a `provider "google"` block, or an empty string if there is none
+func findJSONGoogleProvider(result *OverwriteResult, dir string) (string, error) {
+	entries, err := result.fsys.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isTfJSONFile(entry.Name()) {
+			continue
+		}
+		filename := path.Join(dir, entry.Name())
+		b, err := result.readFile(filename)
+		if err != nil {
+			return "", err
+		}
+		if hasJSONGoogleProvider(b) {
+			return filename, nil
+		}
+	}
+	return "", nil
+}
+
+// RemoveConsumerLabel removes the consumer label from the default labels of
+// every `provider "google"` block in the Terraform files of dir. The default
+// labels attribute or block is removed if no other labels remain.
+func RemoveConsumerLabel(dir string) error {
+	result := newOverwriteResult()
+
+	filenames, err := getTfFilenames(result.fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		b, err := result.readFile(filename)
+		if err != nil {
+			return err
+		}
+		parsedFile, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+		if diag.HasErrors() {
+			return newParseError(diag)
+		}
+
+		changed := false
+		for _, providerBlock := range getGoogleProviderBlocks(parsedFile) {
+			body := providerBlock.Body()
+			defaultLabelsAttribute := body.GetAttribute(defaultLabelsConst)
+			if defaultLabelsAttribute == nil {
+				defaultLabelsBlock := body.FirstMatchingBlock(defaultLabelsConst, nil)
+				if defaultLabelsBlock == nil || defaultLabelsBlock.Body().GetAttribute(consumerLabelConst) == nil {
+					continue
+				}
+
+				changed = true
+				defaultLabelsBlock.Body().RemoveAttribute(consumerLabelConst)
+				if len(defaultLabelsBlock.Body().Attributes()) == 0 && len(defaultLabelsBlock.Body().Blocks()) == 0 {
+					body.RemoveBlock(defaultLabelsBlock)
+				}
+				continue
+			}
+
+			tokens := defaultLabelsAttribute.Expr().BuildTokens(nil)
+			items := getObjectItems(tokens)
+			item := findObjectItem(items, consumerLabelConst)
+			if item == nil {
+				continue
+			}
+
+			changed = true
+			if len(items) == 1 {
+				body.RemoveAttribute(defaultLabelsConst)
+				continue
+			}
+
+			var remaining hclwrite.Tokens
+			remaining = append(remaining, tokens[:item.start]...)
+			remaining = append(remaining, tokens[item.end:]...)
+			body.SetAttributeRaw(defaultLabelsConst, remaining)
+		}
+
+		if changed {
+			fmt.Printf("Removing the '%s' consumer label from %s\n", consumerLabelConst, filename)
+			result.stageFile(filename, b, parsedFile.BuildTokens(nil).Bytes())
+		}
+	}
+
+	return result.commit(&overwriteConfig{}, dir)
+}
+
+// GetConsumerLabel returns the value of the consumer label in the default
+// labels of the `provider "google"` blocks in the Terraform files of dir, and
+// whether it is present. No files are written. If several providers set the
+// label, the first value is returned. See GetConsumerLabels for all of them.
+func GetConsumerLabel(dir string) (string, bool, error) {
+	values, err := GetConsumerLabels(dir)
+	if err != nil || len(values) == 0 {
+		return "", false, err
+	}
+	return values[0], true, nil
+}
+
+// GetConsumerLabels returns the values of the consumer label of every
+// `provider "google"` block in the Terraform files of dir that sets it, in the
+// order of the files with the main file first. Values that are not string
+// literals are returned as their expression, such as `var.label`.
+func GetConsumerLabels(dir string) ([]string, error) {
+	result := newOverwriteResult()
+
+	filenames, err := getTfFilenames(result.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, filename := range filenames {
+		b, err := result.readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		parsedFile, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+		if diag.HasErrors() {
+			return nil, newParseError(diag)
+		}
+
+		for _, providerBlock := range getGoogleProviderBlocks(parsedFile) {
+			body := providerBlock.Body()
+			defaultLabelsAttribute := body.GetAttribute(defaultLabelsConst)
+			if defaultLabelsAttribute == nil {
+				defaultLabelsBlock := body.FirstMatchingBlock(defaultLabelsConst, nil)
+				if defaultLabelsBlock == nil {
+					continue
+				}
+				attribute := defaultLabelsBlock.Body().GetAttribute(consumerLabelConst)
+				if attribute == nil {
+					continue
+				}
+				values = append(values, getLabelValue(attribute.Expr().BuildTokens(nil)))
+				continue
+			}
+
+			tokens := defaultLabelsAttribute.Expr().BuildTokens(nil)
+			item := findObjectItem(getObjectItems(tokens), consumerLabelConst)
+			if item == nil {
+				continue
+			}
+			values = append(values, getLabelValue(getObjectItemTokens(tokens, *item)))
+		}
+	}
+	return values, nil
+}
+
+// getLabelValue returns the string value of the label value tokens, or the
+// expression if the value is not a string literal
+func getLabelValue(tokens hclwrite.Tokens) string {
+	source := strings.TrimSpace(string(tokens.Bytes()))
+	expr, diag := hclsyntax.ParseExpression([]byte(source), "", hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return source
+	}
+	value, diag := expr.Value(nil)
+	if diag.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+		return source
+	}
+	return value.AsString()
+}
+
+// objectItem locates an item of an object constructor expression, such as
+// `{ key = "value" }`, within the expression tokens.
+type objectItem struct {
+	key string
+	// start is the index of the first token of the item
+	start int
+	// end is the index after the last token of the item, including a
+	// trailing comma or newline
+	end int
+}
+
+// getObjectItems returns the items of an object constructor expression
+func getObjectItems(tokens hclwrite.Tokens) []objectItem {
+	var items []objectItem
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenOBrace {
+		return items
+	}
+
+	var item *objectItem
+	depth := 0
+	for i := 1; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.Type {
+		case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+			hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			depth++
+		case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+			hclsyntax.TokenTemplateSeqEnd:
+			if depth == 0 {
+				// End of the object
+				if item != nil {
+					item.end = i
+					items = append(items, *item)
+				}
+				return items
+			}
+			depth--
+		}
+
+		if depth > 0 {
+			continue
+		}
+
+		if item == nil {
+			if token.Type == hclsyntax.TokenNewline || token.Type == hclsyntax.TokenComment {
+				continue
+			}
+			item = &objectItem{key: getObjectKey(tokens[i:]), start: i}
+		}
+
+		if token.Type == hclsyntax.TokenNewline || token.Type == hclsyntax.TokenComma ||
+			token.Type == hclsyntax.TokenComment {
+			item.end = i + 1
+			items = append(items, *item)
+			item = nil
+		}
+	}
+
+	return items
+}
+
+// getObjectKey returns the key of an object item given the tokens starting at
+// the item. Keys are either identifiers or quoted strings.
+func getObjectKey(tokens hclwrite.Tokens) string {
+	if tokens[0].Type == hclsyntax.TokenOQuote && len(tokens) > 1 &&
+		tokens[1].Type == hclsyntax.TokenQuotedLit {
+		return string(tokens[1].Bytes)
+	}
+	return string(tokens[0].Bytes)
+}
+
+// getObjectItemValueRange returns the start and end indices of the value
+// tokens of an object item, excluding a trailing comma, comment or newline
+func getObjectItemValueRange(tokens hclwrite.Tokens, item objectItem) (int, int) {
+	start := item.start
+	for start < item.end && tokens[start].Type != hclsyntax.TokenEqual &&
+		tokens[start].Type != hclsyntax.TokenColon {
+		start++
+	}
+	start++
+
+	end := item.end
+	for end > start {
+		switch tokens[end-1].Type {
+		case hclsyntax.TokenNewline, hclsyntax.TokenComma, hclsyntax.TokenComment:
+			end--
+			continue
+		}
+		break
+	}
+	return start, end
+}
+
+// getObjectItemTokens returns the value tokens of an object item
+func getObjectItemTokens(tokens hclwrite.Tokens, item objectItem) hclwrite.Tokens {
+	start, end := getObjectItemValueRange(tokens, item)
+	if start > end {
+		return nil
+	}
+	return tokens[start:end]
+}
+
+// labelValueEquals returns whether the tokens are the string literal value
+func labelValueEquals(tokens hclwrite.Tokens, value string) bool {
+	return strings.TrimSpace(string(tokens.Bytes())) ==
+		strings.TrimSpace(string(getAttributeValueTokens(value).Bytes()))
+}
+
+func findObjectItem(items []objectItem, key string) *objectItem {
+	for i := range items {
+		if items[i].key == key {
+			return &items[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveConsumerLabel(t *testing.T) {
+	testcases := []struct {
+		name            string
+		tfFiles         map[string]string
+		expectedTfFiles map[string]string
+		errorContains   string
+	}{{
+		name: "Remove default labels containing only the consumer label",
+		tfFiles: map[string]string{
+			"main.tf": mainTfLabelUpserted,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": mainTfLabelRemoved,
+		},
+	}, {
+		name: "Keep other default labels",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderOtherLabels,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfProviderOtherLabelsRemoved,
+		},
+	}, {
+		name: "Remove from single line default labels",
+		tfFiles: map[string]string{
+			"main.tf": `provider "google" {
+  default_labels = { goog-partner-solution = "label", team = "db" }
+}
+`,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": `provider "google" {
+  default_labels = { team = "db" }
+}
+`,
+		},
+	}, {
+		name: "Remove from default labels block",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderLabelsBlockUpserted,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": `
+provider "google" {
+  project = var.project_id
+  default_labels {
+    team                  = "db"
+  }
+}
+`,
+		},
+	}, {
+		name: "Remove default labels block containing only the consumer label",
+		tfFiles: map[string]string{
+			"main.tf": `provider "google" {
+  default_labels {
+    goog-partner-solution = "label"
+  }
+}
+`,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": `provider "google" {
+}
+`,
+		},
+	}, {
+		name: "Remove from aliased providers in multiple files",
+		tfFiles: map[string]string{
+			"main.tf":     mainTfLabelUpserted,
+			"provider.tf": tfProviderAliasesLabelUpserted,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf":     mainTfLabelRemoved,
+			"provider.tf": tfProviderAliasesLabelRemoved,
+		},
+	}, {
+		name: "No-op when consumer label is not present",
+		tfFiles: map[string]string{
+			"main.tf": mainTfNoLabel,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": mainTfNoLabel,
+		},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		tfFiles: map[string]string{
+			"main.tf": "this is broken",
+		},
+		errorContains: "Invalid block definition",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.tfFiles {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			err = RemoveConsumerLabel(tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedTfFiles, actualContents)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestGetConsumerLabel(t *testing.T) {
+	testcases := []struct {
+		name           string
+		tfFiles        map[string]string
+		expectedLabels []string
+		errorContains  string
+	}{{
+		name: "Get consumer label of provider",
+		tfFiles: map[string]string{
+			"main.tf": mainTfLabelUpserted,
+		},
+		expectedLabels: []string{"new-consumer-label"},
+	}, {
+		name: "Get consumer label among other default labels",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderOtherLabels,
+		},
+		expectedLabels: []string{"new-consumer-label"},
+	}, {
+		name: "Get consumer labels of multiple providers and files, main file first",
+		tfFiles: map[string]string{
+			"main.tf":     tfProviderExistingLabels,
+			"provider.tf": tfProviderAliasesLabelUpserted,
+		},
+		expectedLabels: []string{
+			"old-consumer-label",
+			"old-consumer-label",
+			"old-consumer-label",
+			"existing-consumer-label",
+			"new-consumer-label",
+			"new-consumer-label",
+		},
+	}, {
+		name: "Get consumer label that is not a string literal as its expression",
+		tfFiles: map[string]string{
+			"main.tf": `provider "google" {
+  default_labels = { goog-partner-solution = var.consumer_label, team = "db" }
+}
+`,
+		},
+		expectedLabels: []string{"var.consumer_label"},
+	}, {
+		name: "Not found when consumer label is not present",
+		tfFiles: map[string]string{
+			"main.tf": mainTfNoLabel,
+		},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		tfFiles: map[string]string{
+			"main.tf": "this is broken",
+		},
+		errorContains: "Invalid block definition",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.tfFiles {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			labels, err := GetConsumerLabels(tmpDir)
+			label, found, labelErr := GetConsumerLabel(tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.NoError(t, labelErr)
+				assert.Equal(t, tc.expectedLabels, labels)
+				assert.Equal(t, len(tc.expectedLabels) > 0, found)
+				if found {
+					assert.Equal(t, tc.expectedLabels[0], label)
+				}
+
+				// Nothing is written
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.tfFiles, actualContents)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+				assert.ErrorContains(t, labelErr, tc.errorContains)
+			}
+		})
+	}
+}
+
+var mainTfLabelRemoved string = `
+provider "google" {
+  project = var.project_id
+}
+
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "new-value"
+}
+`
+
+var tfProviderOtherLabels string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    team                  = "db"
+    goog-partner-solution = "new-consumer-label" # added at release
+    "cost-center"         = "123"
+  }
+}
+`
+
+var tfProviderOtherLabelsRemoved string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    team                  = "db"
+    "cost-center"         = "123"
+  }
+}
+`
+
+var tfProviderAliasesLabelRemoved string = `
+provider "google" {
+  project = var.project_id
+}
+
+provider "google" {
+  alias   = "beta"
+  project = var.project_id
+}
+
+provider "google" {
+  alias   = "other"
+  project = var.project_id
+}
+`
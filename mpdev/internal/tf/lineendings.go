@@ -0,0 +1,41 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import "bytes"
+
+var (
+	crlf = []byte("\r\n")
+	lf   = []byte("\n")
+)
+
+// usesCRLF returns whether most lines of b end with CRLF rather than LF
+func usesCRLF(b []byte) bool {
+	crlfCount := bytes.Count(b, crlf)
+	return crlfCount > 0 && crlfCount >= bytes.Count(b, lf)-crlfCount
+}
+
+// withLineEndings returns proposed with the dominant line ending of original,
+// since the HCL, JSON and YAML writers end new and reformatted lines with LF.
+// Files mostly ending lines with CRLF, such as files committed on Windows, then
+// only differ in the changed lines. Contents equal to original are returned as
+// is.
+func withLineEndings(original []byte, proposed []byte) []byte {
+	if !usesCRLF(original) || bytes.Equal(original, proposed) {
+		return proposed
+	}
+	normalized := bytes.ReplaceAll(proposed, crlf, lf)
+	return bytes.ReplaceAll(normalized, lf, crlf)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const localsBlockType = "locals"
+
+// getFileLocalValues returns the local values declared in the `locals` blocks
+// of a Terraform file by name
+func getFileLocalValues(filename string, body *hclsyntax.Body) map[string][]*moduleValue {
+	values := map[string][]*moduleValue{}
+	for _, block := range body.Blocks {
+		if block.Type != localsBlockType {
+			continue
+		}
+		for _, name := range sortedAttributeNames(block.Body.Attributes) {
+			attribute := block.Body.Attributes[name]
+			value := &moduleValue{Name: name, Filename: filename, Local: true}
+			// Only string literals can be overwritten. Expressions referring to
+			// other values cannot be evaluated without a context.
+			ctyValue, diag := attribute.Expr.Value(nil)
+			if !diag.HasErrors() && ctyValue.Type() == cty.String && ctyValue.IsKnown() && !ctyValue.IsNull() {
+				value.Type = "string"
+				value.Default = ctyValue.AsString()
+			}
+			values[name] = append(values[name], value)
+		}
+	}
+	return values
+}
+
+// overwriteLocalFile sets a local value in the first `locals` block of the file
+// declaring it
+func overwriteLocalFile(result *OverwriteResult, filename string, name string, value string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != localsBlockType || block.Body().GetAttribute(name) == nil {
+			continue
+		}
+
+		_, err := setStringAttribute(block.Body(), name, value)
+		if err != nil {
+			return fmt.Errorf("failure overwriting local: %s error: %w", name, err)
+		}
+		result.stageFile(filename, b, file.BuildTokens(nil).Bytes())
+		return nil
+	}
+
+	return fmt.Errorf("did not find locals block with local: %s", name)
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockFilename is the name of the lock file created in the module directory
+// while an overwrite with Lock set writes its files
+const lockFilename = ".mpdev-overwrite.lock"
+
+// defaultLockTimeout is how long to wait for the lock if LockTimeout is not set
+const defaultLockTimeout = 30 * time.Second
+
+// lockRetryInterval is how long to wait between attempts to acquire the lock
+const lockRetryInterval = 100 * time.Millisecond
+
+// acquireLock creates the lock file in dir, waiting until timeout for another
+// process holding the lock to remove it. The returned function removes the
+// lock file.
+func acquireLock(dir string, timeout time.Duration) (func(), error) {
+	lockPath := filepath.Join(dir, lockFilename)
+	deadline := time.Now().Add(timeout)
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+			lockFile.Close()
+			return func() {
+				os.Remove(lockPath)
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failure creating lock file: %s error: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s not acquired within %s. If no other overwrite of"+
+				" the module is running, remove the lock file", ErrLockTimeout, lockPath, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// lockTimeout returns the LockTimeout, or the defaultLockTimeout if it is not
+// set. The LockTimeout is checked by Validate.
+func (config *overwriteConfig) lockTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.LockTimeout)
+	if err != nil {
+		return defaultLockTimeout
+	}
+	return timeout
+}
+
+// checkUnmodified returns an error if a staged file was changed on disk since
+// its original contents were read, such as by a concurrent overwrite, so that
+// writing the proposed contents would lose the other change
+func (r *OverwriteResult) checkUnmodified() error {
+	for _, filename := range r.filenames() {
+		original := r.Files[filename].Original
+		current, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) && original == nil {
+			continue
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err != nil || original == nil || !bytes.Equal(current, original) {
+			return fmt.Errorf("%w: %s", ErrModifiedConcurrently, filename)
+		}
+	}
+	return nil
+}
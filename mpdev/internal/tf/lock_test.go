@@ -0,0 +1,115 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+	assert.NoError(t, err)
+
+	config := &overwriteConfig{
+		Lock: true,
+		NewValues: map[string]string{
+			"value_to_replace":       "new-value",
+			"other_value_to_replace": "newer-value",
+		},
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = OverwriteTf(config, tmpDir)
+		}(i)
+	}
+	wg.Wait()
+
+	// Each overwrite either succeeds or fails because another overwrite
+	// changed the files it read, but none interleave their writes
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, ErrModifiedConcurrently)
+		}
+	}
+	assert.GreaterOrEqual(t, succeeded, 1)
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"main.tf": mainTfReplaced}, actualContents)
+}
+
+func TestOverwriteLockTimeout(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":    mainTf,
+		lockFilename: "1\n",
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	_, err = OverwriteTf(&overwriteConfig{
+		Lock:        true,
+		LockTimeout: "200ms",
+		NewValues: map[string]string{
+			"value_to_replace": "new-value",
+		},
+	}, tmpDir)
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.ErrorContains(t, err, "not acquired within 200ms")
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, originalFiles, actualContents)
+}
+
+func TestCommitModifiedConcurrently(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	mainFilename := path.Join(tmpDir, "main.tf")
+	err = os.WriteFile(mainFilename, []byte(otherTf), 0600)
+	assert.NoError(t, err)
+
+	result := newOverwriteResult()
+	result.stageFile(mainFilename, []byte(mainTf), []byte(mainTfReplaced))
+
+	err = result.commit(&overwriteConfig{Lock: true}, tmpDir)
+	assert.ErrorIs(t, err, ErrModifiedConcurrently)
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"main.tf": otherTf}, actualContents)
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// withVariablesManifest returns a copy of config with the logical names keying
+// NewValues and Expected resolved to variable names using the
+// VariablesManifest of the module in dir. A dotted key path after a logical
+// name is kept. The copy has no VariablesManifest, so resolving it again is a
+// no-op. Without a VariablesManifest, config is returned as is.
+func (config *overwriteConfig) withVariablesManifest(result *OverwriteResult, dir string) (*overwriteConfig, error) {
+	if config.VariablesManifest == "" {
+		return config, nil
+	}
+
+	manifestPath := config.VariablesManifest
+	if !filepath.IsAbs(manifestPath) {
+		manifestPath = filepath.Join(dir, manifestPath)
+	}
+	b, err := result.readFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failure reading variables manifest: %s error: %w", manifestPath, err)
+	}
+	var manifest map[string]string
+	err = yaml.Unmarshal(b, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing variables manifest: %s error: %w", manifestPath, err)
+	}
+
+	resolved := *config
+	resolved.VariablesManifest = ""
+	resolved.NewValues, err = resolveLogicalNames(config.NewValues, manifest, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Expected, err = resolveLogicalNames(config.Expected, manifest, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// resolveLogicalNames returns values keyed by the variable names that the
+// manifest maps their logical names to
+func resolveLogicalNames(values map[string]string, manifest map[string]string,
+	manifestPath string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	resolved := map[string]string{}
+	logicalNames := map[string]string{}
+	for _, name := range sortedKeys(values) {
+		logicalName, keyPath := splitKeyPath(name)
+		varName, ok := manifest[logicalName]
+		if !ok {
+			return nil, newVariableError(ErrVariableNotFound, logicalName,
+				"logical name: %s not found in variables manifest: %s", logicalName, manifestPath)
+		}
+
+		resolvedName := strings.Join(append([]string{varName}, keyPath...), ".")
+		if other, ok := logicalNames[resolvedName]; ok {
+			return nil, fmt.Errorf("invalid overwrite config: logical names: %s and %s"+
+				" in variables manifest: %s both map to: %s", other, name, manifestPath, resolvedName)
+		}
+		logicalNames[resolvedName] = name
+		resolved[resolvedName] = values[name]
+	}
+	return resolved, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariablesManifest(t *testing.T) {
+	testcases := []struct {
+		name            string
+		files           map[string]string
+		overwriteConfig overwriteConfig
+		expectedFiles   map[string]string
+		errorContains   string
+	}{{
+		name: "Resolve logical names with a YAML manifest",
+		files: map[string]string{
+			"main.tf":        mainTf,
+			"variables.yaml": variablesManifest,
+		},
+		overwriteConfig: overwriteConfig{
+			VariablesManifest: "variables.yaml",
+			NewValues: map[string]string{
+				"image":       "new-value",
+				"other_image": "newer-value",
+			},
+			Expected: map[string]string{
+				"image": "original-value",
+			},
+		},
+		expectedFiles: map[string]string{
+			"main.tf":        mainTfReplaced,
+			"variables.yaml": variablesManifest,
+		},
+	}, {
+		name: "Resolve logical names with a JSON manifest",
+		files: map[string]string{
+			"main.tf":        mainTf,
+			"variables.json": `{"image": "value_to_replace", "other_image": "other_value_to_replace"}`,
+		},
+		overwriteConfig: overwriteConfig{
+			VariablesManifest: "variables.json",
+			NewValues: map[string]string{
+				"image":       "new-value",
+				"other_image": "newer-value",
+			},
+		},
+		expectedFiles: map[string]string{
+			"main.tf":        mainTfReplaced,
+			"variables.json": `{"image": "value_to_replace", "other_image": "other_value_to_replace"}`,
+		},
+	}, {
+		name: "Fail when a logical name has no mapping",
+		files: map[string]string{
+			"main.tf":        mainTf,
+			"variables.yaml": variablesManifest,
+		},
+		overwriteConfig: overwriteConfig{
+			VariablesManifest: "variables.yaml",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+		errorContains: "logical name: value_to_replace not found in variables manifest:",
+	}, {
+		name: "Fail when logical names map to the same variable",
+		files: map[string]string{
+			"main.tf":        mainTf,
+			"variables.yaml": variablesManifest + "source_image: value_to_replace\n",
+		},
+		overwriteConfig: overwriteConfig{
+			VariablesManifest: "variables.yaml",
+			NewValues: map[string]string{
+				"image":        "new-value",
+				"source_image": "new-value",
+			},
+		},
+		errorContains: "invalid overwrite config: logical names: image and source_image in variables manifest:",
+	}, {
+		name: "Fail when the manifest is missing",
+		files: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwriteConfig: overwriteConfig{
+			VariablesManifest: "variables.yaml",
+			NewValues: map[string]string{
+				"image": "new-value",
+			},
+		},
+		errorContains: "failure reading variables manifest:",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteAll(&tc.overwriteConfig, tmpDir)
+			if tc.errorContains != "" {
+				assert.ErrorContains(t, err, tc.errorContains)
+				return
+			}
+			assert.NoError(t, err)
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, actualContents)
+		})
+	}
+}
+
+var variablesManifest string = `image: value_to_replace
+other_image: other_value_to_replace
+`
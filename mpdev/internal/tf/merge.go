@@ -0,0 +1,201 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+// MergeOverwriteConfigs layers override on top of base and returns the merged
+// config. Neither input is modified. The merge rules are:
+//
+//   - Maps (NewValues, Expected, ConsumerLabels, Replacements, Digests,
+//     ProjectRemap, Descriptions, VarTypes, DisplayTitles, ImageProjects,
+//     OutputValues, MetadataFields, ProviderVersions, ModuleSources and the
+//     maps of each variable in DisplayLabels, DisplayProperties and
+//     DisplayNumbers) are merged per key. On a collision the entry of override
+//     wins. NewValues is set if it is set in either config.
+//   - Lists (Variables, VariableTypes, AllowDuplicates, MetadataFiles,
+//     DisplayFiles, ValuesFiles, Outputs, ResourceAttributes) are unioned,
+//     keeping the order of base followed by new entries of override.
+//   - RegexReplacements of override are tried before those of base, so that
+//     override also wins for values matched by both.
+//   - Scopes of both configs apply.
+//   - A non-empty ConsumerLabel, ConsumerLabelKey, DeployerImagePath,
+//     OutputField, VariablesManifest or LockTimeout of override replaces that
+//     of base.
+//   - A non-empty PreHook or PostHook of override replaces that of base.
+//   - A non-zero ModifiedSince of override replaces that of base.
+//   - Boolean options are enabled if they are enabled in either config.
+func MergeOverwriteConfigs(base, override *overwriteConfig) *overwriteConfig {
+	if base == nil {
+		base = &overwriteConfig{}
+	}
+	if override == nil {
+		override = &overwriteConfig{}
+	}
+
+	merged := &overwriteConfig{
+		ConsumerLabel:        base.ConsumerLabel,
+		ConsumerLabelKey:     base.ConsumerLabelKey,
+		DeployerImagePath:    base.DeployerImagePath,
+		OutputField:          base.OutputField,
+		VariablesManifest:    base.VariablesManifest,
+		DryRun:               base.DryRun || override.DryRun,
+		CheckOnly:            base.CheckOnly || override.CheckOnly,
+		Backup:               base.Backup || override.Backup,
+		Lock:                 base.Lock || override.Lock,
+		LockTimeout:          base.LockTimeout,
+		PreHook:              base.PreHook,
+		PostHook:             base.PostHook,
+		ValidateEnums:        base.ValidateEnums || override.ValidateEnums,
+		AllowPartialEnums:    base.AllowPartialEnums || override.AllowPartialEnums,
+		ValidateImageURIs:    base.ValidateImageURIs || override.ValidateImageURIs,
+		Recursive:            base.Recursive || override.Recursive,
+		Strict:               base.Strict || override.Strict,
+		RejectDuplicates:     base.RejectDuplicates || override.RejectDuplicates,
+		AddMissing:           base.AddMissing || override.AddMissing,
+		AllowSensitive:       base.AllowSensitive || override.AllowSensitive,
+		CheckValidations:     base.CheckValidations || override.CheckValidations,
+		CollectErrors:        base.CollectErrors || override.CollectErrors,
+		CaseInsensitiveNames: base.CaseInsensitiveNames || override.CaseInsensitiveNames,
+		ForceConsumerLabel:   base.ForceConsumerLabel || override.ForceConsumerLabel,
+		ReplaceModuleSources: base.ReplaceModuleSources || override.ReplaceModuleSources,
+		RequireUTF8:          base.RequireUTF8 || override.RequireUTF8,
+
+		NewValues:        mergeStringMaps(base.NewValues, override.NewValues),
+		Expected:         mergeStringMaps(base.Expected, override.Expected),
+		ConsumerLabels:   mergeStringMaps(base.ConsumerLabels, override.ConsumerLabels),
+		Replacements:     mergeStringMaps(base.Replacements, override.Replacements),
+		Digests:          mergeStringMaps(base.Digests, override.Digests),
+		ProjectRemap:     mergeStringMaps(base.ProjectRemap, override.ProjectRemap),
+		MetadataFields:   mergeStringMaps(base.MetadataFields, override.MetadataFields),
+		ProviderVersions: mergeStringMaps(base.ProviderVersions, override.ProviderVersions),
+		ModuleSources:    mergeStringMaps(base.ModuleSources, override.ModuleSources),
+		Descriptions:     mergeStringMaps(base.Descriptions, override.Descriptions),
+		VarTypes:         mergeStringMaps(base.VarTypes, override.VarTypes),
+		DisplayTitles:    mergeStringMaps(base.DisplayTitles, override.DisplayTitles),
+		ImageProjects:    mergeStringMaps(base.ImageProjects, override.ImageProjects),
+		OutputValues:     mergeStringMaps(base.OutputValues, override.OutputValues),
+
+		Variables:          unionStrings(base.Variables, override.Variables),
+		VariableTypes:      unionStrings(base.VariableTypes, override.VariableTypes),
+		AllowDuplicates:    unionStrings(base.AllowDuplicates, override.AllowDuplicates),
+		MetadataFiles:      unionStrings(base.MetadataFiles, override.MetadataFiles),
+		DisplayFiles:       unionStrings(base.DisplayFiles, override.DisplayFiles),
+		ValuesFiles:        unionStrings(base.ValuesFiles, override.ValuesFiles),
+		Outputs:            unionStrings(base.Outputs, override.Outputs),
+		ResourceAttributes: unionStrings(base.ResourceAttributes, override.ResourceAttributes),
+	}
+	if override.ConsumerLabel != "" {
+		merged.ConsumerLabel = override.ConsumerLabel
+	}
+	if override.ConsumerLabelKey != "" {
+		merged.ConsumerLabelKey = override.ConsumerLabelKey
+	}
+	if override.DeployerImagePath != "" {
+		merged.DeployerImagePath = override.DeployerImagePath
+	}
+	if override.OutputField != "" {
+		merged.OutputField = override.OutputField
+	}
+	if len(override.PreHook) > 0 {
+		merged.PreHook = override.PreHook
+	}
+	if len(override.PostHook) > 0 {
+		merged.PostHook = override.PostHook
+	}
+	if override.LockTimeout != "" {
+		merged.LockTimeout = override.LockTimeout
+	}
+	if override.VariablesManifest != "" {
+		merged.VariablesManifest = override.VariablesManifest
+	}
+	if !override.ModifiedSince.IsZero() {
+		merged.ModifiedSince = override.ModifiedSince
+	}
+
+	merged.RegexReplacements = append(merged.RegexReplacements, override.RegexReplacements...)
+	merged.RegexReplacements = append(merged.RegexReplacements, base.RegexReplacements...)
+	merged.Scopes = append(merged.Scopes, base.Scopes...)
+	merged.Scopes = append(merged.Scopes, override.Scopes...)
+
+	if base.DisplayLabels != nil || override.DisplayLabels != nil {
+		merged.DisplayLabels = map[string]map[string]string{}
+		for _, labels := range []map[string]map[string]string{base.DisplayLabels, override.DisplayLabels} {
+			for varName, varLabels := range labels {
+				merged.DisplayLabels[varName] = mergeStringMaps(merged.DisplayLabels[varName], varLabels)
+			}
+		}
+	}
+
+	if base.DisplayProperties != nil || override.DisplayProperties != nil {
+		merged.DisplayProperties = map[string]map[string]interface{}{}
+		for _, properties := range []map[string]map[string]interface{}{base.DisplayProperties, override.DisplayProperties} {
+			for varName, varProperties := range properties {
+				if merged.DisplayProperties[varName] == nil {
+					merged.DisplayProperties[varName] = map[string]interface{}{}
+				}
+				for property, value := range varProperties {
+					merged.DisplayProperties[varName][property] = value
+				}
+			}
+		}
+	}
+
+	if base.DisplayNumbers != nil || override.DisplayNumbers != nil {
+		merged.DisplayNumbers = map[string]map[string]float64{}
+		for _, numbers := range []map[string]map[string]float64{base.DisplayNumbers, override.DisplayNumbers} {
+			for varName, varNumbers := range numbers {
+				if merged.DisplayNumbers[varName] == nil {
+					merged.DisplayNumbers[varName] = map[string]float64{}
+				}
+				for property, value := range varNumbers {
+					merged.DisplayNumbers[varName][property] = value
+				}
+			}
+		}
+	}
+
+	return merged
+}
+
+// mergeStringMaps returns a copy of base with the entries of override added,
+// or nil if both are nil
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if base == nil && override == nil {
+		return nil
+	}
+	merged := map[string]string{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+// unionStrings returns the strings of base followed by the strings of override
+// not in base, or nil if both are empty
+func unionStrings(base, override []string) []string {
+	var union []string
+	seen := map[string]bool{}
+	for _, list := range [][]string{base, override} {
+		for _, s := range list {
+			if !seen[s] {
+				seen[s] = true
+				union = append(union, s)
+			}
+		}
+	}
+	return union
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeOverwriteConfigs(t *testing.T) {
+	testcases := []struct {
+		name           string
+		base           *overwriteConfig
+		override       *overwriteConfig
+		expectedConfig *overwriteConfig
+	}{{
+		name: "Override wins per key and lists are unioned",
+		base: &overwriteConfig{
+			ConsumerLabel: "base-label",
+			Variables:     []string{"source_image", "db_image"},
+			Replacements: map[string]string{
+				"old-image":    "base-image",
+				"old-db-image": "base-db-image",
+			},
+			RegexReplacements: []RegexRule{{Pattern: "^base", Replacement: "base"}},
+			DisplayLabels: map[string]map[string]string{
+				"source_image": {"wordpress-1": "Base 1", "wordpress-2": "Base 2"},
+			},
+		},
+		override: &overwriteConfig{
+			ConsumerLabel: "override-label",
+			Recursive:     true,
+			Variables:     []string{"db_image", "web_image"},
+			Replacements: map[string]string{
+				"old-image":     "override-image",
+				"old-web-image": "override-web-image",
+			},
+			RegexReplacements: []RegexRule{{Pattern: "^override", Replacement: "override"}},
+			DisplayLabels: map[string]map[string]string{
+				"source_image": {"wordpress-2": "Override 2"},
+			},
+		},
+		expectedConfig: &overwriteConfig{
+			ConsumerLabel: "override-label",
+			Recursive:     true,
+			Variables:     []string{"source_image", "db_image", "web_image"},
+			Replacements: map[string]string{
+				"old-image":     "override-image",
+				"old-db-image":  "base-db-image",
+				"old-web-image": "override-web-image",
+			},
+			RegexReplacements: []RegexRule{
+				{Pattern: "^override", Replacement: "override"},
+				{Pattern: "^base", Replacement: "base"},
+			},
+			DisplayLabels: map[string]map[string]string{
+				"source_image": {"wordpress-1": "Base 1", "wordpress-2": "Override 2"},
+			},
+		},
+	}, {
+		name: "Empty override keeps base",
+		base: &overwriteConfig{
+			ConsumerLabel: "base-label",
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		override: &overwriteConfig{},
+		expectedConfig: &overwriteConfig{
+			ConsumerLabel: "base-label",
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+	}, {
+		name: "Metadata varTypes are merged per key",
+		base: &overwriteConfig{
+			VarTypes: map[string]string{
+				"machine_count": "string",
+				"zones":         "list(string)",
+			},
+		},
+		override: &overwriteConfig{
+			VarTypes: map[string]string{
+				"machine_count": "number",
+			},
+		},
+		expectedConfig: &overwriteConfig{
+			VarTypes: map[string]string{
+				"machine_count": "number",
+				"zones":         "list(string)",
+			},
+		},
+	}, {
+		name: "Nil base",
+		override: &overwriteConfig{
+			Strict: true,
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		expectedConfig: &overwriteConfig{
+			Strict: true,
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged := MergeOverwriteConfigs(tc.base, tc.override)
+			assert.Equal(t, tc.expectedConfig, merged)
+		})
+	}
+}
+
+func TestMergeOverwriteConfigsDoesNotModifyInputs(t *testing.T) {
+	base := &overwriteConfig{Replacements: map[string]string{"old-image": "base-image"}}
+	override := &overwriteConfig{Replacements: map[string]string{"old-image": "override-image"}}
+
+	merged := MergeOverwriteConfigs(base, override)
+	merged.Replacements["other-image"] = "new-image"
+
+	assert.Equal(t, map[string]string{"old-image": "base-image"}, base.Replacements)
+	assert.Equal(t, map[string]string{"old-image": "override-image"}, override.Replacements)
+}
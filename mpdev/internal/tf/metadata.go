@@ -0,0 +1,163 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"sigs.k8s.io/yaml"
+)
+
+// varTypes are the Terraform types allowed as the varType of a variable in
+// Blueprints Metadata
+var varTypes = []string{"string", "number", "bool", "list", "map", "set", "object", "tuple", "any"}
+
+// templateReferencePattern matches template references such as ${var.project}
+// in metadata default values
+var templateReferencePattern = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// isTemplatedValue returns whether a metadata default value references other
+// values, such as `${var.project}/image`
+func isTemplatedValue(value string) bool {
+	return templateReferencePattern.MatchString(value)
+}
+
+// isValidVarType returns whether varType is one of the varTypes, or a
+// parameterized type built on one of them such as list(string)
+func isValidVarType(varType string) bool {
+	baseType := varType
+	if i := strings.Index(varType, "("); i != -1 {
+		if !strings.HasSuffix(varType, ")") {
+			return false
+		}
+		baseType = varType[:i]
+	}
+	for _, allowed := range varTypes {
+		if baseType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// convertMetadataValue converts a value from the overwrite config to the
+// `varType` of a variable in Blueprints Metadata. Numbers and bools are
+// unquoted, and lists are parsed from either a JSON array or a comma
+// separated string. Other types are left as strings.
+func convertMetadataValue(value string, varType string) (interface{}, error) {
+	varType = strings.TrimSpace(varType)
+	switch {
+	case varType == "number":
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("value: %s is not a valid number", value)
+		}
+		return number, nil
+	case varType == "bool":
+		boolean, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("value: %s is not a valid bool", value)
+		}
+		return boolean, nil
+	case strings.HasPrefix(varType, "list(") && strings.HasSuffix(varType, ")"):
+		elementType := varType[len("list(") : len(varType)-1]
+
+		var elements []string
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "[") {
+			var jsonElements []interface{}
+			err := json.Unmarshal([]byte(trimmed), &jsonElements)
+			if err != nil {
+				return nil, fmt.Errorf("value: %s is not a valid JSON list. error: %w", value, err)
+			}
+			for _, jsonElement := range jsonElements {
+				elements = append(elements, fmt.Sprint(jsonElement))
+			}
+		} else if trimmed != "" {
+			for _, element := range strings.Split(trimmed, ",") {
+				elements = append(elements, strings.TrimSpace(element))
+			}
+		}
+
+		list := []interface{}{}
+		for _, element := range elements {
+			converted, err := convertMetadataValue(element, elementType)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	default:
+		return value, nil
+	}
+}
+
+// metadataVariable is a variable entry under spec.interfaces.variables in
+// Blueprints Metadata
+type metadataVariable struct {
+	Name         string
+	VarType      string
+	DefaultValue interface{}
+}
+
+// getMetadataVariables returns the variables declared in Blueprints Metadata
+func getMetadataVariables(data []byte) ([]metadataVariable, error) {
+	json, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing %s error: %w", metadataFile, err)
+	}
+
+	var variables []metadataVariable
+	for _, entry := range gjson.GetBytes(json, "spec.interfaces.variables").Array() {
+		variables = append(variables, metadataVariable{
+			Name:         entry.Get("name").String(),
+			VarType:      entry.Get("varType").String(),
+			DefaultValue: entry.Get("defaultValue").Value(),
+		})
+	}
+	return variables, nil
+}
+
+// StripDefaults removes the defaultValue of every variable under
+// spec.interfaces.variables in the Blueprints Metadata of dir, such as to
+// produce a distributable template. The rest of each variable entry and of the
+// file is left as is.
+func StripDefaults(dir string) error {
+	result := newOverwriteResult()
+
+	filename := path.Join(dir, metadataFile)
+	data, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	stripped, changed, err := removeMetadataVariableField(data, "defaultValue")
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("Removing the default values of the variables in %s\n", filename)
+		result.stageFile(filename, data, stripped)
+	}
+
+	return result.commit(&overwriteConfig{}, dir)
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overwriteMetadataFields sets the string fields at the dotted paths of
+// MetadataFields in Blueprints Metadata, such as apiVersion. A path that is not
+// found is an error unless AddMissing is set, in which case it is added along
+// with any missing parent mappings.
+func overwriteMetadataFields(config *overwriteConfig, data []byte, filename string) ([]byte, error) {
+	for _, fieldPath := range sortedKeys(config.MetadataFields) {
+		value := config.MetadataFields[fieldPath]
+
+		var root yaml.Node
+		err := yaml.Unmarshal(data, &root)
+		if err != nil {
+			return nil, fmt.Errorf("failure parsing %s error: %w", filename, err)
+		}
+
+		keyPath := strings.Split(fieldPath, ".")
+		node := findYAMLPathNode(&root, keyPath)
+		if node == nil && !config.AddMissing {
+			return nil, fmt.Errorf("metadata field: %s not found in %s", fieldPath, filename)
+		}
+		if node == nil {
+			fmt.Printf("Adding the metadata field: %s in %s\n", fieldPath, filename)
+			data, err = addYAMLPath(data, &root, keyPath, value)
+			if err != nil {
+				return nil, fmt.Errorf("error adding metadata field: %s. error: %w", fieldPath, err)
+			}
+			continue
+		}
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("metadata field: %s in %s must be a string", fieldPath, filename)
+		}
+		if node.Value == value {
+			continue
+		}
+
+		fmt.Printf("Replacing the metadata field: %s in %s\n", fieldPath, filename)
+		data, err = setYAMLScalar(data, &root, node, value)
+		if err != nil {
+			return nil, fmt.Errorf("error setting metadata field: %s. error: %w", fieldPath, err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+)
+
+// filterModifiedValues returns the values declared in files modified at or
+// after ModifiedSince. Unlike scopes, skipping every value of a variable is
+// not an error, since the variable was already found in the module.
+func filterModifiedValues(result *OverwriteResult, config *overwriteConfig,
+	values []*moduleValue) ([]*moduleValue, error) {
+	if config.ModifiedSince.IsZero() {
+		return values, nil
+	}
+
+	var modified []*moduleValue
+	for _, value := range values {
+		ok, err := isModifiedSince(result, config, value.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			fmt.Printf("Skipping %s: %s in %s not modified since %s\n",
+				value.kind(), value.Name, value.Filename, config.ModifiedSince)
+			continue
+		}
+		modified = append(modified, value)
+	}
+	return modified, nil
+}
+
+// isModifiedSince returns whether filename was modified at or after
+// ModifiedSince. Files without a modification time, such as those read from
+// memory, are always considered modified.
+func isModifiedSince(result *OverwriteResult, config *overwriteConfig, filename string) (bool, error) {
+	if config.ModifiedSince.IsZero() {
+		return true, nil
+	}
+
+	info, err := result.fsys.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().IsZero() {
+		return true, nil
+	}
+	return !info.ModTime().Before(config.ModifiedSince), nil
+}
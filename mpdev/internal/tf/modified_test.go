@@ -0,0 +1,115 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteTfModifiedSince(t *testing.T) {
+	lastRelease := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		name            string
+		overwriteConfig overwriteConfig
+		expectedMainTf  string
+		expectedOldTf   string
+		errorContains   string
+	}{{
+		name: "Only files modified since the timestamp are overwritten",
+		overwriteConfig: overwriteConfig{
+			ModifiedSince: lastRelease,
+			Variables:     []string{"value_to_replace", "old_value"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		expectedMainTf: strings.Replace(mainTf, "original-value", "new-value", 1),
+		expectedOldTf:  oldTf,
+	}, {
+		name: "Values in skipped files are not validated",
+		overwriteConfig: overwriteConfig{
+			ModifiedSince: lastRelease,
+			Variables:     []string{"unmapped_value"},
+		},
+		expectedMainTf: mainTf,
+		expectedOldTf:  oldTf,
+	}, {
+		name: "Fail when variable is not declared in any file",
+		overwriteConfig: overwriteConfig{
+			ModifiedSince: lastRelease,
+			Variables:     []string{"missing_value"},
+		},
+		expectedMainTf: mainTf,
+		expectedOldTf:  oldTf,
+		errorContains:  "variable: missing_value not found",
+	}, {
+		name: "Every file is overwritten without a timestamp",
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace", "old_value"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		expectedMainTf: strings.Replace(mainTf, "original-value", "new-value", 1),
+		expectedOldTf:  strings.Replace(oldTf, "original-value", "new-value", 1),
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+			assert.NoError(t, err)
+			err = os.WriteFile(path.Join(tmpDir, "old.tf"), []byte(oldTf), 0600)
+			assert.NoError(t, err)
+			unchangedSince := lastRelease.AddDate(0, -1, 0)
+			err = os.Chtimes(path.Join(tmpDir, "old.tf"), unchangedSince, unchangedSince)
+			assert.NoError(t, err)
+
+			_, err = OverwriteTf(&tc.overwriteConfig, tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"main.tf": tc.expectedMainTf,
+				"old.tf":  tc.expectedOldTf,
+			}, actualContents)
+		})
+	}
+}
+
+var oldTf string = `
+variable "old_value" {
+  type    = string
+  default = "original-value"
+}
+
+variable "unmapped_value" {
+  type    = string
+  default = "unmapped-value"
+}
+`
@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+const moduleSourceConst = "source"
+
+// stageModuleSources adds the edits setting the source of module blocks in the
+// Terraform files of the module directories, such as to repoint a local module
+// to a registry source for publishing. Sources are set by module block name
+// using ModuleSources, and if ReplaceModuleSources is set, the sources of the
+// other module blocks are replaced using Replacements, Digests and
+// RegexReplacements. Other module arguments are preserved.
+func stageModuleSources(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, moduleDirs []string) error {
+	if len(config.ModuleSources) == 0 && !config.ReplaceModuleSources {
+		return nil
+	}
+	fmt.Printf("Replacing the sources of the modules: %s\n", config.ModuleSources)
+
+	found := map[string]bool{}
+	for _, moduleDir := range moduleDirs {
+		filenames, err := getModuleFilenames(result.fsys, moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, filename := range filenames {
+			if isTfJSONFile(filename) {
+				continue
+			}
+			err = stageModuleSourcesFile(result, edits, report, config, filename, found)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(config.ModuleSources) {
+		if !found[name] {
+			return newVariableError(ErrVariableNotFound, name,
+				"module: %s not found in Terraform files. Searched directories: %s", name, moduleDirs)
+		}
+	}
+	return nil
+}
+
+// stageModuleSourcesFile adds the edit setting the sources of the module
+// blocks of a file, and marks the modules named in ModuleSources as found
+func stageModuleSourcesFile(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, filename string, found map[string]bool) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	modified, err := isModifiedSince(result, config, filename)
+	if err != nil {
+		return err
+	}
+
+	sources := map[string]string{}
+	for _, block := range getModuleBlocks(file) {
+		name := block.Labels()[0]
+		if _, ok := config.ModuleSources[name]; ok {
+			found[name] = true
+		}
+		if !modified {
+			continue
+		}
+
+		attribute := block.Body().GetAttribute(moduleSourceConst)
+		if attribute == nil {
+			continue
+		}
+		source, ok := getStringLiteral(attribute)
+		if !ok {
+			return newVariableError(ErrWrongType, name,
+				"source of module: %s in %s must be a string", name, filename)
+		}
+
+		newSource, ok, err := config.moduleSourceFor(name, source)
+		if err != nil {
+			return err
+		}
+		if !ok && config.ReplaceModuleSources && config.Strict && !config.isReplacementTarget(source) {
+			return newVariableError(ErrReplacementNotFound, name,
+				"source: %s of module: %s in %s not found in replacements", source, name, filename)
+		}
+		if !ok || newSource == source {
+			continue
+		}
+
+		sources[name] = newSource
+		report.Changes = append(report.Changes, VariableChange{
+			File:       filename,
+			Variable:   "module." + name,
+			OldDefault: source,
+			NewDefault: newSource,
+		})
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	edits.add(filename, func(result *OverwriteResult) error {
+		return overwriteModuleSourcesFile(result, filename, sources)
+	})
+	return nil
+}
+
+// moduleSourceFor returns the new source of the named module block. The source
+// set in ModuleSources takes precedence over a replacement of the current one.
+func (config *overwriteConfig) moduleSourceFor(name string, source string) (string, bool, error) {
+	if newSource, ok := config.ModuleSources[name]; ok {
+		return newSource, true, nil
+	}
+	if !config.ReplaceModuleSources {
+		return "", false, nil
+	}
+	return config.replacementFor("module."+name, source)
+}
+
+// overwriteModuleSourcesFile sets the sources of the module blocks of a file
+// that are keys of sources
+func overwriteModuleSourcesFile(result *OverwriteResult, filename string, sources map[string]string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	for _, block := range getModuleBlocks(file) {
+		name := block.Labels()[0]
+		source, ok := sources[name]
+		if !ok {
+			continue
+		}
+		_, err = setStringAttribute(block.Body(), moduleSourceConst, source)
+		if err != nil {
+			return fmt.Errorf("failure overwriting source of module: %s error: %w", name, err)
+		}
+	}
+
+	result.stageFile(filename, b, file.BuildTokens(nil).Bytes())
+	return nil
+}
+
+// getModuleBlocks returns the `module "name"` blocks of file
+func getModuleBlocks(file *hclwrite.File) []*hclwrite.Block {
+	var blocks []*hclwrite.Block
+	for _, block := range file.Body().Blocks() {
+		if block.Type() == "module" && len(block.Labels()) == 1 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+)
+
+// defaultOutputField is the field of the entries under spec.interfaces.outputs
+// that is overwritten if OutputField is not set
+const defaultOutputField = "description"
+
+// overwriteMetadataOutputs overwrites the OutputField of the entries under
+// spec.interfaces.outputs in Blueprints Metadata. If OutputValues is set, the
+// field of each output in it is set to the new value. Otherwise the field of
+// each output in Outputs is replaced using the replacements. The JSON is the
+// metadata file converted from YAML, used to look up the current values.
+func overwriteMetadataOutputs(config *overwriteConfig, data []byte, json []byte,
+	filename string) ([]byte, error) {
+	if config.OutputValues == nil && len(config.Outputs) == 0 {
+		return data, nil
+	}
+	field := config.outputField()
+
+	if config.OutputValues != nil {
+		fmt.Printf("Replacing the %s of the outputs: %s in %s\n", field, config.OutputValues, filename)
+
+		for _, name := range sortedKeys(config.OutputValues) {
+			if !gjson.GetBytes(json, outputQuery(name)).Exists() {
+				return nil, newVariableError(ErrVariableNotFound, name,
+					"missing output entry for output: %s in %s", name, filename)
+			}
+
+			var err error
+			data, err = setMetadataEntryField(data, "outputs", name, field, config.OutputValues[name])
+			if err != nil {
+				return nil, fmt.Errorf("error setting %s of output: %s. error: %w", field, name, err)
+			}
+		}
+		return data, nil
+	}
+
+	fmt.Printf("Replacing the %s of the outputs: %s in %s\n", field, config.Outputs, filename)
+
+	for _, name := range config.Outputs {
+		output := gjson.GetBytes(json, outputQuery(name))
+		if !output.Exists() {
+			return nil, newVariableError(ErrVariableNotFound, name,
+				"missing output entry for output: %s in %s", name, filename)
+		}
+		currValue := output.Get(escapeJSONPath(field))
+		if currValue.Type != gjson.String {
+			return nil, newVariableError(ErrMissingDefault, name,
+				"missing string %s of output: %s in %s", field, name, filename)
+		}
+
+		replaceVal, ok, err := config.replacementFor(name, currValue.String())
+		if err != nil {
+			return nil, err
+		}
+		if !ok && config.isReplacementTarget(currValue.String()) {
+			continue
+		}
+		if !ok {
+			return nil, newVariableError(ErrReplacementNotFound, name,
+				"%s: %s of output: %s in %s not found in replacements",
+				field, currValue.String(), name, filename)
+		}
+
+		data, err = setMetadataEntryField(data, "outputs", name, field, replaceVal)
+		if err != nil {
+			return nil, fmt.Errorf("error setting %s of output: %s. error: %w", field, name, err)
+		}
+	}
+	return data, nil
+}
+
+// outputQuery returns the gjson query of the entry for an output under
+// spec.interfaces.outputs
+func outputQuery(name string) string {
+	return fmt.Sprintf(`spec.interfaces.outputs.#(name=="%s")`, name)
+}
+
+// outputField returns the OutputField, or the description if it is not set
+func (config *overwriteConfig) outputField() string {
+	if config.OutputField == "" {
+		return defaultOutputField
+	}
+	return config.OutputField
+}
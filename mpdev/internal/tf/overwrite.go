@@ -15,38 +15,358 @@
 package tf
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/BurntSushi/toml"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
-	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
-	"sigs.k8s.io/yaml"
-
 	"github.com/zclconf/go-cty/cty"
+	"sigs.k8s.io/yaml"
 
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 const mainTfFile = "main.tf"
 const metadataFile = "metadata.yaml"
 const metadataDisplayFile = "metadata.display.yaml"
 
-const defaultLabelsConst = "default_labels"
-const consumerLabelConst = "goog-partner-solution"
-
 type overwriteConfig struct {
 	ConsumerLabel string
 
+	// ConsumerLabelKey is the key of the consumer label in the default labels
+	// of the provider. It defaults to goog-partner-solution.
+	ConsumerLabelKey string
+
+	// ConsumerLabels are additional labels inserted into the default labels
+	// of the provider alongside ConsumerLabel. As with ConsumerLabel, labels
+	// that are already present are not overwritten.
+	ConsumerLabels map[string]string
+
+	// If ForceConsumerLabel is set, the values of ConsumerLabel and
+	// ConsumerLabels replace those of labels that are already present, such as
+	// when a module is re-attributed. Other default labels are preserved.
+	ForceConsumerLabel bool
+
+	// If DryRun is set, the proposed changes are returned without writing files.
+	DryRun bool
+
+	// If CheckOnly is set, no files are written and an error wrapping
+	// ErrWouldChange is returned if the overwrite would change any file, so
+	// that CI can check that a module is already overwritten.
+	CheckOnly bool
+
+	// Scopes limit the overwrite of variables in Terraform files to the files
+	// matching a filename or glob. Variables without a scope are overwritten
+	// in every file declaring them.
+	Scopes []VariableScope
+
+	// If ModifiedSince is set, OverwriteTf only overwrites the Terraform files
+	// modified at or after it, so that incremental builds leave unchanged
+	// modules alone. Variables must still be declared in some file of the
+	// module, but the values in skipped files are not validated otherwise.
+	ModifiedSince time.Time
+
+	// If RejectDuplicates is set, a variable declared in more than one file is
+	// an error unless it is listed in AllowDuplicates.
+	RejectDuplicates bool
+	AllowDuplicates  []string
+
+	// Variables marked `sensitive = true` are not overwritten, so that secrets
+	// are not written to the module as plaintext defaults, unless
+	// AllowSensitive is set.
+	AllowSensitive bool
+
+	// If RequireUTF8 is set, every Terraform and Blueprints Metadata file is
+	// checked to be valid UTF-8 text before it is overwritten, so that binary
+	// files that landed in a module by accident are reported by name rather
+	// than as parse errors.
+	RequireUTF8 bool
+
+	// If CheckValidations is set, the conditions of the validation blocks of
+	// a variable are evaluated against its new string default, and a failed
+	// condition is an error. Conditions using functions other than a few
+	// string and collection functions, such as regex, are skipped.
+	CheckValidations bool
+
+	// If AddMissing is set, variables in NewValues without an entry in
+	// Blueprints Metadata are added with varType string instead of failing.
+	// Paths of MetadataFields that are not found are likewise added.
+	AddMissing bool
+
+	// VarTypes sets the varType of variables in Blueprints Metadata, such as
+	// number or list(string). New default values are converted to the new
+	// varType. Like Descriptions, it applies whether or not NewValues is set.
+	VarTypes map[string]string
+
+	// DeployerImagePath is the dotted path of a deployer image reference in
+	// Blueprints Metadata, such as spec.deployerSpec.image. If it is set, the
+	// reference is replaced using Replacements, Digests and RegexReplacements.
+	// Like Descriptions, it applies whether or not NewValues is set.
+	DeployerImagePath string
+
+	// MetadataFields sets string fields of Blueprints Metadata at dotted paths,
+	// such as apiVersion, so that schema migrations can be made along with
+	// the overwrite. A path that is not found is an error unless AddMissing is
+	// set. Like Descriptions, it applies whether or not NewValues is set.
+	MetadataFields map[string]string
+
+	// ResourceAttributes are dotted paths of string attributes of resource
+	// blocks in Terraform files, such as
+	// google_compute_instance_template.disk.source_image. The first part is the
+	// resource type, the last part is the attribute and any parts in between
+	// are the types of nested blocks. String literal values of the attributes
+	// are replaced using Replacements, Digests and RegexReplacements. Like
+	// Descriptions, it applies whether or not NewValues is set.
+	ResourceAttributes []string
+
+	// ProviderVersions maps the names of providers in the required_providers
+	// blocks of Terraform files, such as google, to the version constraints
+	// they are pinned to, such as "~> 5.0". A provider that is not declared in
+	// any file is an error. Like Descriptions, it applies whether or not
+	// NewValues is set.
+	ProviderVersions map[string]string
+
+	// ModuleSources maps the names of module blocks in Terraform files to the
+	// sources they are repointed to, such as a registry source replacing a
+	// local path for publishing. A module that is not declared in any file is
+	// an error. If ReplaceModuleSources is set, the string literal sources of
+	// the other module blocks are replaced using Replacements, Digests and
+	// RegexReplacements, and sources without a replacement are skipped unless
+	// Strict is set. Like Descriptions, both apply whether or not NewValues is
+	// set.
+	ModuleSources        map[string]string
+	ReplaceModuleSources bool
+
+	// OutputField is the field of the entries under spec.interfaces.outputs in
+	// Blueprints Metadata, such as description, that is overwritten for the
+	// outputs in OutputValues or Outputs. It defaults to description.
+	// OutputValues sets the field of outputs to new values. Otherwise, the
+	// field of each output in Outputs is replaced using Replacements. Like
+	// Descriptions, they apply whether or not NewValues is set.
+	OutputField  string
+	OutputValues map[string]string
+	Outputs      []string
+
+	// Descriptions sets the description of variables in Blueprints Metadata.
+	// Unlike Variables and Replacements, it applies whether or not NewValues
+	// is set.
+	Descriptions map[string]string
+
+	// DisplayTitles sets the title of variables in the Blueprints Metadata
+	// display file.
+	DisplayTitles map[string]string
+
+	// DisplayLabels rewrites the enumValueLabels label text of variables in the
+	// Blueprints Metadata display file. It maps each variable to a map from the
+	// current label to the new label, so each enum entry can be addressed.
+	DisplayLabels map[string]map[string]string
+
+	// DisplayProperties sets boolean, string or number properties of variables
+	// in the Blueprints Metadata display file, such as required or
+	// xGoogleProperty.type. It maps each variable to a map from the dotted
+	// path of each property to its new value. A variable or property that is
+	// absent is an error. Like DisplayTitles, it applies whether or not
+	// NewValues is set.
+	DisplayProperties map[string]map[string]interface{}
+
+	// DisplayNumbers sets the numeric default, min and max within the
+	// xGoogleProperty of variables in the Blueprints Metadata display file,
+	// such as a disk size and its bounds. It maps each variable to a map from
+	// default, min or max to its new value. Absent properties are added, and
+	// the default must lie within the bounds. Like DisplayTitles, it applies
+	// whether or not NewValues is set.
+	DisplayNumbers map[string]map[string]float64
+
+	// ImageProjects repoints the xGoogleProperty imageProject of display
+	// variables with type ET_GCE_DISK_IMAGE, mapping each current project to
+	// its new project. Like DisplayTitles, it applies whether or not NewValues
+	// is set. The image enum values of such variables must be in the new
+	// project.
+	ImageProjects map[string]string
+
+	// MetadataFiles and DisplayFiles are the names of the Blueprints Metadata
+	// and display files within the module, such as metadata.autogen.yaml.
+	// They default to metadata.yaml and metadata.display.yaml.
+	MetadataFiles []string
+	DisplayFiles  []string
+
+	// ValuesFiles are the paths of the Helm values files within the module
+	// overwritten by OverwriteValuesYaml. They default to chart/values.yaml.
+	ValuesFiles []string
+
+	// If ValidateImageURIs is set, the replaced enum values of display
+	// variables with type ET_GCE_DISK_IMAGE must be GCE image URIs of the form
+	// projects/{project}/global/images/{image}.
+	ValidateImageURIs bool
+
+	// If AllowPartialEnums is set, enum values of display variables without a
+	// replacement are left as is instead of failing.
+	AllowPartialEnums bool
+
+	// If ValidateEnums is set, OverwriteAll and OverwriteDisplay check that the
+	// metadata default value of each variable with display enumValueLabels is
+	// one of the enum values.
+	ValidateEnums bool
+
+	// If Lock is set, files are written while holding a lock file in the
+	// module directory, so that concurrent overwrites of the same module are
+	// serialized instead of interleaving their writes. A file changed by
+	// another overwrite since it was read is then an error, so the overwrite
+	// can be retried. LockTimeout is how long to wait for the lock, such as
+	// 1m, and defaults to 30s.
+	Lock        bool
+	LockTimeout string
+
+	// PreHook and PostHook are commands and their arguments, such as
+	// ["terraform", "fmt"], run in the module directory before the files of
+	// an overwrite are read and after they are written. Commands are not run
+	// in a shell, so a hook using shell syntax must be run with ["sh", "-c",
+	// ...]. The combined output of a hook is printed. A failing hook is an
+	// error: a failing PreHook stops the overwrite, while the files are
+	// already written when the PostHook fails. Hooks do not run if DryRun or
+	// CheckOnly is set.
+	PreHook  []string
+	PostHook []string
+
+	// If Backup is set, the original contents of each changed file are copied
+	// to a sibling file with a .orig extension before it is overwritten.
+	Backup bool
+
+	// If Recursive is set, variables are also overwritten in modules nested
+	// in subdirectories, and in the Blueprints Metadata files of any
+	// subdirectory.
+	Recursive bool
+
+	// If CaseInsensitiveNames is set, Variables and NewValues match declared
+	// variables regardless of case. Declared variables differing only by case
+	// are then an error. Variable patterns remain case-sensitive.
+	CaseInsensitiveNames bool
+
+	// If CollectErrors is set, OverwriteTf does not stop at the first variable
+	// that is not found, has no default, has the wrong type or has no
+	// replacement. The problems with every variable are instead returned
+	// together, joined by errors.Join, and no files are written.
+	CollectErrors bool
+
+	// If Strict is set, ambiguous configs fail validation instead of
+	// printing a warning, and strings in list or map defaults and templated
+	// metadata defaults without a replacement are an error instead of being
+	// left as is.
+	Strict bool
+
+	// NewValues sets the default values of variables. A dotted key path such
+	// as `infra.image` sets a single string within an object or map default
+	// in Terraform files. Key paths are skipped in the metadata files.
 	NewValues map[string]string
 
+	// VariablesManifest is the path of a JSON or YAML file mapping logical
+	// names to variable names, relative to the module directory unless it is
+	// absolute. If it is set, NewValues and Expected are keyed by logical
+	// name, so that release configs don't depend on the naming of variables,
+	// and a logical name without a mapping is an error.
+	VariablesManifest string
+
+	// Expected maps names in NewValues to the value their default is expected
+	// to have before it is overwritten. A variable with a different default is
+	// an error, unless it already equals the new value, so that a value changed
+	// since the config was written is not clobbered.
+	Expected map[string]string
+
 	// Deprecated. If NewValues is specified, the following have no effect.
-	Variables    []string
+	// Variables may contain glob patterns such as `image_*`, which are expanded
+	// against the variables declared in each file. Like an explicit variable
+	// name, a pattern that matches no variables is an error.
+	Variables []string
+	// VariableTypes selects the variables declared with one of the types, such
+	// as string or list, in addition to Variables. A type also matches the
+	// parameterized types built on it, so list matches list(string). The
+	// defaults of the selected variables without a replacement are left as
+	// is, and sensitive variables are skipped.
+	VariableTypes []string
+	// Values that already equal one of the replacement values are left as is,
+	// so that re-running an overwrite is a no-op. Templated metadata default
+	// values such as `${var.project}/image` are matched literally, and are
+	// skipped with a warning if they have no replacement.
 	Replacements map[string]string
+
+	// Digests replaces the digest of container image references pinned to a
+	// digest, such as gcr.io/proj/app:1.2.3@sha256:<hex>, keyed by image
+	// repository. The tag and repository of the reference are kept. Digests
+	// are applied to values not found in Replacements.
+	Digests map[string]string
+
+	// ProjectRemap replaces the project of GCE image URIs, such as
+	// projects/click-to-deploy-images/global/images/wordpress, keyed by the
+	// current project. It applies to values not found in Replacements or
+	// Digests, in Terraform, metadata and display files alike, so that images
+	// moved to a new project need not be listed one by one.
+	ProjectRemap map[string]string
+
+	// RegexReplacements are applied in order to values not found in
+	// Replacements. The first rule with a matching pattern is used.
+	RegexReplacements []RegexRule
+
+	// usedReplacements records the Replacements keys that were applied or
+	// already applied, if it is not nil
+	usedReplacements map[string]bool
+
+	// transform replaces the static replacements if it is not nil
+	transform TransformFunc
+}
+
+// RegexRule replaces the matches of Pattern in a value with Replacement.
+// Replacement may refer to submatches of Pattern such as `${1}`.
+type RegexRule struct {
+	Pattern     string
+	Replacement string
+}
+
+// replacementFor returns the replacement for a value of the named variable from
+// Replacements or, if the value is not found there, from Digests, ProjectRemap
+// or the first matching RegexReplacements rule. If a transform is set, the
+// replacement is instead the value it returns.
+func (config *overwriteConfig) replacementFor(name string, value string) (string, bool, error) {
+	if config.transform != nil {
+		return config.transformFor(name, value)
+	}
+
+	if replaceVal, ok := config.Replacements[value]; ok {
+		config.markReplacementUsed(value)
+		return replaceVal, true, nil
+	}
+
+	if replaceVal, ok := config.digestReplacementFor(value); ok {
+		return replaceVal, true, nil
+	}
+
+	if replaceVal, ok := config.projectReplacementFor(value); ok {
+		return replaceVal, true, nil
+	}
+
+	for _, rule := range config.RegexReplacements {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid regex replacement pattern: %s error: %w",
+				rule.Pattern, err)
+		}
+		if re.MatchString(value) {
+			return re.ReplaceAllString(value, rule.Replacement), true, nil
+		}
+	}
+	return "", false, nil
 }
 
 type EnumValueLabel struct {
@@ -55,95 +375,954 @@ type EnumValueLabel struct {
 }
 
 // OverwriteTf replaces default variable values in Terraform modules
-func OverwriteTf(config *overwriteConfig, dir string) error {
+func OverwriteTf(config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	return OverwriteTfContext(context.Background(), config, dir)
+}
+
+// OverwriteTfContext is like OverwriteTf but stops with ctx.Err() if ctx is
+// done before all files have been processed. No files are written in that case.
+func OverwriteTfContext(ctx context.Context, config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	result, _, err := overwriteTf(ctx, config, dir)
+	return result, err
+}
+
+func overwriteTf(ctx context.Context, config *overwriteConfig, dir string) (*OverwriteResult, *ChangeReport, error) {
+	err := config.runPreHook(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := newOverwriteResult()
+	report := &ChangeReport{}
+
+	err = stageTf(ctx, result, report, config, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = result.commit(config, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Println("Successfully replaced default values in tf files")
+	return result, report, nil
+}
+
+// stageTf stages the overwrites of a Terraform module in result without
+// writing any files
+func stageTf(ctx context.Context, result *OverwriteResult, report *ChangeReport,
+	config *overwriteConfig, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	config, err := config.withVariablesManifest(result, dir)
+	if err != nil {
+		return err
+	}
+
+	moduleDirs, err := getModuleDirs(result.fsys, dir, config.Recursive)
+	if err != nil {
+		return err
+	}
 
-	upsertErr := upsertConsumerLabel(dir, config.ConsumerLabel)
+	err = checkModuleFiles(result.fsys, dir, moduleDirs)
+	if err != nil {
+		return err
+	}
+
+	err = checkModuleUTF8(result, config, moduleDirs)
+	if err != nil {
+		return err
+	}
+
+	upsertErr := upsertConsumerLabel(result, dir, config.consumerLabels(), config.ForceConsumerLabel)
 	if upsertErr != nil {
 		return upsertErr
 	}
 
+	scan, err := scanModules(ctx, result, moduleDirs)
+	if err != nil {
+		return err
+	}
+	edits := newFileEdits()
+
+	var names []string
+	if config.CaseInsensitiveNames {
+		names = scan.variableNames()
+	}
+
+	// errs are the problems with single variables collected if CollectErrors
+	// is set
+	var errs []error
+
 	if config.NewValues != nil {
 		fmt.Printf("Replacing the default values of the variables: %s\n", config.NewValues)
 
-		for varName, newValue := range config.NewValues {
-			varInfo, err := getVarInfo(varName, dir)
+		for _, configName := range sortedKeys(config.NewValues) {
+			err := stageNewValue(ctx, result, report, edits, config, dir, scan, names, configName)
+			if err != nil && !config.collects(err) {
+				return err
+			} else if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	} else {
+		fmt.Printf("Replacing the default values of the variables: %s\n", config.Variables)
+		fmt.Printf("Mapping of values to replace: %s\n", config.Replacements)
+
+		variables := config.Variables
+		if hasVariablePatterns(variables) {
+			variables, err = expandVariablePatterns(variables, scan.variableNames(), "module")
 			if err != nil {
 				return err
 			}
+		}
 
-			if varInfo.Type != "string" {
-				return fmt.Errorf("image variable: %s must be type string", varName)
+		for _, configName := range variables {
+			err := stageVariable(ctx, result, report, edits, config, dir, scan, names, configName)
+			if err != nil && !config.collects(err) {
+				return err
+			} else if err != nil {
+				errs = append(errs, err)
 			}
+		}
 
-			err = overwriteFile(varInfo.Pos.Filename, varName, newValue)
+		err = overwriteTypedVariables(ctx, result, edits, report, config, dir, scan, variables)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = stageResourceAttributes(result, edits, report, config, moduleDirs)
+	if err != nil && !config.collects(err) {
+		return err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = stageProviderVersions(result, edits, report, config, moduleDirs)
+	if err != nil && !config.collects(err) {
+		return err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = stageModuleSources(result, edits, report, config, moduleDirs)
+	if err != nil && !config.collects(err) {
+		return err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return edits.apply(ctx, result)
+}
+
+// stageNewValue adds the edits setting the default of the variable or key path
+// named configName in NewValues
+func stageNewValue(ctx context.Context, result *OverwriteResult, report *ChangeReport, edits *fileEdits,
+	config *overwriteConfig, dir string, scan *moduleScan, names []string, configName string) error {
+	newValue := config.NewValues[configName]
+	configVarName, keyPath := splitKeyPath(configName)
+	varName, err := config.resolveVariableName(configVarName, names)
+	if err != nil {
+		return err
+	}
+	values, err := scan.values(varName)
+	if err != nil {
+		return err
+	}
+	values, err = filterScopedValues(config, dir, varName, values)
+	if err != nil {
+		return err
+	}
+	values, err = filterModifiedValues(result, config, values)
+	if err != nil {
+		return err
+	}
+	err = checkDuplicateValues(config, varName, values)
+	if err != nil {
+		return err
+	}
+	report.Matched = append(report.Matched, varName)
+
+	for _, value := range values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := checkSensitiveValue(config, value); err != nil {
+			return err
+		}
+
+		if value.NonLiteral {
+			return newVariableError(ErrNonLiteralDefault, varName,
+				"variable: %s has a non-literal default, cannot overwrite", varName)
+		}
+
+		if err := checkExpectedValue(config, configName, value, keyPath); err != nil {
+			return err
+		}
+		checkNoOpNewValue(report, configName, value, keyPath, newValue)
+
+		if len(keyPath) > 0 {
+			err = overwriteKeyPathValue(edits, report, value, keyPath, newValue)
 			if err != nil {
 				return err
 			}
+			continue
+		}
+
+		if value.Type != "string" {
+			return newVariableError(ErrWrongType, varName,
+				"image %s: %s must be type string", value.kind(), varName)
+		}
+
+		if err := checkValidations(result, config, value, newValue); err != nil {
+			return err
 		}
-	} else {
-		fmt.Printf("Replacing the default values of the variables: %s\n", config.Variables)
-		fmt.Printf("Mapping of values to replace: %s\n", config.Replacements)
 
-		for _, varname := range config.Variables {
-			varInfo, err := getVarInfo(varname, dir)
+		overwriteValue(edits, report, value, newValue)
+	}
+	return nil
+}
+
+// stageVariable adds the edits replacing the default of the variable named
+// configName in Variables
+func stageVariable(ctx context.Context, result *OverwriteResult, report *ChangeReport, edits *fileEdits,
+	config *overwriteConfig, dir string, scan *moduleScan, names []string, configName string) error {
+	varname, err := config.resolveVariableName(configName, names)
+	if err != nil {
+		return err
+	}
+	values, err := scan.values(varname)
+	if err != nil {
+		return err
+	}
+	values, err = filterScopedValues(config, dir, varname, values)
+	if err != nil {
+		return err
+	}
+	values, err = filterModifiedValues(result, config, values)
+	if err != nil {
+		return err
+	}
+	err = checkDuplicateValues(config, varname, values)
+	if err != nil {
+		return err
+	}
+	report.Matched = append(report.Matched, varname)
+
+	for _, value := range values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := checkSensitiveValue(config, value); err != nil {
+			return err
+		}
+
+		if value.NonLiteral {
+			err = overwriteNonLiteralValue(result, edits, report, config, value)
 			if err != nil {
 				return err
 			}
+			continue
+		}
+
+		if value.Default == nil && !value.Local {
+			return newVariableError(ErrMissingDefault, varname,
+				"image variable: %s must have default value", varname)
+		}
+
+		defaultVal, ok := value.Default.(string)
+		if elements, isCollection := getCollectionStrings(value.Default); !ok && isCollection {
+			err = overwriteCollectionValue(edits, report, config, value, elements)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok {
+			return newVariableError(ErrWrongType, varname,
+				"image %s: %s must be type string", value.kind(), varname)
+		}
+
+		replaceVal, ok, err := config.replacementFor(varname, defaultVal)
+		if err != nil {
+			return err
+		}
+		if !ok && config.isReplacementTarget(defaultVal) {
+			// Already replaced by a previous run
+			report.Skipped = append(report.Skipped, value.Name)
+			continue
+		}
+		if !ok {
+			return newVariableError(ErrReplacementNotFound, varname,
+				"default value: %s of %s: %s not found in replacements",
+				defaultVal, value.kind(), varname)
+		}
+
+		if err := checkValidations(result, config, value, replaceVal); err != nil {
+			return err
+		}
+
+		overwriteValue(edits, report, value, replaceVal)
+	}
+	return nil
+}
+
+// overwriteTypedVariables applies the replacements to the defaults of the
+// variables with one of the VariableTypes, other than those already named in
+// variables. Unlike named variables, defaults without a replacement and
+// sensitive variables are skipped.
+func overwriteTypedVariables(ctx context.Context, result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, dir string, scan *moduleScan, variables []string) error {
+	if len(config.VariableTypes) == 0 {
+		return nil
+	}
+
+	named := map[string]bool{}
+	for _, varname := range variables {
+		named[varname] = true
+	}
+
+	typed := scan.variableNamesOfTypes(config.VariableTypes)
+	fmt.Printf("Replacing the default values of the variables of types: %s\n", config.VariableTypes)
+
+	for _, varname := range typed {
+		if named[varname] {
+			continue
+		}
+		values, err := scan.values(varname)
+		if err != nil {
+			return err
+		}
+		values, err = filterScopedValues(config, dir, varname, values)
+		if err != nil {
+			return err
+		}
+		values, err = filterModifiedValues(result, config, values)
+		if err != nil {
+			return err
+		}
+		report.Matched = append(report.Matched, varname)
+
+		for _, value := range values {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if value.Sensitive {
+				fmt.Printf("Skipping sensitive variable: %s in %s\n", varname, value.Filename)
+				report.Skipped = append(report.Skipped, varname)
+				continue
+			}
 
-			if varInfo.Default == nil {
-				return fmt.Errorf("image variable: %s must have default value", varname)
+			if elements, ok := getCollectionStrings(value.Default); ok {
+				// Elements without a replacement are left as is even if Strict is set
+				lenient := *config
+				lenient.Strict = false
+				err = overwriteCollectionValue(edits, report, &lenient, value, elements)
+				if err != nil {
+					return err
+				}
+				continue
 			}
 
-			defaultVal, ok := varInfo.Default.(string)
+			defaultVal, ok := value.Default.(string)
+			if !ok {
+				report.Skipped = append(report.Skipped, varname)
+				continue
+			}
+			replaceVal, ok, err := config.replacementFor(varname, defaultVal)
+			if err != nil {
+				return err
+			}
 			if !ok {
-				return fmt.Errorf("image variable: %s must be type string", varname)
+				report.Skipped = append(report.Skipped, varname)
+				continue
+			}
+			overwriteValue(edits, report, value, replaceVal)
+		}
+	}
+	return nil
+}
+
+// moduleValue is an overwritable value in a Terraform module. It is either the
+// default value of a variable or a named local value.
+type moduleValue struct {
+	Name     string
+	Filename string
+	Local    bool
+
+	// Type is the declared type of a variable. Local values have type string
+	// if they are a string literal.
+	Type    string
+	Default interface{}
+
+	// Sensitive is set for variables declared with `sensitive = true`
+	Sensitive bool
+
+	// NonLiteral is set for variables whose default is an expression that
+	// cannot be evaluated without a context. Default is nil in that case.
+	NonLiteral bool
+}
+
+func (v *moduleValue) kind() string {
+	if v.Local {
+		return "local"
+	}
+	return "variable"
+}
+
+// checkSensitiveValue returns an error if the value is the default of a
+// sensitive variable, unless AllowSensitive is set, in which case a warning is
+// printed instead
+func checkSensitiveValue(config *overwriteConfig, moduleVal *moduleValue) error {
+	if !moduleVal.Sensitive {
+		return nil
+	}
+	if !config.AllowSensitive {
+		return newVariableError(ErrSensitiveVariable, moduleVal.Name,
+			"variable: %s is marked sensitive. Set allowSensitive to overwrite its default value",
+			moduleVal.Name)
+	}
+	fmt.Printf("Warning: overwriting the default value of sensitive variable: %s in %s\n",
+		moduleVal.Name, moduleVal.Filename)
+	return nil
+}
+
+// checkExpectedValue returns an error if the current value of a NewValues entry
+// differs from both its Expected value and its new value
+func checkExpectedValue(config *overwriteConfig, configName string, moduleVal *moduleValue,
+	keyPath []string) error {
+	expected, ok := config.Expected[configName]
+	if !ok {
+		return nil
+	}
+	current, _ := getKeyPathValue(moduleVal.Default, keyPath)
+	if current == expected || current == config.NewValues[configName] {
+		return nil
+	}
+	return newVariableError(ErrUnexpectedValue, moduleVal.Name,
+		"current value: %v of %s: %s does not match expected value: %s",
+		current, moduleVal.kind(), configName, expected)
+}
+
+// checkNoOpNewValue records a warning in the report if the current value of a
+// NewValues entry already equals its new value
+func checkNoOpNewValue(report *ChangeReport, configName string, moduleVal *moduleValue,
+	keyPath []string, newValue string) {
+	current, ok := getKeyPathValue(moduleVal.Default, keyPath)
+	if !ok || current != newValue {
+		return
+	}
+	fmt.Printf("Warning: new value of %s: %s in %s equals its current value: %s\n",
+		moduleVal.kind(), configName, moduleVal.Filename, newValue)
+	report.NoOpNewValues = append(report.NoOpNewValues, NoOpValue{
+		File:     moduleVal.Filename,
+		Variable: configName,
+		Value:    newValue,
+	})
+}
+
+// overwriteValue adds an edit overwriting a variable default or local value and
+// records the change in the report. Values that already equal the new value
+// are skipped.
+func overwriteValue(edits *fileEdits, report *ChangeReport, moduleVal *moduleValue, value string) {
+	if moduleVal.Default == value {
+		report.Skipped = append(report.Skipped, moduleVal.Name)
+		return
+	}
+
+	edits.add(moduleVal.Filename, func(result *OverwriteResult) error {
+		if moduleVal.Local {
+			return overwriteLocalFile(result, moduleVal.Filename, moduleVal.Name, value)
+		} else if isTfJSONFile(moduleVal.Filename) {
+			return overwriteJSONFile(result, moduleVal.Filename, moduleVal.Name, value)
+		}
+		return overwriteFile(result, moduleVal.Filename, moduleVal.Name, value)
+	})
+
+	report.Changes = append(report.Changes, VariableChange{
+		File:       moduleVal.Filename,
+		Variable:   moduleVal.Name,
+		OldDefault: moduleVal.Default,
+		NewDefault: value,
+	})
+}
+
+// expandVariablePatterns expands glob patterns in variables against the names of
+// the declared variables. Variables without glob characters are returned as is.
+func expandVariablePatterns(variables []string, names []string, source string) ([]string, error) {
+	sortedNames := append([]string{}, names...)
+	sort.Strings(sortedNames)
+
+	var expanded []string
+	seen := map[string]bool{}
+	for _, variable := range variables {
+		matches := []string{variable}
+		if hasVariablePatterns([]string{variable}) {
+			matches = nil
+			for _, name := range sortedNames {
+				ok, err := path.Match(variable, name)
+				if err != nil {
+					return nil, fmt.Errorf("invalid variable pattern: %s error: %w", variable, err)
+				}
+				if ok {
+					matches = append(matches, name)
+				}
+			}
+			if len(matches) == 0 {
+				return nil, newVariableError(ErrVariableNotFound, variable,
+					"variable pattern: %s did not match any variables in %s",
+					variable, source)
+			}
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				expanded = append(expanded, match)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// metadataFilenames returns MetadataFiles, or metadata.yaml if it is not set
+func (config *overwriteConfig) metadataFilenames() []string {
+	if len(config.MetadataFiles) == 0 {
+		return []string{metadataFile}
+	}
+	return config.MetadataFiles
+}
+
+// displayFilenames returns DisplayFiles, or metadata.display.yaml if it is not
+// set
+func (config *overwriteConfig) displayFilenames() []string {
+	if len(config.DisplayFiles) == 0 {
+		return []string{metadataDisplayFile}
+	}
+	return config.DisplayFiles
+}
+
+// metadataVarType returns the varType of a variable in VarTypes, or the
+// current varType if it is not being changed
+func (config *overwriteConfig) metadataVarType(varName string, current string) string {
+	if varType, ok := config.VarTypes[varName]; ok {
+		return varType
+	}
+	return current
+}
+
+// consumerLabelKey returns the ConsumerLabelKey, or goog-partner-solution if it
+// is not set
+func (config *overwriteConfig) consumerLabelKey() string {
+	if config.ConsumerLabelKey == "" {
+		return consumerLabelConst
+	}
+	return config.ConsumerLabelKey
+}
+
+// consumerLabels returns the ConsumerLabels and, if it is set, the
+// ConsumerLabel under the ConsumerLabelKey
+func (config *overwriteConfig) consumerLabels() map[string]string {
+	labels := map[string]string{}
+	for key, value := range config.ConsumerLabels {
+		labels[key] = value
+	}
+	if config.ConsumerLabel != "" {
+		labels[config.consumerLabelKey()] = config.ConsumerLabel
+	}
+	return labels
+}
+
+// collects returns whether err is collected rather than returned at once,
+// which is the case for the VariableErrors of single variables if
+// CollectErrors is set
+func (config *overwriteConfig) collects(err error) bool {
+	var variableErr *VariableError
+	return config.CollectErrors && errors.As(err, &variableErr)
+}
+
+// resolveVariableName returns the name in names matching varname. Unless
+// CaseInsensitiveNames is set, or if nothing matches, varname is returned as is.
+func (config *overwriteConfig) resolveVariableName(varname string, names []string) (string, error) {
+	if !config.CaseInsensitiveNames {
+		return varname, nil
+	}
+
+	var matches []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.EqualFold(name, varname) && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return varname, nil
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", newVariableError(ErrVariableNotFound, varname,
+			"variable: %s ambiguously matches variables differing only by case: %s",
+			varname, matches)
+	}
+}
+
+// isReplacementTarget returns whether value is one of the replacement values, in
+// which case it has already been replaced and overwriting it again is a no-op.
+// If a transform is set, values it returned unchanged are likewise left as is.
+func (config *overwriteConfig) isReplacementTarget(value string) bool {
+	if config.transform != nil {
+		return true
+	}
+
+	found := false
+	for key, replacement := range config.Replacements {
+		if replacement == value {
+			config.markReplacementUsed(key)
+			found = true
+		}
+	}
+	return found || config.isProjectRemapTarget(value)
+}
+
+func (config *overwriteConfig) markReplacementUsed(key string) {
+	if config.usedReplacements != nil {
+		config.usedReplacements[key] = true
+	}
+}
+
+func hasVariablePatterns(variables []string) bool {
+	for _, variable := range variables {
+		if strings.ContainsAny(variable, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetOverwriteConfig parses overwriteConfig from a byte array containing
+// either JSON or YAML. Unknown fields are an error.
+func GetOverwriteConfig(b []byte) (*overwriteConfig, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		return GetOverwriteConfigYAML(b)
+	}
+	return GetOverwriteConfigJSON(b)
+}
+
+// GetOverwriteConfigJSON parses overwriteConfig from a byte array containing
+// JSON. Unknown fields are an error.
+func GetOverwriteConfigJSON(b []byte) (*overwriteConfig, error) {
+	config, err := decodeOverwriteConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config: %s error: %w", string(b), err)
+	}
+
+	err = config.expandEnvValues()
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// decodeOverwriteConfig decodes a JSON overwrite config. Unknown fields are
+// rejected so that misspelled keys are not silently ignored.
+func decodeOverwriteConfig(b []byte) (*overwriteConfig, error) {
+	var config overwriteConfig
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// envVariablePattern matches references to environment variables, such as
+// ${RELEASE_IMAGE}
+var envVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvValues expands references to environment variables in the values of
+// NewValues and Replacements. Referencing an unset environment variable is an
+// error.
+func (config *overwriteConfig) expandEnvValues() error {
+	for _, values := range []map[string]string{config.NewValues, config.Replacements} {
+		for key, value := range values {
+			var err error
+			values[key] = envVariablePattern.ReplaceAllStringFunc(value, func(ref string) string {
+				name := envVariablePattern.FindStringSubmatch(ref)[1]
+				envValue, ok := os.LookupEnv(name)
+				if !ok && err == nil {
+					err = fmt.Errorf("invalid overwrite config: environment variable: %s"+
+						" referenced by: %s is not set", name, key)
+				}
+				return envValue
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that scope and regex replacement patterns compile and that
+// the overwrite config is not ambiguous. NewValues takes precedence over
+// Variables and Replacements, so setting both is reported as an error if Strict
+// is set and as a warning otherwise.
+func (config *overwriteConfig) Validate() error {
+	for _, scope := range config.Scopes {
+		_, err := path.Match(scope.File, "")
+		if err != nil {
+			return fmt.Errorf("invalid overwrite config: scope file pattern: %s error: %w",
+				scope.File, err)
+		}
+	}
+
+	for _, rule := range config.RegexReplacements {
+		_, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid overwrite config: regex replacement pattern: %s error: %w",
+				rule.Pattern, err)
+		}
+	}
+
+	for _, repository := range sortedKeys(config.Digests) {
+		if !digestPattern.MatchString(config.Digests[repository]) {
+			return fmt.Errorf("invalid overwrite config: digest: %s of image repository: %s"+
+				" must have the form sha256:<hex>", config.Digests[repository], repository)
+		}
+	}
+
+	for _, varName := range sortedKeys(config.VarTypes) {
+		if !isValidVarType(config.VarTypes[varName]) {
+			return fmt.Errorf("invalid overwrite config: varType: %s of variable: %s must be one of"+
+				" %s, or a parameterized type such as list(string)",
+				config.VarTypes[varName], varName, strings.Join(varTypes, ", "))
+		}
+	}
+
+	if config.LockTimeout != "" {
+		_, err := time.ParseDuration(config.LockTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid overwrite config: lock timeout: %s error: %w", config.LockTimeout, err)
+		}
+	}
+
+	for varName, numbers := range config.DisplayNumbers {
+		for property := range numbers {
+			if !isDisplayNumberProperty(property) {
+				return fmt.Errorf("invalid overwrite config: display number: %s of variable: %s must be one of %s",
+					property, varName, strings.Join(displayNumberProperties, ", "))
+			}
+		}
+	}
+
+	for _, attributePath := range config.ResourceAttributes {
+		if len(strings.Split(attributePath, ".")) < 2 {
+			return fmt.Errorf("invalid overwrite config: resource attribute: %s must have the form"+
+				" <resource type>.<attribute>, such as google_compute_instance.boot_disk.initialize_params.image",
+				attributePath)
+		}
+	}
+
+	for _, name := range sortedKeys(config.ProviderVersions) {
+		if config.ProviderVersions[name] == "" {
+			return fmt.Errorf("invalid overwrite config: version constraint of provider: %s must not be empty", name)
+		}
+	}
+
+	for _, name := range sortedKeys(config.ModuleSources) {
+		if config.ModuleSources[name] == "" {
+			return fmt.Errorf("invalid overwrite config: source of module: %s must not be empty", name)
+		}
+	}
+
+	for _, name := range sortedKeys(config.Expected) {
+		if _, ok := config.NewValues[name]; !ok {
+			return fmt.Errorf("invalid overwrite config: expected value of: %s has no entry in newValues", name)
+		}
+	}
+
+	// Replacements also apply to the deployer image, resource attributes and
+	// module sources, regardless of NewValues
+	replacementsIgnored := config.DeployerImagePath == "" && len(config.ResourceAttributes) == 0 &&
+		!config.ReplaceModuleSources &&
+		(len(config.Replacements) > 0 ||
+			len(config.RegexReplacements) > 0 || len(config.Digests) > 0 || len(config.ProjectRemap) > 0)
+	if len(config.NewValues) == 0 || (len(config.Variables) == 0 && len(config.VariableTypes) == 0 &&
+		!replacementsIgnored) {
+		return nil
+	}
+
+	msg := "newValues is set, so variables and replacements have no effect"
+	if config.Strict {
+		return fmt.Errorf("invalid overwrite config: %s", msg)
+	}
+
+	fmt.Printf("Warning: %s\n", msg)
+	return nil
+}
+
+// GetOverwriteConfigYAML parses overwriteConfig from a byte array containing
+// YAML. Since YAML is a superset of JSON, JSON is accepted too. Unknown fields
+// are an error.
+func GetOverwriteConfigYAML(b []byte) (*overwriteConfig, error) {
+	jsonBytes, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config as YAML: %s error: %w", string(b), err)
+	}
+
+	config, err := decodeOverwriteConfig(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config as YAML: %s error: %w", string(b), err)
+	}
+
+	err = config.expandEnvValues()
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// GetOverwriteConfigTOML parses overwriteConfig from a byte array containing
+// TOML. Keys have the same names as in JSON and unknown keys are an error.
+func GetOverwriteConfigTOML(b []byte) (*overwriteConfig, error) {
+	var values map[string]interface{}
+	_, err := toml.Decode(string(b), &values)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config as TOML: %s error: %w", string(b), err)
+	}
+
+	jsonBytes, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config as TOML: %s error: %w", string(b), err)
+	}
+
+	config, err := decodeOverwriteConfig(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing overwrite config as TOML: %s error: %w", string(b), err)
+	}
+
+	err = config.expandEnvValues()
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// getModuleDirs returns dir and, if recursive is set, every subdirectory of dir
+// containing Terraform files. Hidden directories such as .terraform are skipped.
+func getModuleDirs(fsys moduleFS, dir string, recursive bool) ([]string, error) {
+	return getNestedDirs(fsys, dir, recursive, func(name string) bool {
+		return strings.HasSuffix(name, ".tf") || isTfJSONFile(name)
+	})
+}
+
+// getMetadataDirs returns dir and, if recursive is set, every subdirectory of
+// dir containing one of the Blueprints Metadata files
+func getMetadataDirs(fsys moduleFS, dir string, recursive bool, filenames []string) ([]string, error) {
+	return getNestedDirs(fsys, dir, recursive, func(name string) bool {
+		for _, filename := range filenames {
+			if name == filename {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// getNestedDirs returns dir and, if recursive is set, every subdirectory of dir
+// containing a file whose name matches. Hidden directories are skipped.
+func getNestedDirs(fsys moduleFS, dir string, recursive bool, matches func(name string) bool) ([]string, error) {
+	dirs := []string{dir}
+	if !recursive {
+		return dirs, nil
+	}
+
+	var walk func(parent string) error
+	walk = func(parent string) error {
+		entries, err := fsys.ReadDir(parent)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			subdir := filepath.Join(parent, entry.Name())
+			subEntries, err := fsys.ReadDir(subdir)
+			if err != nil {
+				return err
 			}
-
-			replaceVal, ok := config.Replacements[defaultVal]
-			if !ok {
-				return fmt.Errorf("default value: %s of variable: %s not found in replacements",
-					defaultVal, varname)
+			for _, subEntry := range subEntries {
+				if !subEntry.IsDir() && matches(subEntry.Name()) {
+					dirs = append(dirs, subdir)
+					break
+				}
 			}
 
-			err = overwriteFile(varInfo.Pos.Filename, varname, replaceVal)
+			err = walk(subdir)
 			if err != nil {
 				return err
 			}
 		}
+		return nil
 	}
 
-	fmt.Println("Successfully replaced default values in tf files")
-	return nil
-}
-
-// GetOverwriteConfig parses overwriteConfig from a byte array
-func GetOverwriteConfig(b []byte) (*overwriteConfig, error) {
-	var config overwriteConfig
-	err := json.Unmarshal(b, &config)
-	if err != nil {
-		return nil, fmt.Errorf("failure parsing overwrite config: %s error: %w", string(b), err)
-	}
-
-	return &config, nil
-}
-
-func getVarInfo(varname string, dir string) (*tfconfig.Variable, error) {
-	module, diag := tfconfig.LoadModule(dir)
-	if diag.HasErrors() {
-		return nil, fmt.Errorf("failure parsing terraform module: %w", diag)
-	}
-
-	variable, ok := module.Variables[varname]
-	if !ok {
-		return nil, fmt.Errorf("variable: %s not found in module", varname)
-	}
-
-	return variable, nil
+	err := walk(dir)
+	return dirs, err
 }
 
 func getAttributeValueTokens(value string) hclwrite.Tokens {
 	// Use logic similar to https://github.com/hashicorp/hcl/blob/4679383728fe331fc8a6b46036a27b8f818d9bc0/hclwrite/generate.go#L217-L234
-	// for writing string values
+	// for writing string values. Newlines, quotes and template sequences in
+	// the value are escaped.
+	escaped := hclwrite.TokensForValue(cty.StringVal(value))[1].Bytes
 	return hclwrite.Tokens{
 		{
 			Type:         hclsyntax.TokenOQuote,
@@ -152,7 +1331,7 @@ func getAttributeValueTokens(value string) hclwrite.Tokens {
 		},
 		{
 			Type:  hclsyntax.TokenQuotedLit,
-			Bytes: []byte(value),
+			Bytes: escaped,
 		},
 		{
 			Type:  hclsyntax.TokenCQuote,
@@ -162,14 +1341,70 @@ func getAttributeValueTokens(value string) hclwrite.Tokens {
 
 }
 
-func overwriteFile(filename string, varname string, value string) error {
-	b, err := os.ReadFile(filename)
+// getHeredocValueTokens returns the tokens of a heredoc expression with the
+// contents replaced by value. The delimiters of the original heredoc tokens are
+// kept, and for indented heredocs (<<-) the lines are indented like the
+// original contents.
+func getHeredocValueTokens(tokens hclwrite.Tokens, value string) (hclwrite.Tokens, error) {
+	if !strings.HasSuffix(value, "\n") {
+		return nil, fmt.Errorf("heredoc value can only be replaced with a value ending in a newline")
+	}
+
+	openToken := tokens[0]
+	closeToken := tokens[len(tokens)-1]
+	if closeToken.Type != hclsyntax.TokenCHeredoc {
+		return nil, fmt.Errorf("unsupported heredoc value")
+	}
+
+	indent := ""
+	if strings.HasPrefix(string(openToken.Bytes), "<<-") {
+		indent = getHeredocIndent(tokens[1 : len(tokens)-1])
+	}
+
+	valueTokens := hclwrite.Tokens{openToken}
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(value, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\n")
+		// Template sequences are escaped so the value is written literally
+		line = strings.ReplaceAll(line, "${", "$${")
+		line = strings.ReplaceAll(line, "%{", "%%{")
+		if line != "" {
+			line = indent + line
+		}
+		valueTokens = append(valueTokens, &hclwrite.Token{
+			Type:  hclsyntax.TokenStringLit,
+			Bytes: []byte(line + "\n"),
+		})
+	}
+	return append(valueTokens, closeToken), nil
+}
+
+// getHeredocIndent returns the leading whitespace shared by the non-empty lines
+// of heredoc contents
+func getHeredocIndent(contentTokens hclwrite.Tokens) string {
+	lines := strings.Split(string(contentTokens.Bytes()), "\n")
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first || len(lineIndent) < len(indent) {
+			indent = lineIndent
+			first = false
+		}
+	}
+	return indent
+}
+
+func overwriteFile(result *OverwriteResult, filename string, varname string, value string) error {
+	b, err := result.readFile(filename)
 	if err != nil {
 		return err
 	}
-	file, diag := hclwrite.ParseConfig(b, "", hcl.Pos{Line: 1, Column: 1})
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
 	if diag.HasErrors() {
-		return diag
+		return newParseError(diag)
 	}
 
 	block := file.Body().FirstMatchingBlock("variable", []string{varname})
@@ -177,94 +1412,200 @@ func overwriteFile(filename string, varname string, value string) error {
 		return fmt.Errorf("did not find block with variable: %s", varname)
 	}
 
+	added, err := setStringAttribute(block.Body(), "default", value)
+	if err != nil {
+		return fmt.Errorf("failure overwriting variable: %s error: %w", varname, err)
+	}
+
+	rawBytes := file.BuildTokens(nil).Bytes()
+	if added {
+		// Only an added attribute needs formatting. Otherwise, the file is left
+		// byte-identical apart from the replaced value.
+		rawBytes = formatBlock(rawBytes, block)
+	}
+	result.stageFile(filename, b, rawBytes)
+	return nil
+}
+
+// setStringAttribute sets an attribute to a string value and returns whether
+// the attribute was added. Heredoc values are rewritten as heredocs.
+func setStringAttribute(body *hclwrite.Body, name string, value string) (bool, error) {
 	// SetAttributeValue() is cleaner to overwrite values, however SetAttributeRaw gives more
 	// control over formatting. SetAttributeValue() and File.WriteTo() would overwrite all
 	// formatting. See: https://github.com/hashicorp/hcl/issues/316
-	block.Body().SetAttributeRaw("default", getAttributeValueTokens(value))
+	attribute := body.GetAttribute(name)
+	valueTokens := getAttributeValueTokens(value)
+	if attribute != nil {
+		exprTokens := attribute.Expr().BuildTokens(nil)
+		if exprTokens[0].Type == hclsyntax.TokenOHeredoc {
+			var err error
+			valueTokens, err = getHeredocValueTokens(exprTokens, value)
+			if err != nil {
+				return false, err
+			}
+		}
+		// Keep the original spacing between the equals sign and the value
+		valueTokens[0].SpacesBefore = exprTokens[0].SpacesBefore
+	}
+	body.SetAttributeRaw(name, valueTokens)
+	return attribute == nil, nil
+}
+
+// formatBlock formats a single top-level block within the raw bytes of a file,
+// leaving formatting and comments in the rest of the file untouched.
+func formatBlock(fileBytes []byte, block *hclwrite.Block) []byte {
+	blockBytes := block.BuildTokens(nil).Bytes()
+	return bytes.Replace(fileBytes, blockBytes, hclwrite.Format(blockBytes), 1)
+}
+
+// OverwriteAll replaces values in the Terraform module, Blueprints Metadata,
+// and Blueprints Metadata display file in dir. Missing metadata files are
+// ignored. The returned error identifies the stage that failed. Files are only
+// written once every stage has succeeded and the proposed contents of all
+// files have been validated. If writing any file fails, the files already
+// written are rolled back, so the module is updated together or not at all.
+func OverwriteAll(config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	return OverwriteAllContext(context.Background(), config, dir)
+}
 
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0000)
+// OverwriteAllContext is like OverwriteAll but stops with ctx.Err() if ctx is
+// done before all files have been processed. No files are written in that case.
+func OverwriteAllContext(ctx context.Context, config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	err := config.runPreHook(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	rawBytes := file.BuildTokens(nil).Bytes()
-	formattedBytes := hclwrite.Format(rawBytes)
-	_, err = f.Write(formattedBytes)
-	return err
-}
-
-// Inserts a consumer label under the `provider "google"` block if it does
-// not exist.
-// The `dir` parameter is the path to the TF main file.
-// The `mpConsumerlabel` parameter is the label value.
-func upsertConsumerLabel(dir string, mpConsumerlabel string) error {
-	// If the parameter is not provided, do nothing.
-	// This is for backward-compatibility purpose.
-	if len(mpConsumerlabel) == 0 {
-		fmt.Printf("No consumer label was passed as a parameter.\n")
-		return nil
+	result := newOverwriteResult()
+	err = stageAll(ctx, result, config, dir)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Inserting the '%s' consumer label.\n", mpConsumerlabel)
-
-	mainTfFullPath := path.Join(dir, mainTfFile)
-	b, err := os.ReadFile(mainTfFullPath)
+	err = ctx.Err()
 	if err != nil {
-		return err
-	}
-	mainTfParsedFile, diag := hclwrite.ParseConfig(b, "", hcl.Pos{Line: 1, Column: 1})
-	if diag.HasErrors() {
-		return diag
+		return nil, err
 	}
 
-	// the `provider` section is expected in the main config file
-	providerGoogleBlock := mainTfParsedFile.Body().FirstMatchingBlock("provider", []string{"google"})
-	if providerGoogleBlock == nil {
-		// We always expect a `provider` block in the main config
-		return fmt.Errorf("'provider \"google\"' block not found in %s", mainTfFullPath)
-	} else {
-		fmt.Printf("'provider \"google\"' block detected in %s\n", mainTfFullPath)
+	err = result.commit(config, dir)
+	if err != nil {
+		return nil, err
+	}
 
-		defaultLabelsAttribute := providerGoogleBlock.Body().GetAttribute(defaultLabelsConst)
-		if defaultLabelsAttribute == nil {
-			fmt.Printf("'%s' attribute not found in %s. Appending.\n", defaultLabelsConst, mainTfFullPath)
+	fmt.Printf("Successfully replaced values in %s\n", dir)
+	return result, nil
+}
 
-			providerGoogleBlock.Body().SetAttributeValue(defaultLabelsConst, cty.MapVal(map[string]cty.Value{
-				consumerLabelConst: cty.StringVal(mpConsumerlabel),
-			}))
+// stageAll stages the overwrites of the Terraform module, Blueprints Metadata
+// and display files in result without writing any files
+func stageAll(ctx context.Context, result *OverwriteResult, config *overwriteConfig, dir string) error {
+	config, err := config.withVariablesManifest(result, dir)
+	if err != nil {
+		return err
+	}
 
-			f, err := os.OpenFile(mainTfFullPath, os.O_WRONLY|os.O_TRUNC, 0000)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
+	err = stageTf(ctx, result, &ChangeReport{}, config, dir)
+	if err != nil {
+		return fmt.Errorf("failure overwriting tf files: %w", err)
+	}
 
-			rawBytes := mainTfParsedFile.BuildTokens(nil).Bytes()
-			formattedBytes := hclwrite.Format(rawBytes)
-			_, err = f.Write(formattedBytes)
+	err = stageMetadata(ctx, result, config, dir)
+	if err != nil {
+		return fmt.Errorf("failure overwriting %s: %w",
+			strings.Join(config.metadataFilenames(), ", "), err)
+	}
 
-			fmt.Printf("Successfully upserted consumber label in %s\n", mainTfFullPath)
-			return err
-		} else {
-			fmt.Printf("'%s' attribute detected in %s. Not overwriting.\n", defaultLabelsConst, mainTfFullPath)
-		}
+	err = stageDisplay(ctx, result, config, dir)
+	if err != nil {
+		return fmt.Errorf("failure overwriting %s: %w",
+			strings.Join(config.displayFilenames(), ", "), err)
 	}
 
+	if config.ValidateEnums {
+		return validateEnums(result, config, dir)
+	}
 	return nil
 }
 
 // OverwriteMetadata replaces default values for variables in Blueprints Metadata
 //
-// This overwrite rearranges the order of keys in the YAML, since we are not using the
-// definition in
+// If Recursive is set, the metadata files of every subdirectory are
+// overwritten alike and the changes are returned in one result. Directories
+// without a metadata file are skipped.
+//
+// Only the changed values are rewritten, so comments and the order of keys in the
+// YAML are preserved. We are not using the definition in
 // https://github.com/GoogleCloudPlatform/cloud-foundation-toolkit/blob/master/cli/bpmetadata/types.go
 //
 // We are not using this definition because
 // 1. There are version compatiblilty issues with Kpt and cloud-foundation-toolkit to resolve
 // 2. We will avoid dropping fields if mpdev is using an out-of-date version of cloud-foundation-toolkit
-func OverwriteMetadata(config *overwriteConfig, dir string) error {
-	data, err := os.ReadFile(path.Join(dir, metadataFile))
+func OverwriteMetadata(config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	return OverwriteMetadataContext(context.Background(), config, dir)
+}
+
+// OverwriteMetadataContext is like OverwriteMetadata but stops with ctx.Err()
+// if ctx is done before the file is written
+func OverwriteMetadataContext(ctx context.Context, config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	err := config.runPreHook(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newOverwriteResult()
+	err = stageMetadata(ctx, result, config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.commit(config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Successfully replaced default values in %s\n",
+		strings.Join(config.metadataFilenames(), ", "))
+	return result, nil
+}
+
+// stageMetadata stages the overwrites of the Blueprints Metadata files in result
+// without writing any files
+func stageMetadata(ctx context.Context, result *OverwriteResult, config *overwriteConfig, dir string) error {
+	config, err := config.withVariablesManifest(result, dir)
+	if err != nil {
+		return err
+	}
+
+	metadataDirs, err := getMetadataDirs(result.fsys, dir, config.Recursive, config.metadataFilenames())
+	if err != nil {
+		return err
+	}
+
+	for _, metadataDir := range metadataDirs {
+		for _, filename := range config.metadataFilenames() {
+			err := stageMetadataFile(ctx, result, config, metadataDir, filename)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func stageMetadataFile(ctx context.Context, result *OverwriteResult, config *overwriteConfig,
+	dir string, filename string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	metadataFullPath := path.Join(dir, filename)
+
+	data, err := result.readFile(metadataFullPath)
 	if err != nil {
 		// CLI only modules will not have a metadata file. Ignore file not found errors
 		if os.IsNotExist(err) {
@@ -272,29 +1613,63 @@ func OverwriteMetadata(config *overwriteConfig, dir string) error {
 		}
 		return err
 	}
+	result.markScanned(metadataFullPath)
+
+	err = checkUTF8(config, metadataFullPath, data)
+	if err != nil {
+		return err
+	}
 
+	// The JSON is only used to look up values. Changes are made to the YAML
+	// directly to preserve its comments and formatting.
 	json, err := yaml.YAMLToJSON(data)
 	if err != nil {
-		return fmt.Errorf("failure parsing %s error: %w", metadataFile, err)
+		return fmt.Errorf("failure parsing %s error: %w", filename, err)
+	}
+	modified := data
+
+	var names []string
+	for _, name := range gjson.GetBytes(json, "spec.interfaces.variables.#.name").Array() {
+		names = append(names, name.String())
 	}
 
 	if config.NewValues != nil {
 		fmt.Printf("Replacing the default values of the variables: %s in %s\n",
-			config.NewValues, metadataFile)
+			config.NewValues, filename)
 
-		for varName, newValue := range config.NewValues {
+		for _, configName := range sortedKeys(config.NewValues) {
+			newValue := config.NewValues[configName]
+			if _, keyPath := splitKeyPath(configName); len(keyPath) > 0 {
+				fmt.Printf("Skipping key path: %s in %s\n", configName, filename)
+				continue
+			}
+			varName, err := config.resolveVariableName(configName, names)
+			if err != nil {
+				return err
+			}
 			varQuery := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s")`, varName)
 			varEntry := gjson.GetBytes(json, varQuery)
+			if varEntry.Raw == "" && config.AddMissing {
+				fmt.Printf("Adding variable entry for variable: %s in %s\n", varName, filename)
+				modified, err = addMetadataVariable(modified, varName, newValue)
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			if varEntry.Raw == "" {
-				return fmt.Errorf("missing variable entry for variable: %s in %s",
-					varName, metadataFile)
-			}
-			// sjson.SetBytes doesn't work when spec.interfaces.variables.#(name=="%s").defaultValue
-			// doesn't already exist. Retrieving and setting the whole variable entry
-			// as a workaround.
-			varEntryMap := varEntry.Value().(map[string]interface{})
-			varEntryMap["defaultValue"] = newValue
-			json, err = sjson.SetBytes(json, varQuery, varEntryMap)
+				return newVariableError(ErrVariableNotFound, varName,
+					"missing variable entry for variable: %s in %s",
+					varName, filename)
+			}
+			varType := config.metadataVarType(varName, varEntry.Get("varType").String())
+			defaultValue, err := convertMetadataValue(newValue, varType)
+			if err != nil {
+				return newVariableError(ErrWrongType, varName,
+					"invalid new value for variable: %s of varType: %s in %s. error: %w",
+					varName, varType, filename, err)
+			}
+			modified, err = setMetadataVariableField(modified, varName, "defaultValue", defaultValue)
 			if err != nil {
 				return fmt.Errorf("error setting the updated entry for variable: %s. error: %w",
 					varName, err)
@@ -302,22 +1677,59 @@ func OverwriteMetadata(config *overwriteConfig, dir string) error {
 		}
 	} else {
 		fmt.Printf("Replacing the default values of the variables: %s in %s\n",
-			config.Variables, metadataFile)
+			config.Variables, filename)
 
-		for _, variable := range config.Variables {
+		variables, err := expandVariablePatterns(config.Variables, names, filename)
+		if err != nil {
+			return err
+		}
+
+		for _, configName := range variables {
+			variable, err := config.resolveVariableName(configName, names)
+			if err != nil {
+				return err
+			}
 			query := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s").defaultValue`, variable)
 			defaultVal := gjson.GetBytes(json, query).String()
 			if defaultVal == "" {
-				return fmt.Errorf("Missing valid default value for variable: %s in %s",
-					variable, metadataFile)
+				return newVariableError(ErrMissingDefault, variable,
+					"Missing valid default value for variable: %s in %s",
+					variable, filename)
+			}
+			replaceVal, ok, err := config.replacementFor(variable, defaultVal)
+			if err != nil {
+				return err
+			}
+			if !ok && config.isReplacementTarget(defaultVal) {
+				continue
+			}
+			if !ok && isTemplatedValue(defaultVal) && !config.Strict {
+				fmt.Printf("Warning: skipping templated default value: %s of variable: %s in %s."+
+					" Add the template as a key of replacements to replace it\n",
+					defaultVal, variable, filename)
+				continue
+			}
+			if !ok && isTemplatedValue(defaultVal) {
+				return newVariableError(ErrReplacementNotFound, variable,
+					"templated default value: %s of variable: %s in %s not found"+
+						" in replacements", defaultVal, variable, filename)
 			}
-			replaceVal, ok := config.Replacements[defaultVal]
 			if !ok {
-				return fmt.Errorf("default value: %s of variable: %s in %s not found"+
-					" in replacements", defaultVal, variable, metadataFile)
+				return newVariableError(ErrReplacementNotFound, variable,
+					"default value: %s of variable: %s in %s not found"+
+						" in replacements", defaultVal, variable, filename)
+			}
+
+			varTypeQuery := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s").varType`, variable)
+			varType := config.metadataVarType(variable, gjson.GetBytes(json, varTypeQuery).String())
+			defaultValue, err := convertMetadataValue(replaceVal, varType)
+			if err != nil {
+				return newVariableError(ErrWrongType, variable,
+					"invalid replacement for variable: %s of varType: %s in %s. error: %w",
+					variable, varType, filename, err)
 			}
 
-			json, err = sjson.SetBytes(json, query, replaceVal)
+			modified, err = setMetadataVariableField(modified, variable, "defaultValue", defaultValue)
 			if err != nil {
 				return fmt.Errorf("Error setting default value of variable: %s. error: %w",
 					variable, err)
@@ -325,114 +1737,307 @@ func OverwriteMetadata(config *overwriteConfig, dir string) error {
 		}
 	}
 
-	modifiedYaml, err := yaml.JSONToYAML([]byte(json))
+	for _, varName := range sortedKeys(config.Descriptions) {
+		varQuery := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s")`, varName)
+		varEntry := gjson.GetBytes(json, varQuery)
+		if varEntry.Raw == "" {
+			return newVariableError(ErrVariableNotFound, varName,
+				"missing variable entry for variable: %s in %s", varName, filename)
+		}
+
+		modified, err = setMetadataVariableField(modified, varName, "description",
+			config.Descriptions[varName])
+		if err != nil {
+			return fmt.Errorf("error setting description of variable: %s. error: %w",
+				varName, err)
+		}
+	}
+
+	for _, varName := range sortedKeys(config.VarTypes) {
+		varQuery := fmt.Sprintf(`spec.interfaces.variables.#(name=="%s")`, varName)
+		varEntry := gjson.GetBytes(json, varQuery)
+		if varEntry.Raw == "" {
+			return newVariableError(ErrVariableNotFound, varName,
+				"missing variable entry for variable: %s in %s", varName, filename)
+		}
+
+		modified, err = setMetadataVariableField(modified, varName, "varType",
+			config.VarTypes[varName])
+		if err != nil {
+			return fmt.Errorf("error setting varType of variable: %s. error: %w",
+				varName, err)
+		}
+	}
+
+	modified, err = overwriteDeployerImage(config, modified, filename)
+	if err != nil {
+		return err
+	}
+
+	modified, err = overwriteMetadataOutputs(config, modified, json, filename)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path.Join(dir, metadataFile), modifiedYaml, 0644)
+	modified, err = overwriteMetadataFields(config, modified, filename)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Successfully replaced default values in %s\n", metadataFile)
+	result.stageFile(metadataFullPath, data, modified)
 	return nil
 }
 
 // OverwriteDisplay replaces variable values in Blueprint metadata display file.
-func OverwriteDisplay(config *overwriteConfig, dir string) error {
+// Both enumValueLabels and image values under the xGoogleProperty of
+// ET_GCE_DISK_IMAGE variables are replaced. Only the value of each enum entry
+// is rewritten, so the order of the entries and their labels are kept. Modules
+// without a display file are left unchanged.
+func OverwriteDisplay(config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	return OverwriteDisplayContext(context.Background(), config, dir)
+}
+
+// OverwriteDisplayContext is like OverwriteDisplay but stops with ctx.Err() if
+// ctx is done before the file is written
+func OverwriteDisplayContext(ctx context.Context, config *overwriteConfig, dir string) (*OverwriteResult, error) {
+	err := config.runPreHook(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newOverwriteResult()
+	err = stageDisplay(ctx, result, config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ValidateEnums {
+		err = validateEnums(result, config, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.commit(config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Successfully replaced display values in %s\n",
+		strings.Join(config.displayFilenames(), ", "))
+	return result, nil
+}
+
+// stageDisplay stages the overwrites of the Blueprints Metadata display files
+// in result without writing any files
+func stageDisplay(ctx context.Context, result *OverwriteResult, config *overwriteConfig, dir string) error {
+	config, err := config.withVariablesManifest(result, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range config.displayFilenames() {
+		err := stageDisplayFile(ctx, result, config, dir, filename)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stageDisplayFile(ctx context.Context, result *OverwriteResult, config *overwriteConfig,
+	dir string, filename string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	fmt.Printf("Replacing the values of the display variables: %s in %s\n",
-		config.Variables, metadataDisplayFile)
+		config.Variables, filename)
 
-	data, err := os.ReadFile(path.Join(dir, metadataDisplayFile))
+	displayFullPath := path.Join(dir, filename)
+
+	data, err := result.readFile(displayFullPath)
 	if err != nil {
-		// CLI only modules will not have a metadata display file. Ignore file not found errors
+		// CLI only modules will not have a metadata display file. Ignore file not
+		// found errors, even if display variables were requested
 		if os.IsNotExist(err) {
+			fmt.Printf("No %s found in %s. Skipping display variables\n", filename, dir)
 			return nil
 		}
 		return err
 	}
+	result.markScanned(displayFullPath)
+
+	err = checkUTF8(config, displayFullPath, data)
+	if err != nil {
+		return err
+	}
 
 	json, err := yaml.YAMLToJSON(data)
 	if err != nil {
-		return fmt.Errorf("failure parsing %s error: %w", metadataDisplayFile, err)
+		return fmt.Errorf("failure parsing %s error: %w", filename, err)
 	}
+	originalJSON := json
+
+	var names []string
+	gjson.GetBytes(json, "spec.ui.input.variables").ForEach(func(key, _ gjson.Result) bool {
+		names = append(names, key.String())
+		return true
+	})
 
 	if config.NewValues != nil {
-		for varName, newValue := range config.NewValues {
+		for _, configName := range sortedKeys(config.NewValues) {
+			newValue := config.NewValues[configName]
+			if _, keyPath := splitKeyPath(configName); len(keyPath) > 0 {
+				fmt.Printf("Skipping key path: %s in %s\n", configName, filename)
+				continue
+			}
+			varName, err := config.resolveVariableName(configName, names)
+			if err != nil {
+				return err
+			}
 			variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, varName)
 			variableInfo := gjson.GetBytes(json, variableQuery).String()
 			if variableInfo == "" {
-				return fmt.Errorf("missing valid display info for variable: %s in %s",
-					varName, metadataDisplayFile)
+				return newVariableError(ErrVariableNotFound, varName,
+					"missing valid display info for variable: %s in %s",
+					varName, filename)
 			}
 			enumValueLabels := gjson.Get(variableInfo, "enumValueLabels").Array()
 			if len(enumValueLabels) == 0 {
 				fmt.Printf("No enum value labels for display variable: %s in %s\n",
-					varName, metadataDisplayFile)
+					varName, filename)
 				continue
 			}
 
-			var replacementEnumValueLabels []EnumValueLabel
-			for _, enumValueLabel := range enumValueLabels {
-				currLabel := enumValueLabel.Get("label").String()
-				replacementEnumValueLabels = append(replacementEnumValueLabels, EnumValueLabel{Label: currLabel, Value: newValue})
+			values := make([]string, len(enumValueLabels))
+			for i := range enumValueLabels {
+				values[i] = newValue
 			}
-
-			enumQuery := fmt.Sprintf(`spec.ui.input.variables.%s.enumValueLabels`, varName)
-			json, err = sjson.SetBytes(json, enumQuery, replacementEnumValueLabels)
+			json, err = setEnumValues(json, varName, values)
 			if err != nil {
-				return fmt.Errorf("error setting default value of variable: %s. error: %w",
-					varName, err)
+				return err
+			}
+			err = validateImageURIs(config, json, varName, filename)
+			if err != nil {
+				return err
 			}
 		}
 	} else {
-		for _, variable := range config.Variables {
+		variables, err := expandVariablePatterns(config.Variables, names, filename)
+		if err != nil {
+			return err
+		}
+
+		for _, configName := range variables {
+			variable, err := config.resolveVariableName(configName, names)
+			if err != nil {
+				return err
+			}
 			variableQuery := fmt.Sprintf(`spec.ui.input.variables.%s`, variable)
 			variableInfo := gjson.GetBytes(json, variableQuery).String()
 			if variableInfo == "" {
-				return fmt.Errorf("missing valid display info for variable: %s in %s",
-					variable, metadataDisplayFile)
+				return newVariableError(ErrVariableNotFound, variable,
+					"missing valid display info for variable: %s in %s",
+					variable, filename)
+			}
+
+			json, err = overwriteDiskImageProperty(config, json, variable)
+			if err != nil {
+				return err
 			}
 
 			enumValueLabels := gjson.Get(variableInfo, "enumValueLabels").Array()
 			if len(enumValueLabels) == 0 {
 				fmt.Printf("No enum value labels for display variable: %s in %s\n",
-					variable, metadataDisplayFile)
+					variable, filename)
 				continue
 			}
 
-			var replacementEnumValueLabels []EnumValueLabel
+			var values []string
 			for _, enumValueLabel := range enumValueLabels {
 				currValue := enumValueLabel.Get("value").String()
-				currLabel := enumValueLabel.Get("label").String()
-				replaceVal, ok := config.Replacements[currValue]
+				replaceVal, ok, err := config.replacementFor(variable, currValue)
+				if err != nil {
+					return err
+				}
+				if !ok && (config.isReplacementTarget(currValue) || config.AllowPartialEnums) {
+					replaceVal, ok = currValue, true
+				}
 				if !ok {
-					return fmt.Errorf("enum value: %s of variable: %s in %s not found"+
-						" in replacements", currValue, variable, metadataDisplayFile)
+					return newVariableError(ErrReplacementNotFound, variable,
+						"enum value: %s of variable: %s in %s not found"+
+							" in replacements", currValue, variable, filename)
 				}
-				replacementEnumValueLabels = append(replacementEnumValueLabels, EnumValueLabel{Label: currLabel, Value: replaceVal})
+				values = append(values, replaceVal)
 			}
 
-			enumQuery := fmt.Sprintf(`spec.ui.input.variables.%s.enumValueLabels`, variable)
-			json, err = sjson.SetBytes(json, enumQuery, replacementEnumValueLabels)
+			json, err = setEnumValues(json, variable, values)
 			if err != nil {
-				return fmt.Errorf("error setting default value of variable: %s. error: %w",
-					variable, err)
+				return err
+			}
+			err = validateImageURIs(config, json, variable, filename)
+			if err != nil {
+				return err
 			}
 		}
 	}
 
-	modifiedYaml, err := yaml.JSONToYAML([]byte(json))
+	json, err = overwriteDisplayLabels(config, json, filename)
+	if err != nil {
+		return err
+	}
+
+	json, err = overwriteDisplayProperties(config, json, filename)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path.Join(dir, metadataDisplayFile), modifiedYaml, 0644)
+	json, err = overwriteDisplayNumbers(config, json, filename)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Successfully replaced display values in %s\n", metadataDisplayFile)
+	json, err = overwriteImageProjects(config, json, filename)
+	if err != nil {
+		return err
+	}
+
+	// Re-serializing drops comments and sorts keys, so files whose values are
+	// unchanged are left as is
+	if equalJSON(originalJSON, json) {
+		return nil
+	}
+
+	// JSONToYAML writes the keys of every map in sorted order, so overwriting
+	// the same file with the same config yields byte-identical output
+	modifiedYaml, err := yaml.JSONToYAML([]byte(json))
+	if err != nil {
+		return err
+	}
+
+	result.stageFile(displayFullPath, data, modifiedYaml)
 	return nil
 }
+
+// setEnumValues sets the values of the enumValueLabels of a display variable in
+// order. Each value is set in place so the order of the entries and their other
+// fields are preserved.
+func setEnumValues(json []byte, varName string, values []string) ([]byte, error) {
+	for i, value := range values {
+		valueQuery := fmt.Sprintf(`spec.ui.input.variables.%s.enumValueLabels.%d.value`,
+			escapeJSONPath(varName), i)
+		var err error
+		json, err = sjson.SetBytes(json, valueQuery, value)
+		if err != nil {
+			return nil, fmt.Errorf("error setting default value of variable: %s. error: %w",
+				varName, err)
+		}
+	}
+	return json, nil
+}
@@ -14,9 +14,12 @@
 package tf
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,33 +53,45 @@ func TestOverwriteTf(t *testing.T) {
 			},
 		},
 	}, {
-		name: "Invalid HCL shows parsing error",
+		name: "Re-running overwrite on replaced values is a no-op",
 		tfFiles: map[string]string{
-			"main.tf": "this is broken",
+			"main.tf":        mainTfReplaced,
+			"anyfilename.tf": otherTfReplaced,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf":        mainTfReplaced,
+			"anyfilename.tf": otherTfReplaced,
 		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"value_to_replace"},
+			Variables: []string{"value_to_replace", "other_value_to_replace", "another_variable"},
 			Replacements: map[string]string{
 				"original-value": "new-value",
+				"old-value":      "newer-value",
+				"oldest-value":   "newest-value",
 			},
 		},
-		errorContains: "failure parsing terraform module",
 	}, {
-		name: "Fail when variable not present in Terraform module",
+		name: "Overwrite variables with regex replacements",
 		tfFiles: map[string]string{
 			"main.tf": mainTf,
 		},
+		expectedTfFiles: map[string]string{
+			"main.tf": mainTfReplaced,
+		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"missing_variable"},
+			Variables: []string{"value_to_replace", "other_value_to_replace"},
 			Replacements: map[string]string{
 				"original-value": "new-value",
 			},
+			RegexReplacements: []RegexRule{{
+				Pattern:     "^old-(value)$",
+				Replacement: "newer-${1}",
+			}},
 		},
-		errorContains: "variable: missing_variable not found",
 	}, {
-		name: "Fail when variable has no default value set",
+		name: "Invalid HCL shows parsing error",
 		tfFiles: map[string]string{
-			"main.tf": tfNoDefault,
+			"main.tf": "this is broken",
 		},
 		overwriteConfig: overwriteConfig{
 			Variables: []string{"value_to_replace"},
@@ -84,617 +99,4575 @@ func TestOverwriteTf(t *testing.T) {
 				"original-value": "new-value",
 			},
 		},
-		errorContains: "variable: value_to_replace must have default value",
+		errorContains: "failure parsing terraform module",
 	}, {
-		name: "Fail when variable default value is not a string",
+		name: "Fail when the directory has no terraform files",
 		tfFiles: map[string]string{
-			"main.tf": tfDefaultWrongType,
+			"metadata.yaml": "apiVersion: blueprints.cloud.google.com/v1alpha1\n",
 		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"value_to_replace"},
-			Replacements: map[string]string{
-				"original-value": "new-value",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
 			},
 		},
-		errorContains: "image variable: value_to_replace must be type string",
+		errorContains: "no terraform files found in",
 	}, {
-		name: "Fail when variable default value is not in replacements",
+		name: "Fail when only subdirectories have terraform files without recursive",
 		tfFiles: map[string]string{
-			"main.tf": mainTf,
+			"modules/db/main.tf": mainTf,
 		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"value_to_replace"},
-			Replacements: map[string]string{
-				"non-existent": "new-value",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
 			},
 		},
-		errorContains: "default value: original-value of variable: value_to_replace not found in replacements",
+		errorContains: "no terraform files found in",
 	}, {
-		name: "With NewValues, overwrite multiple variables and files",
+		name: "Overwrite heredoc defaults, preserving delimiters and indentation",
 		tfFiles: map[string]string{
-			"main.tf":        mainTf,
-			"anyfilename.tf": otherTf,
+			"main.tf": tfHeredoc,
 		},
 		expectedTfFiles: map[string]string{
-			"main.tf":        mainTfReplaced,
-			"anyfilename.tf": otherTfReplaced,
+			"main.tf": tfHeredocReplaced,
 		},
 		overwriteConfig: overwriteConfig{
-			NewValues: map[string]string{
-				"value_to_replace":       "new-value",
-				"other_value_to_replace": "newer-value",
-				"another_variable":       "newest-value",
+			Variables: []string{"startup_script", "plain_script"},
+			Replacements: map[string]string{
+				"gcloud compute images describe old-image\n  --project old-project\n": "gcloud compute images describe new-image\n  --project ${project}\n",
+				"echo old-image\n": "echo new-image\necho done\n",
 			},
 		},
 	}, {
-		name: "With NewValues, overwrite multiple variables and files. Add consumer label",
+		name: "Overwrite string default with a multi-line value",
 		tfFiles: map[string]string{
-			"main.tf": mainTfNoLabel,
+			"main.tf": mainTf,
 		},
 		expectedTfFiles: map[string]string{
-			"main.tf": mainTfLabelUpserted,
+			"main.tf": strings.Replace(mainTf, `"original-value"`, `"first-line\nsecond-line"`, 1),
 		},
 		overwriteConfig: overwriteConfig{
-			ConsumerLabel: "new-consumer-label",
 			NewValues: map[string]string{
-				"value_to_replace": "new-value",
+				"value_to_replace": "first-line\nsecond-line",
 			},
 		},
 	}, {
-		name: "With NewValues, overwrite multiple variables and files. Do not replace existing consumer label",
+		name: "Fail when heredoc default is replaced without a trailing newline",
 		tfFiles: map[string]string{
-			"main.tf": mainTfProvidedLabel,
-		},
-		expectedTfFiles: map[string]string{
-			"main.tf": mainTfLabelUpserted,
+			"main.tf": tfHeredoc,
 		},
 		overwriteConfig: overwriteConfig{
-			ConsumerLabel: "even-newer-consumer-label",
 			NewValues: map[string]string{
-				"value_to_replace": "new-value",
-			},
-		},
-	},
-		{
-			name: "With NewValues, ignores Variables and Replacements",
-			tfFiles: map[string]string{
-				"main.tf":        mainTf,
-				"anyfilename.tf": otherTf,
-			},
-			expectedTfFiles: map[string]string{
-				"main.tf":        mainTfReplaced,
-				"anyfilename.tf": otherTfReplaced,
-			},
-			overwriteConfig: overwriteConfig{
-				NewValues: map[string]string{
-					"value_to_replace":       "new-value",
-					"other_value_to_replace": "newer-value",
-					"another_variable":       "newest-value",
-				},
-				Variables: []string{"value_to_replace", "other_value_to_replace", "another_variable"},
-				Replacements: map[string]string{
-					"original-value": "new-value-unused",
-					"old-value":      "newer-value-unused",
-					"oldest-value":   "newest-value-unused",
-				},
-			},
-		}, {
-			name: "With NewValues, adds default value when variable has no default value set",
-			tfFiles: map[string]string{
-				"main.tf": tfNoDefault,
-			},
-			expectedTfFiles: map[string]string{
-				"main.tf": tfDefaultAdded,
+				"plain_script": "echo new-image",
 			},
-			overwriteConfig: overwriteConfig{
-				NewValues: map[string]string{
-					"value_to_replace": "new-value",
-				},
-			},
-		}, {
-			name: "With NewValues, fail when variable default value is not a string",
-			tfFiles: map[string]string{
-				"main.tf": tfNoDefaultWrongType,
-			},
-			overwriteConfig: overwriteConfig{
-				NewValues: map[string]string{
-					"value_to_replace": "new-value",
-				},
-			},
-			errorContains: "image variable: value_to_replace must be type string",
 		},
-	}
-
-	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			tmpDir, err := os.MkdirTemp("", "tftest")
-			assert.NoError(t, err)
-			defer os.RemoveAll(tmpDir)
-
-			for file, content := range tc.tfFiles {
-				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
-				assert.NoError(t, err)
-			}
-
-			err = OverwriteTf(&tc.overwriteConfig, tmpDir)
-
-			if tc.errorContains == "" {
-				assert.NoError(t, err)
-
-				actualContents, err := getDirContents(tmpDir)
-				assert.NoError(t, err)
-				assert.Equal(t, tc.expectedTfFiles, actualContents)
-			} else {
-				assert.Error(t, err)
-				assert.ErrorContains(t, err, tc.errorContains)
-			}
-		})
-	}
-}
-
-func TestGetOverwriteConfig(t *testing.T) {
-	testcases := []struct {
-		name           string
-		configBytes    []byte
-		expectedConfig overwriteConfig
-		errorContains  string
-	}{{
-		name:          "Invalid overwrite config shows parsing error",
-		configBytes:   []byte("not valid json"),
-		errorContains: "failure parsing overwrite config",
+		errorContains: "failure overwriting variable: plain_script error: heredoc value can only be replaced with a value ending in a newline",
 	}, {
-		name: "Parses overwrite config",
-		configBytes: []byte(`
-{
-	"variables": ["source_image"],
-	"replacements": {"old_image": "new_image" },
-	"newValues": {
-		"source_image": "new_image"
-	}
-}
-`),
-		expectedConfig: overwriteConfig{
-			Variables: []string{"source_image"},
+		name: "CaseInsensitiveNames, overwrite variables regardless of case",
+		tfFiles: map[string]string{
+			"main.tf": strings.ReplaceAll(mainTf, `"value_to_replace"`, `"Value_To_Replace"`),
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": strings.ReplaceAll(mainTfReplaced, `"value_to_replace"`, `"Value_To_Replace"`),
+		},
+		overwriteConfig: overwriteConfig{
+			CaseInsensitiveNames: true,
+			Variables:            []string{"value_to_replace", "OTHER_VALUE_TO_REPLACE"},
 			Replacements: map[string]string{
-				"old_image": "new_image",
+				"original-value": "new-value",
+				"old-value":      "newer-value",
 			},
+		},
+	}, {
+		name: "Fail when variable differs by case without CaseInsensitiveNames",
+		tfFiles: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwriteConfig: overwriteConfig{
 			NewValues: map[string]string{
-				"source_image": "new_image",
+				"Value_To_Replace": "new-value",
 			},
 		},
-	},
-	}
-
-	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			config, err := GetOverwriteConfig([]byte(tc.configBytes))
-			if tc.errorContains == "" {
-				assert.NoError(t, err)
-				assert.Equal(t, &tc.expectedConfig, config)
-			} else {
-				assert.Error(t, err)
-				assert.ErrorContains(t, err, tc.errorContains)
-			}
-		})
-	}
-}
-
-func TestOverwriteMetadata(t *testing.T) {
-	testcases := []struct {
-		name             string
-		originalMetadata string
-		expectedMetadata string
-		overwriteConfig  overwriteConfig
-		errorContains    string
-	}{{
-		name:             "Overwrite multiple variables",
-		originalMetadata: metadata,
-		expectedMetadata: metadataReplaced,
+		errorContains: "variable: Value_To_Replace not found",
+	}, {
+		name: "CaseInsensitiveNames, fail when variables differ only by case",
+		tfFiles: map[string]string{
+			"main.tf":  mainTf,
+			"other.tf": strings.ReplaceAll(mainTf, `"value_to_replace"`, `"Value_To_Replace"`),
+		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"source_image", "another_image"},
-			Replacements: map[string]string{
-				"old-image":   "new-image",
-				"older-image": "newer-image",
+			CaseInsensitiveNames: true,
+			NewValues: map[string]string{
+				"VALUE_TO_REPLACE": "new-value",
 			},
 		},
+		errorContains: "variable: VALUE_TO_REPLACE ambiguously matches variables differing only by case: [Value_To_Replace value_to_replace]",
 	}, {
-		name:             "Fail when metadata is invalid yaml",
-		originalMetadata: "- not validyaml\ninvalid-",
-		errorContains:    "failure parsing metadata.yaml",
-	}, {
-		name:             "Fail when variable not present in Metadata",
-		originalMetadata: metadata,
+		name: "Overwrite image digests, keeping tags and repositories",
+		tfFiles: map[string]string{
+			"main.tf": tfDigests,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfDigestsReplaced,
+		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"missing_variable"},
-			Replacements: map[string]string{
-				"original-value": "new-value",
+			Variables: []string{"app_image", "registry_image"},
+			Digests: map[string]string{
+				"gcr.io/proj/app":             "sha256:" + strings.Repeat("b", 64),
+				"localhost:5000/proj/sidecar": "sha256:" + strings.Repeat("d", 64),
 			},
 		},
-		errorContains: "Missing valid default value for variable: missing_variable",
 	}, {
-		name:             "Fail when variable has no default value set",
-		originalMetadata: metadataNoDefault,
+		name: "Re-running overwrite on replaced digests is a no-op",
+		tfFiles: map[string]string{
+			"main.tf": tfDigestsReplaced,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfDigestsReplaced,
+		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"source_image"},
-			Replacements: map[string]string{
-				"original-value": "new-value",
+			Variables: []string{"app_image", "registry_image"},
+			Digests: map[string]string{
+				"gcr.io/proj/app":             "sha256:" + strings.Repeat("b", 64),
+				"localhost:5000/proj/sidecar": "sha256:" + strings.Repeat("d", 64),
 			},
 		},
-		errorContains: "Missing valid default value for variable: source_image",
 	}, {
-		name:             "Fail when variable default value is not in replacements",
-		originalMetadata: metadata,
+		name: "Fail when image is not pinned to a digest",
+		tfFiles: map[string]string{
+			"main.tf": tfDigests,
+		},
 		overwriteConfig: overwriteConfig{
-			Variables: []string{"source_image"},
-			Replacements: map[string]string{
-				"non-existent": "new-value",
+			Variables: []string{"tagged_image"},
+			Digests: map[string]string{
+				"gcr.io/proj/app": "sha256:" + strings.Repeat("b", 64),
 			},
 		},
-		errorContains: "default value: old-image of variable: source_image in metadata.yaml not found in replacements",
+		errorContains: "default value: gcr.io/proj/app:1.2.3 of variable: tagged_image not found in replacements",
 	}, {
-		name:             "With NewValues, overwrite multiple variables",
-		originalMetadata: metadata,
-		expectedMetadata: metadataReplaced,
+		name: "Overwrite fields of object defaults by key path",
+		tfFiles: map[string]string{
+			"main.tf": tfObjects,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfObjectsReplaced,
+		},
 		overwriteConfig: overwriteConfig{
 			NewValues: map[string]string{
-				"source_image":  "new-image",
-				"another_image": "newer-image",
+				"infra.image":          "new-image",
+				"infra.sidecar.image":  "new-sidecar",
+				"images_by_zone.east1": "new-east-image",
 			},
 		},
 	}, {
-		name:             "With NewValues, ignores Variables and Replacements",
-		originalMetadata: metadata,
-		expectedMetadata: metadataReplaced,
+		name: "Overwrite fields of object defaults by key path in .tf.json files",
+		tfFiles: map[string]string{
+			"objects.tf.json": tfJSONObjects,
+		},
+		expectedTfFiles: map[string]string{
+			"objects.tf.json": tfJSONObjectsReplaced,
+		},
 		overwriteConfig: overwriteConfig{
 			NewValues: map[string]string{
-				"source_image":  "new-image",
-				"another_image": "newer-image",
+				"infra.image": "new-image",
 			},
-			Variables: []string{"source_image", "another_image"},
+		},
+	}, {
+		name: "Fail when key path not found in object default",
+		tfFiles: map[string]string{
+			"main.tf": tfObjects,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"infra.missing.image": "new-image",
+			},
+		},
+		errorContains: "key path: missing.image not found in default value of variable: infra",
+	}, {
+		name: "Fail when key path resolves to a non-string value",
+		tfFiles: map[string]string{
+			"main.tf": tfObjects,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"infra.size": "20",
+			},
+		},
+		errorContains: "value at key path: size of variable: infra must be type string",
+	}, {
+		name: "Fail when overwriting a sensitive variable",
+		tfFiles: map[string]string{
+			"main.tf": tfSensitive,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"db_password": "new-password",
+			},
+		},
+		errorContains: "variable: db_password is marked sensitive",
+	}, {
+		name: "Overwrite a sensitive variable with AllowSensitive",
+		tfFiles: map[string]string{
+			"main.tf": tfSensitive,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfSensitiveReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			AllowSensitive: true,
+			Variables:      []string{"db_password"},
 			Replacements: map[string]string{
-				"old-image":   "new-image-unused",
-				"older-image": "newer-image-unused",
+				"old-password": "new-password",
 			},
 		},
 	}, {
-		name:             "With NewValues, fail when variable is not present in Metadata",
-		originalMetadata: metadata,
+		name: "Overwrite string literals within a non-literal default",
+		tfFiles: map[string]string{
+			"main.tf": tfConditional,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfConditionalReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/beta-image":   "projects/mpi/global/images/beta-image",
+				"projects/partner/global/images/stable-image": "projects/mpi/global/images/stable-image",
+			},
+		},
+	}, {
+		name: "Leave non-literal defaults of other variables untouched",
+		tfFiles: map[string]string{
+			"main.tf": tfConditional,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfConditionalOtherReplaced,
+		},
 		overwriteConfig: overwriteConfig{
 			NewValues: map[string]string{
-				"missing_variable": "new-value",
+				"machine_type": "e2-standard-2",
 			},
 		},
-		errorContains: "missing variable entry for variable: missing_variable",
 	}, {
-		name:             "With NewValues, adds default value when variable has no default value set",
-		originalMetadata: metadataNoDefault,
-		expectedMetadata: metadataDefaultAdded,
+		name: "Fail when a non-literal default has no replacements",
+		tfFiles: map[string]string{
+			"main.tf": tfConditional,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/other-image": "projects/mpi/global/images/other-image",
+			},
+		},
+		errorContains: "variable: source_image has a non-literal default, cannot overwrite",
+	}, {
+		name: "Fail when setting a new value for a non-literal default",
+		tfFiles: map[string]string{
+			"main.tf": tfConditional,
+		},
 		overwriteConfig: overwriteConfig{
 			NewValues: map[string]string{
-				"source_image": "new-value",
+				"source_image": "projects/mpi/global/images/stable-image",
 			},
 		},
-	},
-	}
-
-	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			tmpDir, err := os.MkdirTemp("", "tftest")
-			assert.NoError(t, err)
-			defer os.RemoveAll(tmpDir)
-
-			err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"),
-				[]byte(tc.originalMetadata), 0600)
-			assert.NoError(t, err)
-
-			err = OverwriteMetadata(&tc.overwriteConfig, tmpDir)
-
-			if tc.errorContains == "" {
-				assert.NoError(t, err)
-
-				metadataBytes, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
-				assert.NoError(t, err)
-				actualMetadata := make(map[interface{}]interface{})
-				expectedMetadata := make(map[interface{}]interface{})
-
-				err = yaml.Unmarshal(metadataBytes, &actualMetadata)
-				assert.NoError(t, err)
-
-				err = yaml.Unmarshal([]byte(tc.expectedMetadata), expectedMetadata)
-				assert.NoError(t, err)
-
-				assert.Equal(t, expectedMetadata, actualMetadata)
-			} else {
-				assert.Error(t, err)
-				assert.ErrorContains(t, err, tc.errorContains)
-			}
-		})
-	}
-}
-
-func TestOverwriteMetadataNoFile(t *testing.T) {
-	tmpDir, err := os.MkdirTemp("", "tftest")
-	assert.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
-
-	err = OverwriteMetadata(&overwriteConfig{}, tmpDir)
-	assert.NoError(t, err)
-}
-
-func TestOverwiteMetadataPermissionError(t *testing.T) {
-	tmpDir, err := os.MkdirTemp("", "tftest")
-	assert.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
-
-	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte("file"), 0111)
-	assert.NoError(t, err)
-
-	err = OverwriteMetadata(&overwriteConfig{}, tmpDir)
-	assert.Error(t, err)
-	assert.True(t, os.IsPermission(err))
-}
-
-func getDirContents(dir string) (map[string]string, error) {
-	fileContents := make(map[string]string)
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		b, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		fileContents[path[len(dir)+1:]] = string(b)
-
-		return nil
-	})
-	return fileContents, err
-}
-
-func TestOverwriteDisplay(t *testing.T) {
-	testcases := []struct {
-		name                    string
-		originalMetadataDisplay string
-		expectedMetadataDisplay string
-		overwriteConfig         overwriteConfig
-		errorContains           string
-	}{
-		{
-			name:                    "Overwrite single display variable enum values",
-			originalMetadataDisplay: metadataDisplayWithEnumsSingle,
-			expectedMetadataDisplay: metadataDisplayWithEnumsSingleReplaced,
-			overwriteConfig: overwriteConfig{
-				Variables: []string{"source_image"},
-				Replacements: map[string]string{
-					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
-				},
+		errorContains: "variable: source_image has a non-literal default, cannot overwrite",
+	}, {
+		name: "Overwrite defaults of variables with validation blocks, leaving the blocks intact",
+		tfFiles: map[string]string{
+			"main.tf": tfValidation,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfValidationReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			CheckValidations: true,
+			NewValues: map[string]string{
+				"source_image": "projects/mpi/global/images/new-image",
 			},
 		},
-		{
-			name:                    "Overwrite multiple display variable enum values",
-			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
-			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
-			overwriteConfig: overwriteConfig{
-				Variables: []string{"source_image", "another_image"},
-				Replacements: map[string]string{
-					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
-					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
-					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
-				},
+	}, {
+		name: "Fail when a new default fails a validation condition",
+		tfFiles: map[string]string{
+			"main.tf": tfValidation,
+		},
+		overwriteConfig: overwriteConfig{
+			CheckValidations: true,
+			Variables:        []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/old-image": "new-image",
 			},
 		},
-		{
-			name:                    "No changes if no display variable enum value labels",
-			originalMetadataDisplay: metadataDisplayNoEnums,
-			expectedMetadataDisplay: metadataDisplayNoEnums,
-			overwriteConfig: overwriteConfig{
-				Variables:    []string{"source_image"},
-				Replacements: map[string]string{},
+		errorContains: "new default: new-image of variable: source_image fails validation: " +
+			"The source image must be a GCE image URI.",
+	}, {
+		name: "Overwrite defaults failing validation conditions without CheckValidations",
+		tfFiles: map[string]string{
+			"main.tf": tfValidation,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfValidationInvalidReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "new-image",
 			},
-		}, {
-			name:                    "Fail when metadata display is invalid yaml",
-			originalMetadataDisplay: "- not validyaml\ninvalid-",
-			errorContains:           "failure parsing metadata.display.yaml",
-		}, {
-			name:                    "Fail when display variable not present in Metadata display",
-			originalMetadataDisplay: metadata,
-			overwriteConfig: overwriteConfig{
-				Variables: []string{"missing_variable"},
-				Replacements: map[string]string{
-					"original-value": "new-value",
-				},
+		},
+	}, {
+		name: "Overwrite NewValues matching their expected values",
+		tfFiles: map[string]string{
+			"main.tf": tfObjects,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfObjectsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"infra.image":          "new-image",
+				"infra.sidecar.image":  "new-sidecar",
+				"images_by_zone.east1": "new-east-image",
 			},
-			errorContains: "missing valid display info for variable: missing_variable",
-		}, {
-			name:                    "Fail when display variable enum value is not in replacements",
-			originalMetadataDisplay: metadataDisplayWithEnumsSingle,
-			overwriteConfig: overwriteConfig{
-				Variables: []string{"source_image"},
-				Replacements: map[string]string{
-					"non-existent": "new-value",
-				},
+			Expected: map[string]string{
+				"infra.image":         "old-image",
+				"infra.sidecar.image": "old-sidecar",
 			},
-			errorContains: "enum value: projects/click-to-deploy-images/global/images/wordpress-1 of variable: source_image in metadata.display.yaml not found in replacements",
-		}}
+		},
+	}, {
+		name: "Re-running overwrite with expected values is a no-op",
+		tfFiles: map[string]string{
+			"main.tf": tfObjectsReplaced,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfObjectsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"infra.image": "new-image",
+			},
+			Expected: map[string]string{
+				"infra.image": "old-image",
+			},
+		},
+	}, {
+		name: "Fail when current value does not match expected value",
+		tfFiles: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+			Expected: map[string]string{
+				"value_to_replace": "changed-value",
+			},
+		},
+		errorContains: "current value: original-value of variable: value_to_replace does not match expected value: changed-value",
+	}, {
+		name: "Overwrite variables selected by type",
+		tfFiles: map[string]string{
+			"main.tf": tfTyped,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfTypedReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Strict:        true,
+			VariableTypes: []string{"string", "list"},
+			Replacements: map[string]string{
+				"old-image":   "new-image",
+				"old-image-a": "new-image-a",
+			},
+		},
+	}, {
+		name: "Fail when variable not present in Terraform module",
+		tfFiles: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"missing_variable"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "variable: missing_variable not found",
+	}, {
+		name: "Fail when variable has no default value set",
+		tfFiles: map[string]string{
+			"main.tf": tfNoDefault,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "variable: value_to_replace must have default value",
+	}, {
+		name: "Fail when variable default value is not a string",
+		tfFiles: map[string]string{
+			"main.tf": tfDefaultWrongType,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "image variable: value_to_replace must be type string",
+	}, {
+		name: "Fail when variable default value is not in replacements",
+		tfFiles: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace"},
+			Replacements: map[string]string{
+				"non-existent": "new-value",
+			},
+		},
+		errorContains: "default value: original-value of variable: value_to_replace not found in replacements",
+	}, {
+		name: "Fail when a later file fails, without overwriting earlier files",
+		tfFiles: map[string]string{
+			"main.tf":        mainTf,
+			"anyfilename.tf": otherTf,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace", "another_variable"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "default value: oldest-value of variable: another_variable not found in replacements",
+	}, {
+		name: "Overwrite elements of list and map defaults",
+		tfFiles: map[string]string{
+			"main.tf": tfCollections,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfCollectionsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"images", "images_by_zone"},
+			Replacements: map[string]string{
+				"old-image-a": "new-image-a",
+				"old-image-b": "new-image-b",
+				"us-east1-b":  "not-a-key",
+			},
+		},
+	}, {
+		name: "Strict, fail when element of list default is not in replacements",
+		tfFiles: map[string]string{
+			"main.tf": tfCollections,
+		},
+		overwriteConfig: overwriteConfig{
+			Strict:    true,
+			Variables: []string{"images"},
+			Replacements: map[string]string{
+				"old-image-a": "new-image-a",
+			},
+		},
+		errorContains: "element: old-image-b of default value of variable: images not found in replacements",
+	}, {
+		name: "Overwrite variables in mixed .tf and .tf.json files",
+		tfFiles: map[string]string{
+			"main.tf":             mainTf,
+			"variables.tf.json":   tfJSON,
+			"collections.tf.json": tfJSONCollections,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf":             mainTfReplaced,
+			"variables.tf.json":   tfJSONReplaced,
+			"collections.tf.json": tfJSONCollectionsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace", "other_value_to_replace", "another_variable", "images"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+				"old-value":      "newer-value",
+				"oldest-value":   "newest-value",
+				"old-image-a":    "new-image-a",
+			},
+		},
+	}, {
+		name: "Overwrite variables in .tf.json files with the array form of blocks",
+		tfFiles: map[string]string{
+			"variables.tf.json": tfJSONArrayForm,
+		},
+		expectedTfFiles: map[string]string{
+			"variables.tf.json": tfJSONArrayFormReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"another_variable", "images"},
+			Replacements: map[string]string{
+				"oldest-value": "newest-value",
+				"old-image-a":  "new-image-a",
+			},
+		},
+	}, {
+		name: "With NewValues, overwrite multiple variables and files",
+		tfFiles: map[string]string{
+			"main.tf":        mainTf,
+			"anyfilename.tf": otherTf,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf":        mainTfReplaced,
+			"anyfilename.tf": otherTfReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"value_to_replace":       "new-value",
+				"other_value_to_replace": "newer-value",
+				"another_variable":       "newest-value",
+			},
+		},
+	}, {
+		name: "With NewValues, overwrite multiple variables and files. Add consumer label",
+		tfFiles: map[string]string{
+			"main.tf": mainTfNoLabel,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": mainTfLabelUpserted,
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel: "new-consumer-label",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+	}, {
+		name: "With NewValues, overwrite multiple variables and files. Do not replace existing consumer label",
+		tfFiles: map[string]string{
+			"main.tf": mainTfProvidedLabel,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": mainTfLabelUpserted,
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel: "even-newer-consumer-label",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+	}, {
+		name: "With ForceConsumerLabel, replace existing consumer label",
+		tfFiles: map[string]string{
+			"main.tf": mainTfProvidedLabel,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": strings.Replace(mainTfLabelUpserted, "new-consumer-label", "even-newer-consumer-label", 1),
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel:      "even-newer-consumer-label",
+			ForceConsumerLabel: true,
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+	}, {
+		name: "With ForceConsumerLabel, replace existing consumer labels and preserve other labels",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderExistingLabels,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": strings.ReplaceAll(tfProviderExistingLabels, "old-consumer-label", "new-consumer-label"),
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel:      "new-consumer-label",
+			ForceConsumerLabel: true,
+		},
+	}, {
+		name: "Add consumer label under a custom key",
+		tfFiles: map[string]string{
+			"main.tf": mainTfNoLabel,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": strings.Replace(mainTfLabelUpserted, "goog-partner-solution", "goog-test-solution", 1),
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel:    "new-consumer-label",
+			ConsumerLabelKey: "goog-test-solution",
+			NewValues: map[string]string{
+				"value_to_replace": "new-value",
+			},
+		},
+	}, {
+		name: "Add multiple consumer labels without overwriting existing labels",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderMultipleLabels,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfProviderMultipleLabelsUpserted,
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel: "new-consumer-label",
+			ConsumerLabels: map[string]string{
+				"goog-tracking": "new-tracking-label",
+				"team":          "new-team",
+			},
+		},
+	}, {
+		name: "Add consumer labels to a default labels block",
+		tfFiles: map[string]string{
+			"main.tf": tfProviderLabelsBlock,
+		},
+		expectedTfFiles: map[string]string{
+			"main.tf": tfProviderLabelsBlockUpserted,
+		},
+		overwriteConfig: overwriteConfig{
+			ConsumerLabel: "new-consumer-label",
+			ConsumerLabels: map[string]string{
+				"team": "new-team",
+			},
+		},
+	},
+		{
+			name: "With NewValues, ignores Variables and Replacements",
+			tfFiles: map[string]string{
+				"main.tf":        mainTf,
+				"anyfilename.tf": otherTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":        mainTfReplaced,
+				"anyfilename.tf": otherTfReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+					"another_variable":       "newest-value",
+				},
+				Variables: []string{"value_to_replace", "other_value_to_replace", "another_variable"},
+				Replacements: map[string]string{
+					"original-value": "new-value-unused",
+					"old-value":      "newer-value-unused",
+					"oldest-value":   "newest-value-unused",
+				},
+			},
+		}, {
+			name: "With NewValues, adds default value when variable has no default value set",
+			tfFiles: map[string]string{
+				"main.tf": tfNoDefault,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfDefaultAdded,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+			},
+		}, {
+			name: "With NewValues, fail when variable default value is not a string",
+			tfFiles: map[string]string{
+				"main.tf": tfNoDefaultWrongType,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+			},
+			errorContains: "image variable: value_to_replace must be type string",
+		}, {
+			name: "Add provider block with consumer label when none exists",
+			tfFiles: map[string]string{
+				"main.tf": tfDefaultAdded,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfProviderAppended,
+			},
+			overwriteConfig: overwriteConfig{
+				ConsumerLabel: "new-consumer-label",
+			},
+		}, {
+			name: "Fail to add consumer label when the provider is only in a .tf.json file",
+			tfFiles: map[string]string{
+				"main.tf":          tfDefaultAdded,
+				"provider.tf.json": tfJSONProvider,
+			},
+			overwriteConfig: overwriteConfig{
+				ConsumerLabel: "new-consumer-label",
+			},
+			errorContains: "provider.tf.json. Consumer labels cannot be inserted into .tf.json files",
+		}, {
+			name: "Add consumer label to provider block in another file",
+			tfFiles: map[string]string{
+				"main.tf":     tfDefaultAdded,
+				"provider.tf": tfProvider,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":     tfDefaultAdded,
+				"provider.tf": tfProviderLabelUpserted,
+			},
+			overwriteConfig: overwriteConfig{
+				ConsumerLabel: "new-consumer-label",
+			},
+		}, {
+			name: "Add consumer label to aliased providers without overwriting existing label",
+			tfFiles: map[string]string{
+				"main.tf": tfProviderAliases,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfProviderAliasesLabelUpserted,
+			},
+			overwriteConfig: overwriteConfig{
+				ConsumerLabel: "new-consumer-label",
+			},
+		}, {
+			name: "Preserve comments and formatting outside of replaced values",
+			tfFiles: map[string]string{
+				"main.tf": tfWithComments,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfWithCommentsReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+			},
+		}, {
+			name: "Overwrite local values",
+			tfFiles: map[string]string{
+				"main.tf": tfLocals,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfLocalsReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "value_to_replace"},
+				Replacements: map[string]string{
+					"old-image":      "new-image",
+					"original-value": "new-value",
+				},
+			},
+		}, {
+			name: "With NewValues, overwrite local values",
+			tfFiles: map[string]string{
+				"main.tf": tfLocals,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfLocalsReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"source_image":     "new-image",
+					"value_to_replace": "new-value",
+				},
+			},
+		}, {
+			name: "Fail when local value is not a string literal",
+			tfFiles: map[string]string{
+				"main.tf": tfLocals,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"image_path": "new-image",
+				},
+			},
+			errorContains: "image local: image_path must be type string",
+		}, {
+			name: "Fail when local value is not in replacements",
+			tfFiles: map[string]string{
+				"main.tf": tfLocals,
+			},
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image"},
+				Replacements: map[string]string{
+					"non-existent": "new-image",
+				},
+			},
+			errorContains: "default value: old-image of local: source_image not found in replacements",
+		}, {
+			name: "Overwrite variables matching a pattern",
+			tfFiles: map[string]string{
+				"main.tf":        mainTf,
+				"anyfilename.tf": otherTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":        mainTfReplaced,
+				"anyfilename.tf": otherTf,
+			},
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"*value_to_replace"},
+				Replacements: map[string]string{
+					"original-value": "new-value",
+					"old-value":      "newer-value",
+				},
+			},
+		}, {
+			name: "Fail when variable pattern matches no variables",
+			tfFiles: map[string]string{
+				"main.tf": mainTf,
+			},
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"image_*"},
+				Replacements: map[string]string{
+					"original-value": "new-value",
+				},
+			},
+			errorContains: "variable pattern: image_* did not match any variables in module",
+		}, {
+			name: "Recursive, overwrite variables in nested modules",
+			tfFiles: map[string]string{
+				"main.tf":                 mainTf,
+				"modules/db/variables.tf": otherTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":                 mainTfReplaced,
+				"modules/db/variables.tf": otherTfReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive: true,
+				Variables: []string{"value_to_replace", "other_value_to_replace", "another_variable"},
+				Replacements: map[string]string{
+					"original-value": "new-value",
+					"old-value":      "newer-value",
+					"oldest-value":   "newest-value",
+				},
+			},
+		}, {
+			name: "Scoped, overwrite variable only in matching files",
+			tfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTfReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive: true,
+				Variables: []string{"value_to_replace", "other_value_to_replace"},
+				Replacements: map[string]string{
+					"original-value": "new-value",
+					"old-value":      "newer-value",
+				},
+				Scopes: []VariableScope{{
+					File:      "modules/db/*.tf",
+					Variables: []string{"value_to_replace", "other_value_to_replace"},
+				}},
+			},
+		}, {
+			name: "Scoped, fail when variable not present in matching files",
+			tfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTf,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive: true,
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+				Scopes: []VariableScope{{
+					File:      "variables.tf",
+					Variables: []string{"value_to_replace"},
+				}},
+			},
+			errorContains: "variable: value_to_replace not found in files matching: [variables.tf]",
+		}, {
+			name: "RejectDuplicates, fail when variable is declared in multiple files",
+			tfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTf,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive:        true,
+				RejectDuplicates: true,
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+			},
+			errorContains: "variable: value_to_replace is declared in multiple files: [",
+		}, {
+			name: "RejectDuplicates, overwrite variables allowed in multiple files",
+			tfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":            mainTfReplaced,
+				"modules/db/main.tf": mainTfReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive:        true,
+				RejectDuplicates: true,
+				AllowDuplicates:  []string{"value_to_replace", "other_value_to_replace"},
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+			},
+		}, {
+			name: "RejectDuplicates, overwrite variable scoped to a single file",
+			tfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTf,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":            mainTf,
+				"modules/db/main.tf": mainTfReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive:        true,
+				RejectDuplicates: true,
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+				Scopes: []VariableScope{{
+					File:      "modules/db/main.tf",
+					Variables: []string{"value_to_replace", "other_value_to_replace"},
+				}},
+			},
+		}, {
+			name: "Not recursive, fail when variable is only in nested module",
+			tfFiles: map[string]string{
+				"main.tf":                 mainTf,
+				"modules/db/variables.tf": otherTf,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"another_variable": "newest-value",
+				},
+			},
+			errorContains: "variable: another_variable not found",
+		}, {
+			name: "Recursive, fail when variable not present lists searched directories",
+			tfFiles: map[string]string{
+				"main.tf":                 mainTf,
+				"modules/db/variables.tf": otherTf,
+			},
+			overwriteConfig: overwriteConfig{
+				Recursive: true,
+				NewValues: map[string]string{
+					"missing_variable": "new-value",
+				},
+			},
+			errorContains: "modules/db]",
+		}, {
+			name: "Preserve CRLF line endings",
+			tfFiles: map[string]string{
+				"main.tf": strings.ReplaceAll(mainTf, "\n", "\r\n"),
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": strings.ReplaceAll(mainTfReplaced, "\n", "\r\n"),
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+			},
+		}, {
+			name: "Preserve CRLF line endings of added default values",
+			tfFiles: map[string]string{
+				"main.tf": strings.ReplaceAll(tfNoDefault, "\n", "\r\n"),
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": strings.ReplaceAll(tfDefaultAdded, "\n", "\r\n"),
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace": "new-value",
+				},
+			},
+		}, {
+			name: "Keep LF line endings of files with few CRLF line endings",
+			tfFiles: map[string]string{
+				"main.tf": strings.Replace(mainTf, "\n", "\r\n", 1),
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": strings.Replace(mainTfReplaced, "\n", "\r\n", 1),
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+			},
+		}, {
+			name: "Overwrite resource attributes using replacements",
+			tfFiles: map[string]string{
+				"main.tf": tfResourceImages,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfResourceImagesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ResourceAttributes: []string{
+					"google_compute_instance_template.disk.source_image",
+					"google_compute_instance.boot_disk.initialize_params.image",
+				},
+				Replacements: map[string]string{
+					"projects/old-project/global/images/old-image": "projects/new-project/global/images/new-image",
+					"old-boot-image": "new-boot-image",
+				},
+			},
+		}, {
+			name: "Overwrite resource attributes together with new values",
+			tfFiles: map[string]string{
+				"main.tf":  mainTf,
+				"image.tf": tfResourceImages,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":  mainTfReplaced,
+				"image.tf": tfResourceImagesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+				ResourceAttributes: []string{
+					"google_compute_instance_template.disk.source_image",
+					"google_compute_instance.boot_disk.initialize_params.image",
+				},
+				Replacements: map[string]string{
+					"projects/old-project/global/images/old-image": "projects/new-project/global/images/new-image",
+					"old-boot-image": "new-boot-image",
+				},
+			},
+		}, {
+			name: "Re-running overwrite on replaced resource attributes is a no-op",
+			tfFiles: map[string]string{
+				"main.tf": tfResourceImagesReplaced,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfResourceImagesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ResourceAttributes: []string{
+					"google_compute_instance_template.disk.source_image",
+					"google_compute_instance.boot_disk.initialize_params.image",
+				},
+				Replacements: map[string]string{
+					"projects/old-project/global/images/old-image": "projects/new-project/global/images/new-image",
+					"old-boot-image": "new-boot-image",
+				},
+				Strict: true,
+			},
+		}, {
+			name: "Strict, fail when resource attribute has no replacement",
+			tfFiles: map[string]string{
+				"main.tf": tfResourceImages,
+			},
+			overwriteConfig: overwriteConfig{
+				ResourceAttributes: []string{"google_compute_instance.boot_disk.initialize_params.image"},
+				Replacements: map[string]string{
+					"projects/old-project/global/images/old-image": "projects/new-project/global/images/new-image",
+				},
+				Strict: true,
+			},
+			errorContains: "value: old-boot-image of resource attribute:" +
+				" google_compute_instance.vm.boot_disk.initialize_params.image in",
+		}, {
+			name: "Overwrite provider version constraints",
+			tfFiles: map[string]string{
+				"main.tf":     mainTf,
+				"versions.tf": tfRequiredProviders,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf":     mainTfReplaced,
+				"versions.tf": tfRequiredProvidersReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				NewValues: map[string]string{
+					"value_to_replace":       "new-value",
+					"other_value_to_replace": "newer-value",
+				},
+				ProviderVersions: map[string]string{
+					"google":      ">= 5.10, < 6",
+					"google-beta": ">= 5.10, < 6",
+					"random":      "3.6.0",
+				},
+			},
+		}, {
+			name: "Re-running overwrite on provider version constraints is a no-op",
+			tfFiles: map[string]string{
+				"versions.tf": tfRequiredProvidersReplaced,
+			},
+			expectedTfFiles: map[string]string{
+				"versions.tf": tfRequiredProvidersReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ProviderVersions: map[string]string{
+					"google":      ">= 5.10, < 6",
+					"google-beta": ">= 5.10, < 6",
+					"random":      "3.6.0",
+				},
+			},
+		}, {
+			name: "Fail when provider is not declared",
+			tfFiles: map[string]string{
+				"versions.tf": tfRequiredProviders,
+			},
+			overwriteConfig: overwriteConfig{
+				ProviderVersions: map[string]string{
+					"aws": "~> 5.0",
+				},
+			},
+			errorContains: "provider: aws not found in required_providers",
+		}, {
+			name: "Fail when provider has no version constraint",
+			tfFiles: map[string]string{
+				"versions.tf": `terraform {
+  required_providers {
+    google = {
+      source = "hashicorp/google"
+    }
+  }
+}
+`,
+			},
+			overwriteConfig: overwriteConfig{
+				ProviderVersions: map[string]string{
+					"google": "~> 5.0",
+				},
+			},
+			errorContains: "version constraint of provider: google not found in",
+		}, {
+			name: "Overwrite module sources by name",
+			tfFiles: map[string]string{
+				"main.tf": tfModules,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfModulesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ModuleSources: map[string]string{
+					"db":  "GoogleCloudPlatform/db/google",
+					"web": "GoogleCloudPlatform/web/google//modules/frontend",
+				},
+			},
+		}, {
+			name: "Overwrite module sources using replacements",
+			tfFiles: map[string]string{
+				"main.tf": tfModules,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfModulesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ReplaceModuleSources: true,
+				ModuleSources: map[string]string{
+					"web": "GoogleCloudPlatform/web/google//modules/frontend",
+				},
+				Replacements: map[string]string{
+					"../modules/db":  "GoogleCloudPlatform/db/google",
+					"../modules/web": "unused",
+				},
+			},
+		}, {
+			name: "Re-running overwrite on replaced module sources is a no-op",
+			tfFiles: map[string]string{
+				"main.tf": tfModulesReplaced,
+			},
+			expectedTfFiles: map[string]string{
+				"main.tf": tfModulesReplaced,
+			},
+			overwriteConfig: overwriteConfig{
+				ReplaceModuleSources: true,
+				Replacements: map[string]string{
+					"../modules/db":  "GoogleCloudPlatform/db/google",
+					"../modules/web": "GoogleCloudPlatform/web/google//modules/frontend",
+				},
+				Strict: true,
+			},
+		}, {
+			name: "Strict, fail when module source has no replacement",
+			tfFiles: map[string]string{
+				"main.tf": tfModules,
+			},
+			overwriteConfig: overwriteConfig{
+				ReplaceModuleSources: true,
+				Replacements: map[string]string{
+					"../modules/db": "GoogleCloudPlatform/db/google",
+				},
+				Strict: true,
+			},
+			errorContains: "source: ../modules/web of module: web in",
+		}, {
+			name: "Fail when module is not declared",
+			tfFiles: map[string]string{
+				"main.tf": tfModules,
+			},
+			overwriteConfig: overwriteConfig{
+				ModuleSources: map[string]string{
+					"cache": "GoogleCloudPlatform/cache/google",
+				},
+			},
+			errorContains: "module: cache not found in Terraform files",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.tfFiles {
+				err = os.MkdirAll(path.Dir(path.Join(tmpDir, file)), 0700)
+				assert.NoError(t, err)
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteTf(&tc.overwriteConfig, tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedTfFiles, actualContents)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+
+				// A failed overwrite leaves the original files untouched
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.tfFiles, actualContents)
+			}
+		})
+	}
+}
+
+func TestGetOverwriteConfig(t *testing.T) {
+	testcases := []struct {
+		name           string
+		configBytes    []byte
+		expectedConfig overwriteConfig
+		errorContains  string
+	}{{
+		name:          "Invalid overwrite config shows parsing error",
+		configBytes:   []byte("not valid json"),
+		errorContains: "failure parsing overwrite config",
+	}, {
+		name: "Parses overwrite config",
+		configBytes: []byte(`
+{
+	"variables": ["source_image"],
+	"replacements": {"old_image": "new_image" },
+	"newValues": {
+		"source_image": "new_image"
+	}
+}
+`),
+		expectedConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"old_image": "new_image",
+			},
+			NewValues: map[string]string{
+				"source_image": "new_image",
+			},
+		},
+	}, {
+		name: "Parses YAML overwrite config",
+		configBytes: []byte(`
+variables:
+- source_image
+replacements:
+  old_image: new_image
+newValues:
+  source_image: new_image
+`),
+		expectedConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"old_image": "new_image",
+			},
+			NewValues: map[string]string{
+				"source_image": "new_image",
+			},
+		},
+	}, {
+		name: "Parses regex replacements",
+		configBytes: []byte(`
+variables:
+- source_image
+regexReplacements:
+- pattern: ^projects/x/images/app-v1\.2\.3$
+  replacement: projects/x/images/app-v1.2.4
+`),
+		expectedConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			RegexReplacements: []RegexRule{{
+				Pattern:     `^projects/x/images/app-v1\.2\.3$`,
+				Replacement: "projects/x/images/app-v1.2.4",
+			}},
+		},
+	}, {
+		name: "Fail when regex replacement pattern is invalid",
+		configBytes: []byte(`
+{
+	"variables": ["source_image"],
+	"regexReplacements": [{"pattern": "app-v(1", "replacement": "app-v2"}]
+}
+`),
+		errorContains: "invalid overwrite config: regex replacement pattern: app-v(1",
+	}, {
+		name: "Fail on unknown field",
+		configBytes: []byte(`
+{
+	"variables": ["source_image"],
+	"replacement": {"old_image": "new_image" }
+}
+`),
+		errorContains: `json: unknown field "replacement"`,
+	}, {
+		name: "Fail on unknown field in YAML",
+		configBytes: []byte(`
+variables:
+- source_image
+regexReplacements:
+- pattern: old
+  replace: new
+`),
+		errorContains: `json: unknown field "replace"`,
+	}, {
+		name: "Fail when digest is invalid",
+		configBytes: []byte(`
+{
+	"variables": ["source_image"],
+	"digests": {"gcr.io/proj/app": "latest"}
+}
+`),
+		errorContains: "invalid overwrite config: digest: latest of image repository: gcr.io/proj/app must have the form sha256:<hex>",
+	}, {
+		name: "Fail when expected value has no new value",
+		configBytes: []byte(`
+{
+	"newValues": {"source_image": "new-image"},
+	"expected": {"other_image": "old-image"}
+}
+`),
+		errorContains: "invalid overwrite config: expected value of: other_image has no entry in newValues",
+	}, {
+		name: "Fail when varType is not allowed",
+		configBytes: []byte(`
+{
+	"newValues": {"source_image": "new-image"},
+	"varTypes": {"source_image": "integer"}
+}
+`),
+		errorContains: "invalid overwrite config: varType: integer of variable: source_image must be one of",
+	}, {
+		name: "Fail when lock timeout is not a duration",
+		configBytes: []byte(`
+{
+	"newValues": {"source_image": "new-image"},
+	"lock": true,
+	"lockTimeout": "30"
+}
+`),
+		errorContains: "invalid overwrite config: lock timeout: 30 error:",
+	}, {
+		name: "Fail when resource attribute has no attribute name",
+		configBytes: []byte(`
+{
+	"replacements": {"old-image": "new-image"},
+	"resourceAttributes": ["google_compute_instance"]
+}
+`),
+		errorContains: "invalid overwrite config: resource attribute: google_compute_instance must have the form",
+	}, {
+		name: "Fail when display number is not a default or bound",
+		configBytes: []byte(`
+{
+	"newValues": {"source_image": "new-image"},
+	"displayNumbers": {"disk_size": {"step": 10}}
+}
+`),
+		errorContains: "invalid overwrite config: display number: step of variable: disk_size must be one of default, max, min",
+	}, {
+		name: "Fail when scope file pattern is invalid",
+		configBytes: []byte(`
+{
+	"variables": ["source_image"],
+	"scopes": [{"file": "[main.tf", "variables": ["source_image"]}]
+}
+`),
+		errorContains: "invalid overwrite config: scope file pattern: [main.tf",
+	}, {
+		name:          "Invalid YAML overwrite config shows YAML parsing error",
+		configBytes:   []byte("variables: [source_image\nreplacements: {"),
+		errorContains: "failure parsing overwrite config as YAML",
+	}, {
+		name:          "Invalid JSON overwrite config shows parsing error",
+		configBytes:   []byte(`{"variables": ["source_image"`),
+		errorContains: "failure parsing overwrite config: ",
+	}, {
+		name: "Strict, fail when NewValues and Replacements are both set",
+		configBytes: []byte(`
+{
+	"strict": true,
+	"replacements": {"old_image": "new_image" },
+	"newValues": {
+		"source_image": "new_image"
+	}
+}
+`),
+		errorContains: "newValues is set, so variables and replacements have no effect",
+	}, {
+		name: "Strict, parses overwrite config with only NewValues",
+		configBytes: []byte(`
+{
+	"strict": true,
+	"newValues": {
+		"source_image": "new_image"
+	}
+}
+`),
+		expectedConfig: overwriteConfig{
+			Strict: true,
+			NewValues: map[string]string{
+				"source_image": "new_image",
+			},
+		},
+	}, {
+		name: "Expands environment variables in NewValues and Replacements",
+		configBytes: []byte(`
+variables:
+- source_image
+replacements:
+  old_image: ${OVERWRITE_TEST_IMAGE}
+newValues:
+  source_image: projects/${OVERWRITE_TEST_PROJECT}/global/images/${OVERWRITE_TEST_IMAGE}
+`),
+		expectedConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"old_image": "release-image",
+			},
+			NewValues: map[string]string{
+				"source_image": "projects/release-project/global/images/release-image",
+			},
+		},
+	}, {
+		name: "Fail when referenced environment variable is not set",
+		configBytes: []byte(`
+{
+	"newValues": {
+		"source_image": "${OVERWRITE_TEST_UNSET}"
+	}
+}
+`),
+		errorContains: "environment variable: OVERWRITE_TEST_UNSET referenced by: source_image is not set",
+	},
+	}
+
+	t.Setenv("OVERWRITE_TEST_IMAGE", "release-image")
+	t.Setenv("OVERWRITE_TEST_PROJECT", "release-project")
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := GetOverwriteConfig([]byte(tc.configBytes))
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, &tc.expectedConfig, config)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestGetOverwriteConfigTOML(t *testing.T) {
+	testcases := []struct {
+		name           string
+		configBytes    []byte
+		expectedConfig overwriteConfig
+		errorContains  string
+	}{{
+		name:          "Invalid TOML overwrite config shows parsing error",
+		configBytes:   []byte("variables = [source_image"),
+		errorContains: "failure parsing overwrite config as TOML",
+	}, {
+		name: "Parses TOML overwrite config",
+		configBytes: []byte(`
+variables = ["source_image"]
+dryRun = true
+
+[replacements]
+old_image = "new_image"
+
+[[regexReplacements]]
+pattern = '^projects/x/images/app-v1\.2\.3$'
+replacement = "projects/x/images/app-v1.2.4"
+`),
+		expectedConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			DryRun:    true,
+			Replacements: map[string]string{
+				"old_image": "new_image",
+			},
+			RegexReplacements: []RegexRule{{
+				Pattern:     `^projects/x/images/app-v1\.2\.3$`,
+				Replacement: "projects/x/images/app-v1.2.4",
+			}},
+		},
+	}, {
+		name: "Fail on unknown TOML keys",
+		configBytes: []byte(`
+variable = ["source_image"]
+`),
+		errorContains: `unknown field "variable"`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := GetOverwriteConfigTOML(tc.configBytes)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, &tc.expectedConfig, config)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestOverwriteMetadata(t *testing.T) {
+	testcases := []struct {
+		name             string
+		originalMetadata string
+		expectedMetadata string
+		overwriteConfig  overwriteConfig
+		errorContains    string
+	}{{
+		name:             "Overwrite multiple variables",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"old-image":   "new-image",
+				"older-image": "newer-image",
+			},
+		},
+	}, {
+		name:             "Skip templated default values without a replacement",
+		originalMetadata: metadataTemplated,
+		expectedMetadata: metadataTemplatedSkipped,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"older-image": "newer-image",
+			},
+		},
+	}, {
+		name:             "Overwrite templated default values matching a replacement literally",
+		originalMetadata: metadataTemplated,
+		expectedMetadata: metadataTemplatedReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"${var.project}/image": "projects/mpi/global/images/image",
+				"older-image":          "newer-image",
+			},
+		},
+	}, {
+		name:             "Strict, fail on templated default values without a replacement",
+		originalMetadata: metadataTemplated,
+		overwriteConfig: overwriteConfig{
+			Strict:    true,
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"older-image": "newer-image",
+			},
+		},
+		errorContains: "templated default value: ${var.project}/image of variable: source_image" +
+			" in metadata.yaml not found in replacements",
+	}, {
+		name:             "Overwrite variables and deployer image",
+		originalMetadata: metadataWithDeployer,
+		expectedMetadata: metadataWithDeployerReplaced,
+		overwriteConfig: overwriteConfig{
+			DeployerImagePath: "spec.deployerSpec.image",
+			Variables:         []string{"source_image"},
+			Replacements: map[string]string{
+				"old-image":                   "new-image",
+				"gcr.io/partner/deployer:1.0": "gcr.io/mpi/deployer:1.0",
+			},
+		},
+	}, {
+		name:             "With NewValues, overwrite deployer image using replacements",
+		originalMetadata: metadataWithDeployer,
+		expectedMetadata: metadataWithDeployerReplaced,
+		overwriteConfig: overwriteConfig{
+			DeployerImagePath: "spec.deployerSpec.image",
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+			Replacements: map[string]string{
+				"gcr.io/partner/deployer:1.0": "gcr.io/mpi/deployer:1.0",
+			},
+		},
+	}, {
+		name:             "Overwrite metadata fields",
+		originalMetadata: metadataWithAPIVersion,
+		expectedMetadata: metadataWithAPIVersionReplaced,
+		overwriteConfig: overwriteConfig{
+			MetadataFields: map[string]string{
+				"apiVersion": "blueprints.cloud.google.com/v1",
+			},
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+	}, {
+		name: "Overwrite empty metadata field",
+		originalMetadata: `spec:
+  info:
+    version:
+    title: WordPress
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: old-image
+`,
+		expectedMetadata: `spec:
+  info:
+    version: 2.0.0
+    title: WordPress
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: new-image
+`,
+		overwriteConfig: overwriteConfig{
+			MetadataFields: map[string]string{
+				"spec.info.version": "2.0.0",
+			},
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+	}, {
+		name:             "With AddMissing, add metadata fields that are not found",
+		originalMetadata: metadata,
+		expectedMetadata: `apiVersion: blueprints.cloud.google.com/v1
+
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: new-image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: older-image
+  info:
+    version: 2.0.0
+`,
+		overwriteConfig: overwriteConfig{
+			AddMissing: true,
+			MetadataFields: map[string]string{
+				"apiVersion":        "blueprints.cloud.google.com/v1",
+				"spec.info.version": "2.0.0",
+			},
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+	}, {
+		name:             "Fail when metadata field is not found",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			MetadataFields: map[string]string{
+				"apiVersion": "blueprints.cloud.google.com/v1",
+			},
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		errorContains: "metadata field: apiVersion not found in metadata.yaml",
+	}, {
+		name:             "Fail when metadata field is not a string",
+		originalMetadata: metadataWithAPIVersion,
+		overwriteConfig: overwriteConfig{
+			MetadataFields: map[string]string{
+				"spec.interfaces": "v1",
+			},
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		errorContains: "metadata field: spec.interfaces in metadata.yaml must be a string",
+	}, {
+		name:             "Fail when deployer image path is not found",
+		originalMetadata: metadataWithDeployer,
+		overwriteConfig: overwriteConfig{
+			DeployerImagePath: "spec.partnerSpec.image",
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		errorContains: "deployer image path: spec.partnerSpec.image not found in metadata.yaml",
+	}, {
+		name:             "Fail when deployer image is not in replacements",
+		originalMetadata: metadataWithDeployer,
+		overwriteConfig: overwriteConfig{
+			DeployerImagePath: "spec.deployerSpec.image",
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+		},
+		errorContains: "deployer image: gcr.io/partner/deployer:1.0 at spec.deployerSpec.image in metadata.yaml not found in replacements",
+	}, {
+		name:             "Overwrite output descriptions with new values",
+		originalMetadata: metadataWithOutputs,
+		expectedMetadata: metadataWithOutputsReplaced,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+			OutputValues: map[string]string{
+				"instance_url": "URL of the new VM instance",
+			},
+		},
+	}, {
+		name:             "Overwrite output field using replacements",
+		originalMetadata: metadataWithOutputs,
+		expectedMetadata: metadataWithOutputsExampleReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables:   []string{"source_image"},
+			OutputField: "example",
+			Outputs:     []string{"instance_url", "admin_user"},
+			Replacements: map[string]string{
+				"old-image":               "new-image",
+				"https://old.example.com": "https://new.example.com",
+				"admin":                   "root",
+			},
+		},
+	}, {
+		name:             "Fail when output is not found",
+		originalMetadata: metadataWithOutputs,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "new-image",
+			},
+			OutputValues: map[string]string{
+				"missing_output": "description",
+			},
+		},
+		errorContains: "missing output entry for output: missing_output in metadata.yaml",
+	}, {
+		name:             "Fail when output field is not in replacements",
+		originalMetadata: metadataWithOutputs,
+		overwriteConfig: overwriteConfig{
+			Variables:   []string{"source_image"},
+			OutputField: "example",
+			Outputs:     []string{"instance_url"},
+			Replacements: map[string]string{
+				"old-image": "new-image",
+			},
+		},
+		errorContains: "example: https://old.example.com of output: instance_url in metadata.yaml not found in replacements",
+	}, {
+		name:             "Overwrite varType and convert new value",
+		originalMetadata: metadataWithComments,
+		expectedMetadata: metadataVarTypeReplaced,
+		overwriteConfig: overwriteConfig{
+			VarTypes: map[string]string{
+				"source_image": "number",
+			},
+			NewValues: map[string]string{
+				"source_image": "10",
+			},
+		},
+	}, {
+		name:             "Fail when varType variable is missing",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			VarTypes: map[string]string{
+				"missing_image": "number",
+			},
+		},
+		errorContains: "missing variable entry for variable: missing_image in metadata.yaml",
+	}, {
+		name:             "CaseInsensitiveNames, overwrite variables regardless of case",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			CaseInsensitiveNames: true,
+			NewValues: map[string]string{
+				"Source_Image":  "new-image",
+				"ANOTHER_IMAGE": "newer-image",
+			},
+		},
+	}, {
+		name:             "Re-running overwrite on replaced values is a no-op",
+		originalMetadata: metadataReplaced,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"old-image":   "new-image",
+				"older-image": "newer-image",
+			},
+		},
+	}, {
+		name:             "Overwrite variables with regex replacements",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "another_image"},
+			RegexReplacements: []RegexRule{{
+				Pattern:     "^old(er)?-image$",
+				Replacement: "new${1}-image",
+			}},
+		},
+	}, {
+		name:             "Overwrite descriptions",
+		originalMetadata: metadataNoDefault,
+		expectedMetadata: metadataDescriptionReplaced,
+		overwriteConfig: overwriteConfig{
+			Descriptions: map[string]string{
+				"source_image": "Die Image für die Festplatte der VM-Instanz.",
+			},
+		},
+	}, {
+		name:             "Fail when description variable not present in Metadata",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			Descriptions: map[string]string{
+				"missing_variable": "description",
+			},
+		},
+		errorContains: "missing variable entry for variable: missing_variable in metadata.yaml",
+	}, {
+		name:             "Fail when metadata is invalid yaml",
+		originalMetadata: "- not validyaml\ninvalid-",
+		errorContains:    "failure parsing metadata.yaml",
+	}, {
+		name:             "Fail when variable not present in Metadata",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"missing_variable"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "Missing valid default value for variable: missing_variable",
+	}, {
+		name:             "Fail when variable has no default value set",
+		originalMetadata: metadataNoDefault,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+			},
+		},
+		errorContains: "Missing valid default value for variable: source_image",
+	}, {
+		name:             "Fail when variable default value is not in replacements",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"non-existent": "new-value",
+			},
+		},
+		errorContains: "default value: old-image of variable: source_image in metadata.yaml not found in replacements",
+	}, {
+		name:             "With NewValues, overwrite multiple variables",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image":  "new-image",
+				"another_image": "newer-image",
+			},
+		},
+	}, {
+		name:             "With NewValues, ignores Variables and Replacements",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image":  "new-image",
+				"another_image": "newer-image",
+			},
+			Variables: []string{"source_image", "another_image"},
+			Replacements: map[string]string{
+				"old-image":   "new-image-unused",
+				"older-image": "newer-image-unused",
+			},
+		},
+	}, {
+		name:             "With NewValues, fail when variable is not present in Metadata",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"missing_variable": "new-value",
+			},
+		},
+		errorContains: "missing variable entry for variable: missing_variable",
+	}, {
+		name:             "With NewValues and AddMissing, adds missing variable entries",
+		originalMetadata: metadataNoDefault,
+		expectedMetadata: metadataEntryAdded,
+		overwriteConfig: overwriteConfig{
+			AddMissing: true,
+			NewValues: map[string]string{
+				"source_image":  "new-image",
+				"another_image": "newer-image",
+			},
+		},
+	}, {
+		name:             "With NewValues, adds default value when variable has no default value set",
+		originalMetadata: metadataNoDefault,
+		expectedMetadata: metadataDefaultAdded,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "new-value",
+			},
+		},
+	}, {
+		name:             "Overwrite variables matching a pattern",
+		originalMetadata: metadata,
+		expectedMetadata: metadataReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"*_image"},
+			Replacements: map[string]string{
+				"old-image":   "new-image",
+				"older-image": "newer-image",
+			},
+		},
+	}, {
+		name:             "Fail when variable pattern matches no variables",
+		originalMetadata: metadata,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"image_*"},
+		},
+		errorContains: "variable pattern: image_* did not match any variables in metadata.yaml",
+	}, {
+		name:             "With NewValues, converts values to varType",
+		originalMetadata: metadataTyped,
+		expectedMetadata: metadataTypedReplaced,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"disk_size":   "20",
+				"enable_logs": "false",
+				"zones":       "us-east1-b, us-east1-c",
+				"ports":       "[80, 443]",
+			},
+		},
+	}, {
+		name:             "Converts replacements to varType",
+		originalMetadata: metadataTyped,
+		expectedMetadata: metadataTypedDiskReplaced,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"disk_size"},
+			Replacements: map[string]string{
+				"10": "20",
+			},
+		},
+	}, {
+		name:             "With NewValues, fail when value does not match varType",
+		originalMetadata: metadataTyped,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"enable_logs": "sometimes",
+			},
+		},
+		errorContains: "invalid new value for variable: enable_logs of varType: bool in metadata.yaml. error: value: sometimes is not a valid bool",
+	}, {
+		name:             "With NewValues, fail when list element does not match varType",
+		originalMetadata: metadataTyped,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"ports": "80,https",
+			},
+		},
+		errorContains: "value: https is not a valid number",
+	},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"),
+				[]byte(tc.originalMetadata), 0600)
+			assert.NoError(t, err)
+
+			_, err = OverwriteMetadata(&tc.overwriteConfig, tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+
+				metadataBytes, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+				assert.NoError(t, err)
+				actualMetadata := make(map[interface{}]interface{})
+				expectedMetadata := make(map[interface{}]interface{})
+
+				err = yaml.Unmarshal(metadataBytes, &actualMetadata)
+				assert.NoError(t, err)
+
+				err = yaml.Unmarshal([]byte(tc.expectedMetadata), expectedMetadata)
+				assert.NoError(t, err)
+
+				assert.Equal(t, expectedMetadata, actualMetadata)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestOverwriteMetadataPreservesFormatting(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte(metadataWithComments), 0600)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{
+		NewValues: map[string]string{
+			"source_image":  "new-image",
+			"another_image": "newer image: quoted",
+			"zones":         "us-east1-b, us-east1-c",
+		},
+		Descriptions: map[string]string{
+			"zones": "The zones to deploy to.",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	actual, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, metadataWithCommentsReplaced, string(actual))
+}
+
+func TestOverwriteMetadataEmptyDefault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte(metadataEmptyDefault), 0600)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{
+		NewValues: map[string]string{
+			"source_image": "new-image",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	actual, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, metadataEmptyDefaultReplaced, string(actual))
+}
+
+func TestOverwriteMetadataPreservesLineEndings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"),
+		[]byte(strings.ReplaceAll(metadataWithComments, "\n", "\r\n")), 0600)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{
+		NewValues: map[string]string{
+			"source_image":  "new-image",
+			"another_image": "newer image: quoted",
+			"zones":         "us-east1-b, us-east1-c",
+		},
+		Descriptions: map[string]string{
+			"zones": "The zones to deploy to.",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	actual, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(metadataWithCommentsReplaced, "\n", "\r\n"), string(actual))
+}
+
+func TestStripDefaults(t *testing.T) {
+	testcases := []struct {
+		name             string
+		originalMetadata string
+		expectedMetadata string
+	}{{
+		name:             "Remove default values and preserve formatting",
+		originalMetadata: metadataWithComments,
+		expectedMetadata: metadataDefaultsStripped,
+	}, {
+		name: "Remove default values spanning multiple lines or starting entries",
+		originalMetadata: `spec:
+  interfaces:
+    variables:
+    - defaultValue: old-image
+      name: source_image
+    - name: zones
+      defaultValue:
+      - us-east1-b
+      - us-east1-c
+      varType: list(string)
+`,
+		expectedMetadata: `spec:
+  interfaces:
+    variables:
+      - name: source_image
+      - name: zones
+        varType: list(string)
+`,
+	}, {
+		name:             "Leave metadata without default values as is",
+		originalMetadata: metadataDefaultsStripped,
+		expectedMetadata: metadataDefaultsStripped,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte(tc.originalMetadata), 0600)
+			assert.NoError(t, err)
+
+			err = StripDefaults(tmpDir)
+			assert.NoError(t, err)
+
+			actual, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMetadata, string(actual))
+		})
+	}
+}
+
+func TestStripDefaultsNoFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = StripDefaults(tmpDir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOverwriteMetadataAddMissingPreservesFormatting(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte(metadataNoDefault), 0600)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{
+		AddMissing: true,
+		NewValues: map[string]string{
+			"another_image": "newer-image",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	actual, err := os.ReadFile(path.Join(tmpDir, "metadata.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, metadataNoDefault+`    - name: another_image
+      varType: string
+      defaultValue: newer-image
+`, string(actual))
+}
+
+func TestOverwriteMetadataNoFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	_, err = OverwriteMetadata(&overwriteConfig{}, tmpDir)
+	assert.NoError(t, err)
+}
+
+func TestOverwriteMetadataRecursive(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"metadata.yaml":                      metadata,
+		"modules/db/metadata.yaml":           metadata,
+		"modules/web/frontend/metadata.yaml": metadata,
+		"modules/cli/main.tf":                mainTf,
+		".hidden/metadata.yaml":              metadata,
+	}
+	for file, content := range files {
+		err = os.MkdirAll(path.Dir(path.Join(tmpDir, file)), 0700)
+		assert.NoError(t, err)
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	config := &overwriteConfig{
+		Recursive: true,
+		Variables: []string{"source_image", "another_image"},
+		Replacements: map[string]string{
+			"old-image":   "new-image",
+			"older-image": "newer-image",
+		},
+	}
+	result, err := OverwriteMetadata(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Len(t, result.Files, 3)
+
+	actualFiles, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"metadata.yaml":                      metadataReplaced,
+		"modules/db/metadata.yaml":           metadataReplaced,
+		"modules/web/frontend/metadata.yaml": metadataReplaced,
+		"modules/cli/main.tf":                mainTf,
+		".hidden/metadata.yaml":              metadata,
+	}, actualFiles)
+
+	config.Variables = []string{"missing_variable"}
+	_, err = OverwriteMetadata(config, tmpDir)
+	assert.ErrorContains(t, err, "Missing valid default value for variable: missing_variable in metadata.yaml")
+}
+
+func TestOverwriteCustomMetadataFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"metadata.yaml":                 metadata,
+		"metadata.autogen.yaml":         metadata,
+		"metadata.display.autogen.yaml": metadataDisplayWithEnumsSingle,
+	}
+	for file, content := range files {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	config := &overwriteConfig{
+		MetadataFiles: []string{"metadata.autogen.yaml"},
+		DisplayFiles:  []string{"metadata.display.autogen.yaml"},
+		Variables:     []string{"source_image"},
+		Replacements: map[string]string{
+			"old-image": "new-image",
+			"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+		},
+	}
+	_, err = OverwriteMetadata(config, tmpDir)
+	assert.NoError(t, err)
+	_, err = OverwriteDisplay(config, tmpDir)
+	assert.NoError(t, err)
+
+	actualFiles, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, metadata, actualFiles["metadata.yaml"])
+	assert.Equal(t, strings.Replace(metadata, "old-image", "new-image", 1), actualFiles["metadata.autogen.yaml"])
+
+	actualDisplay := make(map[interface{}]interface{})
+	expectedDisplay := make(map[interface{}]interface{})
+	assert.NoError(t, yaml.Unmarshal([]byte(actualFiles["metadata.display.autogen.yaml"]), actualDisplay))
+	assert.NoError(t, yaml.Unmarshal([]byte(metadataDisplayWithEnumsSingleReplaced), expectedDisplay))
+	assert.Equal(t, expectedDisplay, actualDisplay)
+
+	config.Variables = []string{"missing_variable"}
+	_, err = OverwriteMetadata(config, tmpDir)
+	assert.ErrorContains(t, err, "Missing valid default value for variable: missing_variable in metadata.autogen.yaml")
+}
+
+func TestOverwriteDisplayDeterministic(t *testing.T) {
+	var outputs []string
+	for i := 0; i < 5; i++ {
+		tmpDir, err := os.MkdirTemp("", "tftest")
+		assert.NoError(t, err)
+		defer os.RemoveAll(tmpDir)
+
+		err = os.WriteFile(path.Join(tmpDir, "metadata.display.yaml"),
+			[]byte(metadataDisplayUnsorted), 0600)
+		assert.NoError(t, err)
+
+		_, err = OverwriteDisplay(&overwriteConfig{
+			NewValues: map[string]string{
+				"source_image":  "projects/replacement/global/images/wordpress-1-new",
+				"another_image": "projects/replacement/global/images/wordpress-3-new",
+			},
+			DisplayTitles: map[string]string{
+				"source_image":  "WordPress Image",
+				"another_image": "Another WordPress Image",
+			},
+		}, tmpDir)
+		assert.NoError(t, err)
+
+		b, err := os.ReadFile(path.Join(tmpDir, "metadata.display.yaml"))
+		assert.NoError(t, err)
+		outputs = append(outputs, string(b))
+	}
+
+	assert.Equal(t, metadataDisplayUnsortedReplaced, outputs[0])
+	for _, output := range outputs[1:] {
+		assert.Equal(t, outputs[0], output)
+	}
+}
+
+func TestOverwriteDisplayUnchanged(t *testing.T) {
+	// An already overwritten display file with a comment and unsorted keys
+	display := "# Generated by the release pipeline\n" + metadataDisplayWithEnumsSingleReplaced
+	testcases := []struct {
+		name            string
+		overwriteConfig overwriteConfig
+	}{{
+		name: "CheckOnly passes on already overwritten display file",
+		overwriteConfig: overwriteConfig{
+			CheckOnly: true,
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+			},
+		},
+	}, {
+		name: "DryRun with same new values proposes no changes",
+		overwriteConfig: overwriteConfig{
+			DryRun: true,
+			NewValues: map[string]string{
+				"source_image": "projects/replacement/global/images/wordpress-1-new",
+			},
+		},
+	}, {
+		name: "Same new values leave the file as is",
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "projects/replacement/global/images/wordpress-1-new",
+			},
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "metadata.display.yaml"), []byte(display), 0600)
+			assert.NoError(t, err)
+
+			result, err := OverwriteDisplay(&tc.overwriteConfig, tmpDir)
+			assert.NoError(t, err)
+			assert.Empty(t, result.ChangedFiles())
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"metadata.display.yaml": display}, actualContents)
+		})
+	}
+}
+
+func TestOverwriteDisplayNoFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	config := &overwriteConfig{
+		Variables: []string{"source_image"},
+		Replacements: map[string]string{
+			"old-image": "new-image",
+		},
+	}
+	result, err := OverwriteDisplay(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Empty(t, result.ChangedFiles())
+
+	_, err = os.Stat(path.Join(tmpDir, "metadata.display.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOverwiteMetadataPermissionError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "metadata.yaml"), []byte("file"), 0111)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{}, tmpDir)
+	assert.Error(t, err)
+	assert.True(t, os.IsPermission(err))
+}
+
+func getDirContents(dir string) (map[string]string, error) {
+	fileContents := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fileContents[path[len(dir)+1:]] = string(b)
+
+		return nil
+	})
+	return fileContents, err
+}
+
+func TestOverwriteDisplay(t *testing.T) {
+	testcases := []struct {
+		name                    string
+		originalMetadataDisplay string
+		expectedMetadataDisplay string
+		overwriteConfig         overwriteConfig
+		errorContains           string
+	}{
+		{
+			name:                    "Overwrite single display variable enum values",
+			originalMetadataDisplay: metadataDisplayWithEnumsSingle,
+			expectedMetadataDisplay: metadataDisplayWithEnumsSingleReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				},
+			},
+		},
+		{
+			name:                    "Overwrite multiple display variable enum values",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "another_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "Overwrite display variable enum values, order and fields of entries preserved",
+			originalMetadataDisplay: metadataDisplayWithEnumsOrdered,
+			expectedMetadataDisplay: metadataDisplayWithEnumsOrderedReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "Re-running overwrite on replaced enum values is a no-op",
+			originalMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "another_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "Overwrite display variable enum values with regex replacements",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "another_image"},
+				RegexReplacements: []RegexRule{{
+					Pattern:     `^projects/click-to-deploy-images/global/images/(wordpress-\d)$`,
+					Replacement: "projects/replacement/global/images/${1}-new",
+				}},
+			},
+		},
+		{
+			name:                    "ValidateImageURIs, overwrite display variable enum values with GCE image URIs",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				ValidateImageURIs: true,
+				Variables:         []string{"source_image", "another_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "ValidateImageURIs, fail when replaced enum value is not a GCE image URI",
+			originalMetadataDisplay: metadataDisplayWithEnumsSingle,
+			overwriteConfig: overwriteConfig{
+				ValidateImageURIs: true,
+				NewValues: map[string]string{
+					"source_image": "projects/replacement/images/wordpress-1-new",
+				},
+			},
+			errorContains: "enum value: projects/replacement/images/wordpress-1-new of variable: source_image in metadata.display.yaml is not a GCE image URI",
+		},
+		{
+			name:                    "Overwrite image values under xGoogleProperty",
+			originalMetadataDisplay: metadataDisplayWithDiskImageProperty,
+			expectedMetadataDisplay: metadataDisplayWithDiskImagePropertyReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "machine_type"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"e2-medium": "e2-large",
+				},
+			},
+		},
+		{
+			name:                    "CaseInsensitiveNames, overwrite display variables regardless of case",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				CaseInsensitiveNames: true,
+				Variables:            []string{"Source_Image", "ANOTHER_IMAGE"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "Overwrite display variables matching a pattern",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"*_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+			},
+		},
+		{
+			name:                    "Overwrite display variable enum values, labels and titles",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleRelabeled,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image", "another_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+					"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+					"projects/click-to-deploy-images/global/images/wordpress-3": "projects/replacement/global/images/wordpress-3-new",
+				},
+				DisplayTitles: map[string]string{
+					"source_image": "WordPress Image",
+				},
+				DisplayLabels: map[string]map[string]string{
+					"source_image": {
+						"wordpress-1": "WordPress 1 (Marketplace)",
+						"wordpress-2": "WordPress 2 (Marketplace)",
+					},
+				},
+			},
+		},
+		{
+			name:                    "Re-running overwrite on relabeled enum values is a no-op",
+			originalMetadataDisplay: metadataDisplayWithEnumsDoubleRelabeled,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoubleRelabeled,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayTitles: map[string]string{
+					"source_image": "WordPress Image",
+				},
+				DisplayLabels: map[string]map[string]string{
+					"source_image": {
+						"wordpress-1": "WordPress 1 (Marketplace)",
+						"wordpress-2": "WordPress 2 (Marketplace)",
+					},
+				},
+			},
+		},
+		{
+			name:                    "Fail when display enum label is not present",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayLabels: map[string]map[string]string{
+					"another_image": {
+						"wordpress-1": "WordPress 1 (Marketplace)",
+					},
+				},
+			},
+			errorContains: "enum label: wordpress-1 of variable: another_image not found in metadata.display.yaml",
+		},
+		{
+			name:                    "Overwrite display properties",
+			originalMetadataDisplay: metadataDisplayWithProperties,
+			expectedMetadataDisplay: metadataDisplayWithPropertiesReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayProperties: map[string]map[string]interface{}{
+					"admin_email": {
+						"required":  false,
+						"invisible": true,
+					},
+					"zone": {
+						"xGoogleProperty.type": "ET_GCE_REGION",
+						"maxItems":             float64(2),
+					},
+				},
+			},
+		},
+		{
+			name:                    "Fail when display property is not present",
+			originalMetadataDisplay: metadataDisplayWithProperties,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayProperties: map[string]map[string]interface{}{
+					"zone": {
+						"required": true,
+					},
+				},
+			},
+			errorContains: "display property: required of variable: zone not found in metadata.display.yaml",
+		},
+		{
+			name:                    "Fail when display property variable is not present",
+			originalMetadataDisplay: metadataDisplayWithProperties,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayProperties: map[string]map[string]interface{}{
+					"missing_variable": {
+						"required": true,
+					},
+				},
+			},
+			errorContains: "missing valid display info for variable: missing_variable",
+		},
+		{
+			name:                    "Fail when display property has a different type",
+			originalMetadataDisplay: metadataDisplayWithProperties,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayProperties: map[string]map[string]interface{}{
+					"admin_email": {
+						"required": "false",
+					},
+				},
+			},
+			errorContains: "new value: false of display property: required of variable: admin_email in metadata.display.yaml must have the type of its current value: true",
+		},
+		{
+			name:                    "Overwrite display numbers",
+			originalMetadataDisplay: metadataDisplayWithNumbers,
+			expectedMetadataDisplay: metadataDisplayWithNumbersReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayNumbers: map[string]map[string]float64{
+					"disk_size": {
+						"default": 50,
+						"max":     2000,
+					},
+					"replicas": {
+						"min": 1,
+						"max": 5,
+					},
+				},
+			},
+		},
+		{
+			name:                    "Fail when display number has a different type",
+			originalMetadataDisplay: metadataDisplayWithNumbers,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayNumbers: map[string]map[string]float64{
+					"zone": {
+						"default": 1,
+					},
+				},
+			},
+			errorContains: "display property: xGoogleProperty.default of variable: zone in metadata.display.yaml must be a number: \"us-central1-a\"",
+		},
+		{
+			name:                    "Fail when display number default is out of bounds",
+			originalMetadataDisplay: metadataDisplayWithNumbers,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayNumbers: map[string]map[string]float64{
+					"disk_size": {
+						"default": 5,
+					},
+				},
+			},
+			errorContains: "default: 5 of display variable: disk_size in metadata.display.yaml is not within min: 10 and max: 1000",
+		},
+		{
+			name:                    "Fail when display number min is greater than max",
+			originalMetadataDisplay: metadataDisplayWithNumbers,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayNumbers: map[string]map[string]float64{
+					"disk_size": {
+						"min": 2000,
+					},
+				},
+			},
+			errorContains: "min: 2000 of display variable: disk_size in metadata.display.yaml is greater than max: 1000",
+		},
+		{
+			name:                    "Fail when display number variable is not present",
+			originalMetadataDisplay: metadataDisplayWithNumbers,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayNumbers: map[string]map[string]float64{
+					"missing_variable": {
+						"default": 1,
+					},
+				},
+			},
+			errorContains: "missing xGoogleProperty of display variable: missing_variable in metadata.display.yaml",
+		},
+		{
+			name:                    "Fail when titled display variable is not present",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{},
+				DisplayTitles: map[string]string{
+					"missing_variable": "Missing",
+				},
+			},
+			errorContains: "missing valid display info for variable: missing_variable",
+		},
+		{
+			name:                    "No changes if no display variable enum value labels",
+			originalMetadataDisplay: metadataDisplayNoEnums,
+			expectedMetadataDisplay: metadataDisplayNoEnums,
+			overwriteConfig: overwriteConfig{
+				Variables:    []string{"source_image"},
+				Replacements: map[string]string{},
+			},
+		}, {
+			name:                    "Fail when metadata display is invalid yaml",
+			originalMetadataDisplay: "- not validyaml\ninvalid-",
+			errorContains:           "failure parsing metadata.display.yaml",
+		}, {
+			name:                    "Fail when display variable not present in Metadata display",
+			originalMetadataDisplay: metadata,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"missing_variable"},
+				Replacements: map[string]string{
+					"original-value": "new-value",
+				},
+			},
+			errorContains: "missing valid display info for variable: missing_variable",
+		}, {
+			name:                    "Fail when display variable enum value is not in replacements",
+			originalMetadataDisplay: metadataDisplayWithEnumsSingle,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image"},
+				Replacements: map[string]string{
+					"non-existent": "new-value",
+				},
+			},
+			errorContains: "enum value: projects/click-to-deploy-images/global/images/wordpress-1 of variable: source_image in metadata.display.yaml not found in replacements",
+		}, {
+			name:                    "Repoint the image project along with the enum values",
+			originalMetadataDisplay: metadataDisplayWithImageProject,
+			expectedMetadataDisplay: metadataDisplayWithImageProjectReplaced,
+			overwriteConfig: overwriteConfig{
+				Variables: []string{"source_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/mpi-partner/global/images/wordpress-1",
+				},
+				ImageProjects: map[string]string{
+					"click-to-deploy-images": "mpi-partner",
+				},
+			},
+		}, {
+			name:                    "Re-running the image project overwrite is a no-op",
+			originalMetadataDisplay: metadataDisplayWithImageProjectReplaced,
+			expectedMetadataDisplay: metadataDisplayWithImageProjectReplaced,
+			overwriteConfig: overwriteConfig{
+				ImageProjects: map[string]string{
+					"click-to-deploy-images": "mpi-partner",
+				},
+			},
+		}, {
+			name:                    "Fail when the enum values are not in the new image project",
+			originalMetadataDisplay: metadataDisplayWithImageProject,
+			overwriteConfig: overwriteConfig{
+				ImageProjects: map[string]string{
+					"click-to-deploy-images": "mpi-partner",
+				},
+			},
+			errorContains: "enum value: projects/click-to-deploy-images/global/images/wordpress-1 of variable: source_image in metadata.display.yaml is not in image project: mpi-partner",
+		}, {
+			name:                    "Fail when the image project is not in imageProjects",
+			originalMetadataDisplay: metadataDisplayWithImageProject,
+			overwriteConfig: overwriteConfig{
+				ImageProjects: map[string]string{
+					"other-project": "mpi-partner",
+				},
+			},
+			errorContains: "image project: click-to-deploy-images of variable: source_image in metadata.display.yaml not found in imageProjects",
+		}, {
+			name:                    "AllowPartialEnums, leave enum values without replacements unchanged",
+			originalMetadataDisplay: metadataDisplayWithEnumsDouble,
+			expectedMetadataDisplay: metadataDisplayWithEnumsDoublePartiallyReplaced,
+			overwriteConfig: overwriteConfig{
+				AllowPartialEnums: true,
+				Variables:         []string{"source_image", "another_image"},
+				Replacements: map[string]string{
+					"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				},
+			},
+		}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			err = os.WriteFile(path.Join(tmpDir, "metadata.display.yaml"),
+				[]byte(tc.originalMetadataDisplay), 0600)
+			assert.NoError(t, err)
+
+			_, err = OverwriteDisplay(&tc.overwriteConfig, tmpDir)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+
+				metadataDisplayBytes, err := os.ReadFile(path.Join(tmpDir, "metadata.display.yaml"))
+				assert.NoError(t, err)
+				actualMetadataDisplay := make(map[interface{}]interface{})
+				expectedMetadataDisplay := make(map[interface{}]interface{})
+
+				err = yaml.Unmarshal(metadataDisplayBytes, &actualMetadataDisplay)
+				assert.NoError(t, err)
+
+				err = yaml.Unmarshal([]byte(tc.expectedMetadataDisplay), expectedMetadataDisplay)
+				assert.NoError(t, err)
+
+				assert.Equal(t, expectedMetadataDisplay, actualMetadataDisplay)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestOverwriteAll(t *testing.T) {
+	config := overwriteConfig{
+		Variables: []string{"value_to_replace", "other_value_to_replace"},
+		Replacements: map[string]string{
+			"original-value": "new-value",
+			"old-value":      "newer-value",
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		files         map[string]string
+		expectedFiles map[string]string
+		errorContains string
+	}{{
+		name: "Overwrite module without metadata files",
+		files: map[string]string{
+			"main.tf": mainTf,
+		},
+		expectedFiles: map[string]string{
+			"main.tf": mainTfReplaced,
+		},
+	}, {
+		name: "Fail identifies the tf stage",
+		files: map[string]string{
+			"main.tf": otherTf,
+		},
+		errorContains: "failure overwriting tf files: variable: value_to_replace not found in module",
+	}, {
+		name: "Fail identifies the metadata stage",
+		files: map[string]string{
+			"main.tf":       mainTf,
+			"metadata.yaml": metadata,
+		},
+		errorContains: "failure overwriting metadata.yaml: Missing valid default value for variable: value_to_replace",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteAll(&config, tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedFiles, actualContents)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestOverwriteAllProjectRemap(t *testing.T) {
+	files := map[string]string{
+		"main.tf": `
+variable "source_image" {
+  type    = string
+  default = "projects/click-to-deploy-images/global/images/wordpress-1"
+}
+
+variable "boot_image" {
+  type    = string
+  default = "https://www.googleapis.com/compute/v1/projects/click-to-deploy-images/global/images/family/debian"
+}
+`,
+		"metadata.yaml": `spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: projects/click-to-deploy-images/global/images/wordpress-1
+    - name: boot_image
+      varType: string
+      defaultValue: projects/other-project/global/images/family/debian
+`,
+		"metadata.display.yaml": `spec:
+  ui:
+    input:
+      variables:
+        boot_image:
+          name: boot_image
+          title: Boot Image
+        source_image:
+          enumValueLabels:
+          - label: wordpress-1
+            value: projects/click-to-deploy-images/global/images/wordpress-1
+          name: source_image
+          title: Source Image
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`,
+	}
+	expectedFiles := map[string]string{
+		"main.tf": `
+variable "source_image" {
+  type    = string
+  default = "projects/new-project/global/images/wordpress-1"
+}
+
+variable "boot_image" {
+  type    = string
+  default = "https://www.googleapis.com/compute/v1/projects/new-project/global/images/family/debian"
+}
+`,
+		"metadata.yaml": `spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: projects/new-project/global/images/wordpress-1
+    - name: boot_image
+      varType: string
+      defaultValue: projects/new-project/global/images/family/debian
+`,
+		"metadata.display.yaml": `spec:
+  ui:
+    input:
+      variables:
+        boot_image:
+          name: boot_image
+          title: Boot Image
+        source_image:
+          enumValueLabels:
+          - label: wordpress-1
+            value: projects/new-project/global/images/wordpress-1
+          name: source_image
+          title: Source Image
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`,
+	}
+
+	for _, run := range []string{"Overwrite", "Re-running overwrite is a no-op"} {
+		t.Run(run, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			originalFiles := files
+			if run != "Overwrite" {
+				originalFiles = expectedFiles
+			}
+			for file, content := range originalFiles {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteAll(&overwriteConfig{
+				Variables: []string{"source_image", "boot_image"},
+				ProjectRemap: map[string]string{
+					"click-to-deploy-images": "new-project",
+					"other-project":          "new-project",
+				},
+			}, tmpDir)
+			assert.NoError(t, err)
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedFiles, actualContents)
+		})
+	}
+}
+
+func TestOverwriteAllValidateEnums(t *testing.T) {
+	tfFile := `
+variable "source_image" {
+  type    = string
+  default = "projects/click-to-deploy-images/global/images/wordpress-1"
+}
+`
+	metadataFile := `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: projects/click-to-deploy-images/global/images/%s
+`
+
+	testcases := []struct {
+		name            string
+		metadataImage   string
+		extraFiles      map[string]string
+		overwriteConfig overwriteConfig
+		errorContains   string
+	}{{
+		name:          "Default value matches replaced enum values",
+		metadataImage: "wordpress-1",
+		overwriteConfig: overwriteConfig{
+			ValidateEnums: true,
+			Variables:     []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+			},
+		},
+	}, {
+		name:          "Fail when default value is not one of the enum values",
+		metadataImage: "wordpress-2",
+		overwriteConfig: overwriteConfig{
+			ValidateEnums: true,
+			Variables:     []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+			},
+		},
+		errorContains: "default value: projects/replacement/global/images/wordpress-2-new of variable: source_image" +
+			" in metadata.yaml is not one of the enum values in metadata.display.yaml",
+	}, {
+		name:          "Fail when default value in another metadata file is not one of the enum values",
+		metadataImage: "wordpress-1",
+		extraFiles: map[string]string{
+			"metadata.autogen.yaml": fmt.Sprintf(metadataFile, "wordpress-2"),
+		},
+		overwriteConfig: overwriteConfig{
+			ValidateEnums: true,
+			MetadataFiles: []string{"metadata.yaml", "metadata.autogen.yaml"},
+			Variables:     []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+			},
+		},
+		errorContains: "default value: projects/replacement/global/images/wordpress-2-new of variable: source_image" +
+			" in metadata.autogen.yaml is not one of the enum values in metadata.display.yaml",
+	}, {
+		name:          "With Recursive, fail when default value in a subdirectory is not one of the enum values",
+		metadataImage: "wordpress-1",
+		extraFiles: map[string]string{
+			"modules/wordpress/metadata.yaml":         fmt.Sprintf(metadataFile, "wordpress-2"),
+			"modules/wordpress/metadata.display.yaml": metadataDisplayWithEnumsSingle,
+		},
+		overwriteConfig: overwriteConfig{
+			ValidateEnums: true,
+			Recursive:     true,
+			Variables:     []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				"projects/click-to-deploy-images/global/images/wordpress-2": "projects/replacement/global/images/wordpress-2-new",
+			},
+		},
+		errorContains: "default value: projects/replacement/global/images/wordpress-2-new of variable: source_image" +
+			" in modules/wordpress/metadata.yaml is not one of the enum values in modules/wordpress/metadata.display.yaml",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			originalFiles := map[string]string{
+				"main.tf":               tfFile,
+				"metadata.yaml":         fmt.Sprintf(metadataFile, tc.metadataImage),
+				"metadata.display.yaml": metadataDisplayWithEnumsSingle,
+			}
+			for file, content := range tc.extraFiles {
+				originalFiles[file] = content
+			}
+			for file, content := range originalFiles {
+				err = os.MkdirAll(path.Dir(path.Join(tmpDir, file)), 0700)
+				assert.NoError(t, err)
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			_, err = OverwriteAll(&tc.overwriteConfig, tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+
+				// Files are not written when validation fails
+				actualContents, err := getDirContents(tmpDir)
+				assert.NoError(t, err)
+				assert.Equal(t, originalFiles, actualContents)
+			}
+		})
+	}
+}
+
+func TestOverwriteTfManyFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	const fileCount = 100
+	config := &overwriteConfig{
+		Variables: []string{"image_*"},
+		Replacements: map[string]string{
+			"old-image": "new-image",
+		},
+	}
+	expectedFiles := map[string]string{}
+	var expectedChanges []VariableChange
+	for i := 0; i < fileCount; i++ {
+		filename := fmt.Sprintf("image_%03d.tf", i)
+		tf := fmt.Sprintf("variable \"image_%03d\" {\n  type    = string\n  default = \"old-image\"\n}\n", i)
+		err = os.WriteFile(path.Join(tmpDir, filename), []byte(tf), 0600)
+		assert.NoError(t, err)
+
+		expectedFiles[filename] = strings.Replace(tf, "old-image", "new-image", 1)
+		expectedChanges = append(expectedChanges, VariableChange{
+			File:       path.Join(tmpDir, filename),
+			Variable:   fmt.Sprintf("image_%03d", i),
+			OldDefault: "old-image",
+			NewDefault: "new-image",
+		})
+	}
+
+	report, err := OverwriteTfWithReport(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedChanges, report.Changes)
+
+	actualFiles, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedFiles, actualFiles)
+}
+
+func BenchmarkOverwriteTf(b *testing.B) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(b, err)
+	defer os.RemoveAll(tmpDir)
+
+	for i := 0; i < 200; i++ {
+		tf := fmt.Sprintf("variable \"image_%03d\" {\n  type    = string\n  default = \"old-image\"\n}\n", i)
+		err = os.WriteFile(path.Join(tmpDir, fmt.Sprintf("image_%03d.tf", i)), []byte(tf), 0600)
+		assert.NoError(b, err)
+	}
+	config := &overwriteConfig{
+		DryRun:    true,
+		Variables: []string{"image_*"},
+		Replacements: map[string]string{
+			"old-image": "new-image",
+		},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = OverwriteTf(config, tmpDir)
+		assert.NoError(b, err)
+	}
+}
+
+func TestOverwriteContextCanceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":               mainTf,
+		"metadata.yaml":         metadata,
+		"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := &overwriteConfig{
+		NewValues: map[string]string{
+			"value_to_replace": "new-value",
+		},
+	}
+	_, err = OverwriteTfContext(ctx, config, tmpDir)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	config = &overwriteConfig{
+		NewValues: map[string]string{
+			"source_image": "new-image",
+		},
+	}
+	_, err = OverwriteMetadataContext(ctx, config, tmpDir)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = OverwriteDisplayContext(ctx, config, tmpDir)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = OverwriteAllContext(ctx, config, tmpDir)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, originalFiles, actualContents)
+}
+
+func TestOverwriteDryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":               mainTf,
+		"metadata.yaml":         metadata,
+		"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	config := &overwriteConfig{
+		DryRun: true,
+		NewValues: map[string]string{
+			"value_to_replace":       "new-value",
+			"other_value_to_replace": "newer-value",
+		},
+	}
+	result, err := OverwriteTf(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(tmpDir, "main.tf")}, result.ChangedFiles())
+	assert.Equal(t, mainTf, string(result.Files[path.Join(tmpDir, "main.tf")].Original))
+	assert.Equal(t, mainTfReplaced, string(result.Files[path.Join(tmpDir, "main.tf")].Proposed))
+
+	config = &overwriteConfig{
+		DryRun: true,
+		NewValues: map[string]string{
+			"source_image":  "new-image",
+			"another_image": "newer-image",
+		},
+	}
+	result, err = OverwriteMetadata(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Files[path.Join(tmpDir, "metadata.yaml")].Proposed), "defaultValue: new-image")
+
+	result, err = OverwriteDisplay(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Files[path.Join(tmpDir, "metadata.display.yaml")].Proposed), "value: new-image")
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, originalFiles, actualContents)
+}
+
+func TestOverwriteCheckOnly(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":       mainTf,
+		"metadata.yaml": metadataReplaced,
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	config := &overwriteConfig{
+		CheckOnly: true,
+		NewValues: map[string]string{
+			"value_to_replace":       "new-value",
+			"other_value_to_replace": "newer-value",
+		},
+	}
+	_, err = OverwriteTf(config, tmpDir)
+	assert.ErrorIs(t, err, ErrWouldChange)
+	assert.ErrorContains(t, err, path.Join(tmpDir, "main.tf"))
+
+	config = &overwriteConfig{
+		CheckOnly: true,
+		NewValues: map[string]string{
+			"source_image":  "new-image",
+			"another_image": "newer-image",
+		},
+	}
+	result, err := OverwriteMetadata(config, tmpDir)
+	assert.NoError(t, err)
+	assert.Empty(t, result.ChangedFiles())
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, originalFiles, actualContents)
+}
+
+func TestCommitRollback(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	mainFilename := path.Join(tmpDir, "main.tf")
+	err = os.WriteFile(mainFilename, []byte(mainTf), 0600)
+	assert.NoError(t, err)
+	addedFilename := path.Join(tmpDir, "added.tf")
+
+	// Renaming over a non-empty directory fails after main.tf has been written
+	dirFilename := path.Join(tmpDir, "z.yaml")
+	err = os.MkdirAll(path.Join(dirFilename, "nested"), 0700)
+	assert.NoError(t, err)
+
+	result := newOverwriteResult()
+	result.stageFile(mainFilename, []byte(mainTf), []byte(mainTfReplaced))
+	result.stageFile(addedFilename, nil, []byte(otherTf))
+	result.stageFile(dirFilename, nil, []byte("key: value\n"))
+
+	err = result.commit(&overwriteConfig{}, tmpDir)
+	assert.Error(t, err)
+
+	actualContents, err := os.ReadFile(mainFilename)
+	assert.NoError(t, err)
+	assert.Equal(t, mainTf, string(actualContents))
+	assert.NoFileExists(t, addedFilename)
+}
+
+func TestCommitInvalidContents(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":       mainTf,
+		"metadata.yaml": metadata,
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	result := newOverwriteResult()
+	result.stageFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), []byte(mainTfReplaced))
+	result.stageFile(path.Join(tmpDir, "metadata.yaml"), []byte(metadata), []byte("spec: ["))
+
+	err = result.commit(&overwriteConfig{}, tmpDir)
+	assert.ErrorContains(t, err, "failure validating proposed contents of")
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, originalFiles, actualContents)
+}
+
+func TestOverwriteBackup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	originalFiles := map[string]string{
+		"main.tf":        mainTf,
+		"anyfilename.tf": otherTf,
+		"metadata.yaml":  metadata,
+	}
+	for file, content := range originalFiles {
+		err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+		assert.NoError(t, err)
+	}
+
+	_, err = OverwriteTf(&overwriteConfig{
+		Backup:    true,
+		Variables: []string{"value_to_replace", "other_value_to_replace"},
+		Replacements: map[string]string{
+			"original-value": "new-value",
+			"old-value":      "newer-value",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	_, err = OverwriteMetadata(&overwriteConfig{
+		Backup: true,
+		NewValues: map[string]string{
+			"source_image": "new-image",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	actualContents, err := getDirContents(tmpDir)
+	assert.NoError(t, err)
+	assert.Equal(t, mainTfReplaced, actualContents["main.tf"])
+	assert.Equal(t, mainTf, actualContents["main.tf.orig"])
+	assert.Equal(t, metadata, actualContents["metadata.yaml.orig"])
+
+	// Unchanged files are not backed up
+	assert.NotContains(t, actualContents, "anyfilename.tf.orig")
+}
+
+var mainTf string = `
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "original-value"
+}
+
+variable "other_value_to_replace" {
+  type    = string
+  default = "old-value"
+}
+`
+
+var mainTfReplaced string = `
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "new-value"
+}
+
+variable "other_value_to_replace" {
+  type    = string
+  default = "newer-value"
+}
+`
+
+var mainTfNoLabel string = `
+provider "google" {
+  project = var.project_id
+}
+
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "original-value"
+}
+`
+
+var mainTfProvidedLabel string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "original-value"
+}
+`
+
+var mainTfLabelUpserted string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+
+resource "google_compute_instance_template" "template" {
+  name = "template"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "new-value"
+}
+`
+
+var otherTf string = `
+variable "another_variable" {
+  type = string
+  default = "oldest-value"
+}
+`
+
+var otherTfReplaced string = `
+variable "another_variable" {
+  type = string
+  default = "newest-value"
+}
+`
+
+var tfWithComments string = `
+# The image used by the VM
+variable "value_to_replace" {
+  type = string # must be a string
+  default =  "original-value" # replaced at release
+}
+
+variable "untouched" {
+  type       = string
+  default    = "untouched-value"   // aligned on purpose
+}
+`
+
+var tfWithCommentsReplaced string = `
+# The image used by the VM
+variable "value_to_replace" {
+  type = string # must be a string
+  default =  "new-value" # replaced at release
+}
+
+variable "untouched" {
+  type       = string
+  default    = "untouched-value"   // aligned on purpose
+}
+`
+
+var tfLocals string = `
+locals {
+  source_image = "old-image"
+  image_path   = "projects/${var.project}/global/images/image"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "original-value"
+}
+`
+
+var tfLocalsReplaced string = `
+locals {
+  source_image = "new-image"
+  image_path   = "projects/${var.project}/global/images/image"
+}
+
+variable "value_to_replace" {
+  type    = string
+  default = "new-value"
+}
+`
+
+var tfProviderAppended string = `
+variable "value_to_replace" {
+  type    = string
+  default = "new-value"
+}
+
+provider "google" {
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+`
+
+var tfProvider string = `
+provider "google" {
+  project = var.project_id
+}
+`
+
+var tfProviderLabelUpserted string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+`
+
+var tfProviderMultipleLabels string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    team = "db"
+  }
+}
+
+provider "google" {
+  alias          = "beta"
+  default_labels = { goog-partner-solution = "existing-consumer-label" }
+}
+
+provider "google" {
+  alias = "other"
+}
+`
+
+var tfRequiredProviders string = `
+terraform {
+  required_version = ">= 1.3"
+
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = "~> 4.0" # pinned at release
+    }
+    google-beta = { source = "hashicorp/google-beta", version = "~> 4.0" }
+    random      = "~> 3.0"
+  }
+}
+`
+
+var tfRequiredProvidersReplaced string = `
+terraform {
+  required_version = ">= 1.3"
+
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = ">= 5.10, < 6" # pinned at release
+    }
+    google-beta = { source = "hashicorp/google-beta", version = ">= 5.10, < 6" }
+    random      = "3.6.0"
+  }
+}
+`
+
+var tfModules string = `
+module "db" {
+  source = "../modules/db" # local development
+
+  name       = "db"
+  db_version = var.db_version
+}
+
+module "web" {
+  source   = "../modules/web"
+  db_name  = module.db.name
+  replicas = 2
+}
+`
+
+var tfModulesReplaced string = `
+module "db" {
+  source = "GoogleCloudPlatform/db/google" # local development
+
+  name       = "db"
+  db_version = var.db_version
+}
+
+module "web" {
+  source   = "GoogleCloudPlatform/web/google//modules/frontend"
+  db_name  = module.db.name
+  replicas = 2
+}
+`
+
+var tfProviderExistingLabels string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    team                  = "db"
+    goog-partner-solution = "old-consumer-label" # attribution
+    env                   = "prod"
+  }
+}
+
+provider "google" {
+  alias          = "beta"
+  default_labels = { goog-partner-solution = "old-consumer-label", team = "db" }
+}
+
+provider "google" {
+  alias = "block"
+  default_labels {
+    goog-partner-solution = "old-consumer-label"
+    team                  = "db"
+  }
+}
+`
+
+var tfProviderLabelsBlock string = `
+provider "google" {
+  project = var.project_id
+  default_labels {
+    team = "db"
+  }
+}
+`
+
+var tfProviderLabelsBlockUpserted string = `
+provider "google" {
+  project = var.project_id
+  default_labels {
+    team                  = "db"
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+`
+
+var tfResourceImages string = `
+resource "google_compute_instance_template" "template" {
+  name = "template"
+
+  disk {
+    source_image = "projects/old-project/global/images/old-image"
+    boot         = true
+  }
+
+  disk {
+    source_image = var.data_image
+  }
+}
+
+resource "google_compute_instance" "vm" {
+  boot_disk {
+    initialize_params {
+      image = "old-boot-image" # boot image
+    }
+  }
+}
+
+resource "google_compute_disk" "disk" {
+  image = "old-boot-image"
+}
+`
+
+var tfResourceImagesReplaced string = `
+resource "google_compute_instance_template" "template" {
+  name = "template"
+
+  disk {
+    source_image = "projects/new-project/global/images/new-image"
+    boot         = true
+  }
+
+  disk {
+    source_image = var.data_image
+  }
+}
+
+resource "google_compute_instance" "vm" {
+  boot_disk {
+    initialize_params {
+      image = "new-boot-image" # boot image
+    }
+  }
+}
+
+resource "google_compute_disk" "disk" {
+  image = "old-boot-image"
+}
+`
+
+var tfProviderMultipleLabelsUpserted string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    team                  = "db"
+    goog-partner-solution = "new-consumer-label"
+    goog-tracking         = "new-tracking-label"
+  }
+}
+
+provider "google" {
+  alias          = "beta"
+  default_labels = { goog-partner-solution = "existing-consumer-label", goog-tracking = "new-tracking-label", team = "new-team" }
+}
+
+provider "google" {
+  alias = "other"
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+    goog-tracking         = "new-tracking-label"
+    team                  = "new-team"
+  }
+}
+`
+
+var tfProviderAliases string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "existing-consumer-label"
+  }
+}
+
+provider "google" {
+  alias   = "beta"
+  project = var.project_id
+}
+
+provider "google" {
+  alias   = "other"
+  project = var.project_id
+}
+`
+
+var tfProviderAliasesLabelUpserted string = `
+provider "google" {
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "existing-consumer-label"
+  }
+}
+
+provider "google" {
+  alias   = "beta"
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+
+provider "google" {
+  alias   = "other"
+  project = var.project_id
+  default_labels = {
+    goog-partner-solution = "new-consumer-label"
+  }
+}
+`
+
+var tfHeredoc string = `
+variable "startup_script" {
+  type    = string
+  default = <<-EOT
+    gcloud compute images describe old-image
+      --project old-project
+    EOT
+}
+
+variable "plain_script" {
+  type    = string
+  default = <<EOF
+echo old-image
+EOF
+}
+`
+
+var tfHeredocReplaced string = `
+variable "startup_script" {
+  type    = string
+  default = <<-EOT
+    gcloud compute images describe new-image
+      --project $${project}
+    EOT
+}
+
+variable "plain_script" {
+  type    = string
+  default = <<EOF
+echo new-image
+echo done
+EOF
+}
+`
+
+var tfDigests string = `
+variable "app_image" {
+  type    = string
+  default = "gcr.io/proj/app:1.2.3@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+}
+
+variable "registry_image" {
+  type    = string
+  default = "localhost:5000/proj/sidecar@sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+}
+
+variable "tagged_image" {
+  type    = string
+  default = "gcr.io/proj/app:1.2.3"
+}
+`
+
+var tfDigestsReplaced string = `
+variable "app_image" {
+  type    = string
+  default = "gcr.io/proj/app:1.2.3@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+}
+
+variable "registry_image" {
+  type    = string
+  default = "localhost:5000/proj/sidecar@sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+}
+
+variable "tagged_image" {
+  type    = string
+  default = "gcr.io/proj/app:1.2.3"
+}
+`
+
+var tfObjects string = `
+variable "infra" {
+  type = object({
+    image   = string
+    size    = number
+    sidecar = object({ image = string })
+  })
+  default = {
+    image   = "old-image" # the main image
+    size    = 10
+    sidecar = { "image" = "old-sidecar" }
+  }
+}
+
+variable "images_by_zone" {
+  type    = map(string)
+  default = { east1 = "old-east-image", west1 = "old-west-image" }
+}
+`
+
+var tfObjectsReplaced string = `
+variable "infra" {
+  type = object({
+    image   = string
+    size    = number
+    sidecar = object({ image = string })
+  })
+  default = {
+    image   = "new-image" # the main image
+    size    = 10
+    sidecar = { "image" = "new-sidecar" }
+  }
+}
+
+variable "images_by_zone" {
+  type    = map(string)
+  default = { east1 = "new-east-image", west1 = "old-west-image" }
+}
+`
+
+var tfCollections string = `
+variable "images" {
+  type    = list(string)
+  default = ["old-image-a", "old-image-b", "other-image"]
+}
+
+variable "images_by_zone" {
+  type = map(string)
+  default = {
+    "us-east1-b"   = "old-image-a"
+    us-central1-a  = "old-image-b"
+  }
+}
+`
+
+var tfCollectionsReplaced string = `
+variable "images" {
+  type    = list(string)
+  default = ["new-image-a", "new-image-b", "other-image"]
+}
+
+variable "images_by_zone" {
+  type = map(string)
+  default = {
+    "us-east1-b"   = "new-image-a"
+    us-central1-a  = "new-image-b"
+  }
+}
+`
+
+var tfJSON string = `{
+  "variable": {
+    "another_variable": {
+      "type": "string",
+      "default": "oldest-value"
+    }
+  }
+}
+`
+
+var tfJSONReplaced string = `{
+  "variable": {
+    "another_variable": {
+      "type": "string",
+      "default": "newest-value"
+    }
+  }
+}
+`
+
+var tfJSONCollections string = `{
+  "variable": {
+    "images": {
+      "type": "list(string)",
+      "default": ["old-image-a", "other-image"]
+    }
+  }
+}
+`
+
+var tfJSONCollectionsReplaced string = `{
+  "variable": {
+    "images": {
+      "type": "list(string)",
+      "default": ["new-image-a", "other-image"]
+    }
+  }
+}
+`
+
+var tfJSONArrayForm string = `{
+  "variable": [
+    {
+      "another_variable": {
+        "type": "string",
+        "default": "oldest-value"
+      }
+    },
+    {
+      "images": [
+        {
+          "type": "list(string)",
+          "default": ["old-image-a", "other-image"]
+        }
+      ]
+    }
+  ]
+}
+`
+
+var tfJSONArrayFormReplaced string = `{
+  "variable": [
+    {
+      "another_variable": {
+        "type": "string",
+        "default": "newest-value"
+      }
+    },
+    {
+      "images": [
+        {
+          "type": "list(string)",
+          "default": ["new-image-a", "other-image"]
+        }
+      ]
+    }
+  ]
+}
+`
+
+var tfJSONProvider string = `{
+  "provider": {
+    "google": {
+      "project": "my-project"
+    }
+  }
+}
+`
+
+var tfTyped string = `
+variable "web_image" {
+  type    = string
+  default = "old-image"
+}
+
+variable "zone" {
+  type    = string
+  default = "us-central1-a"
+}
 
-	for _, tc := range testcases {
-		t.Run(tc.name, func(t *testing.T) {
-			tmpDir, err := os.MkdirTemp("", "tftest")
-			assert.NoError(t, err)
-			defer os.RemoveAll(tmpDir)
+variable "images" {
+  type    = list(string)
+  default = ["old-image-a", "other-image"]
+}
 
-			err = os.WriteFile(path.Join(tmpDir, "metadata.display.yaml"),
-				[]byte(tc.originalMetadataDisplay), 0600)
-			assert.NoError(t, err)
+variable "untyped_image" {
+  default = "old-image"
+}
 
-			err = OverwriteDisplay(&tc.overwriteConfig, tmpDir)
+variable "secret_image" {
+  type      = string
+  sensitive = true
+  default   = "old-image"
+}
+`
 
-			if tc.errorContains == "" {
-				assert.NoError(t, err)
+var tfTypedReplaced string = `
+variable "web_image" {
+  type    = string
+  default = "new-image"
+}
 
-				metadataDisplayBytes, err := os.ReadFile(path.Join(tmpDir, "metadata.display.yaml"))
-				assert.NoError(t, err)
-				actualMetadataDisplay := make(map[interface{}]interface{})
-				expectedMetadataDisplay := make(map[interface{}]interface{})
+variable "zone" {
+  type    = string
+  default = "us-central1-a"
+}
 
-				err = yaml.Unmarshal(metadataDisplayBytes, &actualMetadataDisplay)
-				assert.NoError(t, err)
+variable "images" {
+  type    = list(string)
+  default = ["new-image-a", "other-image"]
+}
 
-				err = yaml.Unmarshal([]byte(tc.expectedMetadataDisplay), expectedMetadataDisplay)
-				assert.NoError(t, err)
+variable "untyped_image" {
+  default = "old-image"
+}
 
-				assert.Equal(t, expectedMetadataDisplay, actualMetadataDisplay)
-			} else {
-				assert.Error(t, err)
-				assert.ErrorContains(t, err, tc.errorContains)
-			}
-		})
-	}
+variable "secret_image" {
+  type      = string
+  sensitive = true
+  default   = "old-image"
 }
+`
 
-var mainTf string = `
-resource "google_compute_instance_template" "template" {
-  name = "template"
+var tfSensitive string = `
+variable "db_password" {
+  type      = string
+  sensitive = true
+  default   = "old-password"
 }
+`
 
-variable "value_to_replace" {
+var tfSensitiveReplaced string = `
+variable "db_password" {
+  type      = string
+  sensitive = true
+  default   = "new-password"
+}
+`
+
+var tfValidation string = `
+variable "source_image" {
   type    = string
-  default = "original-value"
+  default = "projects/partner/global/images/old-image"
+
+  # Only GCE images can be used
+  validation {
+    condition     = can(regex("^projects/[^/]+/global/images/", var.source_image))
+    error_message = "The source image must be a GCE image URI."
+  }
 }
+`
 
-variable "other_value_to_replace" {
+var tfValidationReplaced string = `
+variable "source_image" {
   type    = string
-  default = "old-value"
+  default = "projects/mpi/global/images/new-image"
+
+  # Only GCE images can be used
+  validation {
+    condition     = can(regex("^projects/[^/]+/global/images/", var.source_image))
+    error_message = "The source image must be a GCE image URI."
+  }
 }
 `
 
-var mainTfReplaced string = `
-resource "google_compute_instance_template" "template" {
-  name = "template"
+var tfValidationInvalidReplaced string = `
+variable "source_image" {
+  type    = string
+  default = "new-image"
+
+  # Only GCE images can be used
+  validation {
+    condition     = can(regex("^projects/[^/]+/global/images/", var.source_image))
+    error_message = "The source image must be a GCE image URI."
+  }
 }
+`
 
-variable "value_to_replace" {
+var tfConditional string = `
+variable "use_beta" {
+  type    = bool
+  default = false
+}
+
+variable "source_image" {
   type    = string
-  default = "new-value"
+  default = var.use_beta ? "projects/partner/global/images/beta-image" : "projects/partner/global/images/stable-image"
 }
 
-variable "other_value_to_replace" {
+variable "machine_type" {
   type    = string
-  default = "newer-value"
+  default = "e2-medium"
 }
 `
 
-var mainTfNoLabel string = `
-provider "google" {
-  project = var.project_id
+var tfConditionalReplaced string = `
+variable "use_beta" {
+  type    = bool
+  default = false
 }
 
-resource "google_compute_instance_template" "template" {
-  name = "template"
+variable "source_image" {
+  type    = string
+  default = var.use_beta ? "projects/mpi/global/images/beta-image" : "projects/mpi/global/images/stable-image"
 }
 
-variable "value_to_replace" {
+variable "machine_type" {
   type    = string
-  default = "original-value"
+  default = "e2-medium"
 }
 `
 
-var mainTfProvidedLabel string = `
-provider "google" {
-  project = var.project_id
-  default_labels = {
-    goog-partner-solution = "new-consumer-label"
-  }
+var tfConditionalOtherReplaced string = `
+variable "use_beta" {
+  type    = bool
+  default = false
 }
 
-resource "google_compute_instance_template" "template" {
-  name = "template"
+variable "source_image" {
+  type    = string
+  default = var.use_beta ? "projects/partner/global/images/beta-image" : "projects/partner/global/images/stable-image"
 }
 
-variable "value_to_replace" {
+variable "machine_type" {
   type    = string
-  default = "original-value"
+  default = "e2-standard-2"
 }
 `
 
-var mainTfLabelUpserted string = `
-provider "google" {
-  project = var.project_id
-  default_labels = {
-    goog-partner-solution = "new-consumer-label"
+var tfJSONObjects string = `{
+  "variable": {
+    "infra": {
+      "default": {"image": "old-image", "size": 10}
+    }
   }
 }
+`
 
-resource "google_compute_instance_template" "template" {
-  name = "template"
-}
-
-variable "value_to_replace" {
-  type    = string
-  default = "new-value"
+var tfJSONObjectsReplaced string = `{
+  "variable": {
+    "infra": {
+      "default": {"image": "new-image", "size": 10}
+    }
+  }
 }
 `
 
-var otherTf string = `
-variable "another_variable" {
+var tfNoDefault string = `
+variable "value_to_replace" {
   type = string
-  default = "oldest-value"
 }
 `
 
-var otherTfReplaced string = `
-variable "another_variable" {
+var tfDefaultAdded string = `
+variable "value_to_replace" {
   type    = string
-  default = "newest-value"
+  default = "new-value"
 }
 `
 
-var tfNoDefault string = `
+var tfDefaultWrongType string = `
 variable "value_to_replace" {
-  type = string
+  type = map(number)
+  default = {
+    foo = 2
+    bar = 4
+  }
+}
+`
+var tfNoDefaultWrongType string = `
+variable "value_to_replace" {
+  type = map(number)
 }
 `
 
-var tfDefaultAdded string = `
-variable "value_to_replace" {
-  type    = string
-  default = "new-value"
-}
+var metadata string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: old-image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: older-image
+`
+
+var metadataEmptyDefault string = `spec:
+  interfaces:
+    variables:
+      - name: source_image
+        defaultValue:
+        varType: string
+`
+
+var metadataEmptyDefaultReplaced string = `spec:
+  interfaces:
+    variables:
+      - name: source_image
+        defaultValue: new-image
+        varType: string
+`
+
+var metadataWithAPIVersion string = `apiVersion: blueprints.cloud.google.com/v1alpha1 # Schema version
+kind: BlueprintMetadata
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: old-image
+`
+
+var metadataWithAPIVersionReplaced string = `apiVersion: blueprints.cloud.google.com/v1 # Schema version
+kind: BlueprintMetadata
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: new-image
+`
+
+var metadataReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: new-image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: newer-image
+`
+
+var metadataTemplated string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: ${var.project}/image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: older-image
+`
+
+var metadataTemplatedSkipped string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: ${var.project}/image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: newer-image
+`
+
+var metadataTemplatedReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: projects/mpi/global/images/image
+    - name: another_image
+      description: The image name for the disk for the VM instance.
+      varType: string
+      defaultValue: newer-image
+`
+
+var metadataWithDeployer string = `
+spec:
+  deployerSpec:
+    image: gcr.io/partner/deployer:1.0 # Deployer image
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: old-image
+`
+
+var metadataWithDeployerReplaced string = `
+spec:
+  deployerSpec:
+    image: gcr.io/mpi/deployer:1.0 # Deployer image
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: new-image
+`
+
+var metadataWithOutputs string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: old-image
+    outputs:
+    # The URL of the VM
+    - name: instance_url
+      description: URL of the VM instance
+      example: https://old.example.com
+    - name: admin_user
+      description: Admin user
+      example: admin # Default user
+`
+
+var metadataWithOutputsReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: new-image
+    outputs:
+    # The URL of the VM
+    - name: instance_url
+      description: URL of the new VM instance
+      example: https://old.example.com
+    - name: admin_user
+      description: Admin user
+      example: admin # Default user
+`
+
+var metadataWithOutputsExampleReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
+      varType: string
+      defaultValue: new-image
+    outputs:
+    # The URL of the VM
+    - name: instance_url
+      description: URL of the VM instance
+      example: https://new.example.com
+    - name: admin_user
+      description: Admin user
+      example: root # Default user
+`
+
+var metadataWithComments string = `# Header comment
+apiVersion: blueprints.cloud.google.com/v1alpha1
+kind: BlueprintMetadata
+spec:
+  interfaces:
+    variables:
+    # The image of the VM
+    - name: source_image
+      varType: string
+      defaultValue: old-image # Replaced by Marketplace
+    - name: another_image
+      description: Another image.
+      defaultValue: "older-image"
+      varType: string
+    - name: zones
+      varType: list(string)
+`
+
+var metadataDefaultsStripped string = `# Header comment
+apiVersion: blueprints.cloud.google.com/v1alpha1
+kind: BlueprintMetadata
+spec:
+  interfaces:
+    variables:
+    # The image of the VM
+    - name: source_image
+      varType: string
+    - name: another_image
+      description: Another image.
+      varType: string
+    - name: zones
+      varType: list(string)
 `
 
-var tfDefaultWrongType string = `
-variable "value_to_replace" {
-  type = map(number)
-  default = {
-    foo = 2
-    bar = 4
-  }
-}
-`
-var tfNoDefaultWrongType string = `
-variable "value_to_replace" {
-  type = map(number)
-}
+var metadataVarTypeReplaced string = `# Header comment
+apiVersion: blueprints.cloud.google.com/v1alpha1
+kind: BlueprintMetadata
+spec:
+  interfaces:
+    variables:
+    # The image of the VM
+    - name: source_image
+      varType: number
+      defaultValue: 10 # Replaced by Marketplace
+    - name: another_image
+      description: Another image.
+      defaultValue: "older-image"
+      varType: string
+    - name: zones
+      varType: list(string)
 `
 
-var metadata string = `
+var metadataWithCommentsReplaced string = `# Header comment
+apiVersion: blueprints.cloud.google.com/v1alpha1
+kind: BlueprintMetadata
 spec:
   interfaces:
     variables:
+    # The image of the VM
     - name: source_image
-      description: The image name for the disk for the VM instance.
       varType: string
-      defaultValue: old-image
+      defaultValue: new-image # Replaced by Marketplace
     - name: another_image
+      description: Another image.
+      defaultValue: 'newer image: quoted'
+      varType: string
+    - name: zones
+      varType: list(string)
+      defaultValue: [us-east1-b, us-east1-c]
+      description: The zones to deploy to.
+`
+
+var metadataNoDefault string = `
+spec:
+  interfaces:
+    variables:
+    - name: source_image
       description: The image name for the disk for the VM instance.
       varType: string
-      defaultValue: older-image
 `
 
-var metadataReplaced string = `
+var metadataEntryAdded string = `
 spec:
   interfaces:
     variables:
@@ -703,17 +4676,16 @@ spec:
       varType: string
       defaultValue: new-image
     - name: another_image
-      description: The image name for the disk for the VM instance.
       varType: string
       defaultValue: newer-image
 `
 
-var metadataNoDefault string = `
+var metadataDescriptionReplaced string = `
 spec:
   interfaces:
     variables:
     - name: source_image
-      description: The image name for the disk for the VM instance.
+      description: Die Image für die Festplatte der VM-Instanz.
       varType: string
 `
 
@@ -727,6 +4699,64 @@ spec:
       defaultValue: new-value
 `
 
+var metadataTyped string = `
+spec:
+  interfaces:
+    variables:
+    - name: disk_size
+      varType: number
+      defaultValue: 10
+    - name: enable_logs
+      varType: bool
+      defaultValue: true
+    - name: zones
+      varType: list(string)
+    - name: ports
+      varType: list(number)
+      defaultValue:
+      - 8080
+`
+
+var metadataTypedReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: disk_size
+      varType: number
+      defaultValue: 20
+    - name: enable_logs
+      varType: bool
+      defaultValue: false
+    - name: zones
+      varType: list(string)
+      defaultValue:
+      - us-east1-b
+      - us-east1-c
+    - name: ports
+      varType: list(number)
+      defaultValue:
+      - 80
+      - 443
+`
+
+var metadataTypedDiskReplaced string = `
+spec:
+  interfaces:
+    variables:
+    - name: disk_size
+      varType: number
+      defaultValue: 20
+    - name: enable_logs
+      varType: bool
+      defaultValue: true
+    - name: zones
+      varType: list(string)
+    - name: ports
+      varType: list(number)
+      defaultValue:
+      - 8080
+`
+
 var metadataDisplayWithEnumsSingle string = `
 spec:
   ui:
@@ -757,6 +4787,43 @@ spec:
             type: ET_GCE_DISK_IMAGE
 `
 
+var metadataDisplayUnsorted string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          title: Source Image
+          name: source_image
+          enumValueLabels:
+            - value: projects/click-to-deploy-images/global/images/wordpress-1
+              label: wordpress-1
+        another_image:
+          title: Another Image
+          name: another_image
+          enumValueLabels:
+            - value: projects/click-to-deploy-images/global/images/wordpress-3
+              label: wordpress-3
+`
+
+var metadataDisplayUnsortedReplaced string = `spec:
+  ui:
+    input:
+      variables:
+        another_image:
+          enumValueLabels:
+          - label: wordpress-3
+            value: projects/replacement/global/images/wordpress-3-new
+          name: another_image
+          title: Another WordPress Image
+        source_image:
+          enumValueLabels:
+          - label: wordpress-1
+            value: projects/replacement/global/images/wordpress-1-new
+          name: source_image
+          title: WordPress Image
+`
+
 var metadataDisplayWithEnumsDouble string = `
 spec:
   ui:
@@ -782,6 +4849,73 @@ spec:
             type: ET_GCE_DISK_IMAGE
 `
 
+var metadataDisplayWithEnumsOrdered string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          enumValueLabels:
+            - label: wordpress-3
+              value: projects/click-to-deploy-images/global/images/wordpress-3
+              description: Latest
+            - label: wordpress-1
+              value: projects/click-to-deploy-images/global/images/wordpress-1
+            - label: wordpress-2
+              value: projects/click-to-deploy-images/global/images/wordpress-2
+              description: Legacy
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`
+
+var metadataDisplayWithEnumsOrderedReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          enumValueLabels:
+            - label: wordpress-3
+              value: projects/replacement/global/images/wordpress-3-new
+              description: Latest
+            - label: wordpress-1
+              value: projects/replacement/global/images/wordpress-1-new
+            - label: wordpress-2
+              value: projects/replacement/global/images/wordpress-2-new
+              description: Legacy
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`
+
+var metadataDisplayWithEnumsDoubleRelabeled string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: WordPress Image
+          enumValueLabels:
+            - label: WordPress 1 (Marketplace)
+              value: projects/replacement/global/images/wordpress-1-new
+            - label: WordPress 2 (Marketplace)
+              value: projects/replacement/global/images/wordpress-2-new
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+        another_image:
+          name: another_image
+          title: Another Image
+          enumValueLabels:
+            - label: wordpress-3
+              value: projects/replacement/global/images/wordpress-3-new
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`
+
 var metadataDisplayWithEnumsDoubleReplaced string = `
 spec:
   ui:
@@ -807,6 +4941,205 @@ spec:
             type: ET_GCE_DISK_IMAGE
 `
 
+var metadataDisplayWithEnumsDoublePartiallyReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          enumValueLabels:
+            - label: wordpress-1
+              value: projects/replacement/global/images/wordpress-1-new
+            - label: wordpress-2
+              value: projects/click-to-deploy-images/global/images/wordpress-2
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+        another_image:
+          name: another_image
+          title: Another Image
+          enumValueLabels:
+            - label: wordpress-3
+              value: projects/click-to-deploy-images/global/images/wordpress-3
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+`
+
+var metadataDisplayWithDiskImageProperty string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+            gceDiskImage:
+              defaultImage: projects/click-to-deploy-images/global/images/wordpress-1
+              images:
+                - projects/click-to-deploy-images/global/images/wordpress-1
+                - projects/click-to-deploy-images/global/images/wordpress-2
+              family: other-value
+        machine_type:
+          name: machine_type
+          title: Machine Type
+          xGoogleProperty:
+            type: ET_GCE_MACHINE_TYPE
+            gceMachineType:
+              minCpu: 2
+              defaultMachineType: e2-medium
+`
+
+var metadataDisplayWithDiskImagePropertyReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+            gceDiskImage:
+              defaultImage: projects/replacement/global/images/wordpress-1-new
+              images:
+                - projects/replacement/global/images/wordpress-1-new
+                - projects/replacement/global/images/wordpress-2-new
+              family: other-value
+        machine_type:
+          name: machine_type
+          title: Machine Type
+          xGoogleProperty:
+            type: ET_GCE_MACHINE_TYPE
+            gceMachineType:
+              minCpu: 2
+              defaultMachineType: e2-medium
+`
+
+var metadataDisplayWithImageProject string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          enumValueLabels:
+            - label: wordpress-1
+              value: projects/click-to-deploy-images/global/images/wordpress-1
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+            imageProject: click-to-deploy-images
+`
+
+var metadataDisplayWithImageProjectReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        source_image:
+          name: source_image
+          title: Source Image
+          enumValueLabels:
+            - label: wordpress-1
+              value: projects/mpi-partner/global/images/wordpress-1
+          xGoogleProperty:
+            type: ET_GCE_DISK_IMAGE
+            imageProject: mpi-partner
+`
+
+var metadataDisplayWithProperties string = `
+spec:
+  ui:
+    input:
+      variables:
+        admin_email:
+          invisible: false
+          name: admin_email
+          required: true
+          title: Admin Email
+        zone:
+          maxItems: 1
+          name: zone
+          title: Zone
+          xGoogleProperty:
+            type: ET_GCE_ZONE
+`
+
+var metadataDisplayWithPropertiesReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        admin_email:
+          invisible: true
+          name: admin_email
+          required: false
+          title: Admin Email
+        zone:
+          maxItems: 2
+          name: zone
+          title: Zone
+          xGoogleProperty:
+            type: ET_GCE_REGION
+`
+
+var metadataDisplayWithNumbers string = `
+spec:
+  ui:
+    input:
+      variables:
+        disk_size:
+          name: disk_size
+          title: Disk Size
+          xGoogleProperty:
+            default: 20
+            max: 1000
+            min: 10
+            type: ET_GCE_DISK_SIZE
+        replicas:
+          name: replicas
+          title: Replicas
+          xGoogleProperty:
+            type: ET_NUMBER
+        zone:
+          name: zone
+          title: Zone
+          xGoogleProperty:
+            default: us-central1-a
+            type: ET_GCE_ZONE
+`
+
+var metadataDisplayWithNumbersReplaced string = `
+spec:
+  ui:
+    input:
+      variables:
+        disk_size:
+          name: disk_size
+          title: Disk Size
+          xGoogleProperty:
+            default: 50
+            max: 2000
+            min: 10
+            type: ET_GCE_DISK_SIZE
+        replicas:
+          name: replicas
+          title: Replicas
+          xGoogleProperty:
+            max: 5
+            min: 1
+            type: ET_NUMBER
+        zone:
+          name: zone
+          title: Zone
+          xGoogleProperty:
+            default: us-central1-a
+            type: ET_GCE_ZONE
+`
+
 var metadataDisplayNoEnums string = `
 spec:
   ui:
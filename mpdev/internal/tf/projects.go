@@ -0,0 +1,52 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import "regexp"
+
+// gceImageProjectPattern matches GCE image URIs, optionally prefixed by the
+// Compute Engine API URL, and captures the segments before, of and after the
+// project
+var gceImageProjectPattern = regexp.MustCompile(
+	`^((?:https://www\.googleapis\.com/compute/[^/\s]+/)?projects/)([^/\s]+)(/global/images/\S+)$`)
+
+// projectReplacementFor returns a GCE image URI with its project replaced by
+// the new project in ProjectRemap
+func (config *overwriteConfig) projectReplacementFor(value string) (string, bool) {
+	match := gceImageProjectPattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", false
+	}
+	newProject, ok := config.ProjectRemap[match[2]]
+	if !ok {
+		return "", false
+	}
+	return match[1] + newProject + match[3], true
+}
+
+// isProjectRemapTarget returns whether a value is a GCE image URI in one of the
+// new projects of ProjectRemap
+func (config *overwriteConfig) isProjectRemapTarget(value string) bool {
+	match := gceImageProjectPattern.FindStringSubmatch(value)
+	if match == nil {
+		return false
+	}
+	for _, newProject := range config.ProjectRemap {
+		if newProject == match[2] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+const requiredProvidersConst = "required_providers"
+
+// requiredProvider is a provider requirement in the required_providers block
+// of a terraform block
+type requiredProvider struct {
+	name string
+	body *hclwrite.Body
+}
+
+// stageProviderVersions adds the edits setting the version constraints of the
+// ProviderVersions in the required_providers blocks of the Terraform files of
+// the module directories. Every provider must be declared in some file.
+func stageProviderVersions(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, moduleDirs []string) error {
+	if len(config.ProviderVersions) == 0 {
+		return nil
+	}
+	fmt.Printf("Replacing the version constraints of the providers: %s\n", config.ProviderVersions)
+
+	declared := map[string]bool{}
+	for _, moduleDir := range moduleDirs {
+		filenames, err := getModuleFilenames(result.fsys, moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, filename := range filenames {
+			if isTfJSONFile(filename) {
+				continue
+			}
+			err = stageProviderVersionsFile(result, edits, report, config, filename, declared)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(config.ProviderVersions) {
+		if !declared[name] {
+			return newVariableError(ErrVariableNotFound, name,
+				"provider: %s not found in %s. Searched directories: %s",
+				name, requiredProvidersConst, moduleDirs)
+		}
+	}
+	return nil
+}
+
+// stageProviderVersionsFile adds the edit setting the version constraints of
+// the ProviderVersions declared in a file, and marks them as declared
+func stageProviderVersionsFile(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, filename string, declared map[string]bool) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	var providers []requiredProvider
+	for _, provider := range findRequiredProviders(file) {
+		if _, ok := config.ProviderVersions[provider.name]; ok {
+			declared[provider.name] = true
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		return nil
+	}
+
+	modified, err := isModifiedSince(result, config, filename)
+	if err != nil || !modified {
+		return err
+	}
+
+	changed := false
+	for _, provider := range providers {
+		tokens, start, end, err := getProviderVersionRange(provider, filename)
+		if err != nil {
+			return err
+		}
+		oldVersion, ok := parseStringLiteral(tokens[start:end])
+		if !ok {
+			return newVariableError(ErrWrongType, provider.name,
+				"version constraint of provider: %s in %s must be a string", provider.name, filename)
+		}
+
+		newVersion := config.ProviderVersions[provider.name]
+		if oldVersion == newVersion {
+			continue
+		}
+		changed = true
+		report.Changes = append(report.Changes, VariableChange{
+			File:       filename,
+			Variable:   requiredProvidersConst + "." + provider.name,
+			OldDefault: oldVersion,
+			NewDefault: newVersion,
+		})
+	}
+	if !changed {
+		return nil
+	}
+
+	edits.add(filename, func(result *OverwriteResult) error {
+		return overwriteProviderVersionsFile(result, config, filename)
+	})
+	return nil
+}
+
+// overwriteProviderVersionsFile sets the version constraints of the
+// ProviderVersions declared in a file
+func overwriteProviderVersionsFile(result *OverwriteResult, config *overwriteConfig, filename string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	for _, provider := range findRequiredProviders(file) {
+		newVersion, ok := config.ProviderVersions[provider.name]
+		if !ok {
+			continue
+		}
+		tokens, start, end, err := getProviderVersionRange(provider, filename)
+		if err != nil {
+			return err
+		}
+
+		var updated hclwrite.Tokens
+		updated = append(updated, tokens[:start]...)
+		updated = append(updated, getAttributeValueTokens(newVersion)...)
+		updated = append(updated, tokens[end:]...)
+		provider.body.SetAttributeRaw(provider.name, updated)
+	}
+
+	result.stageFile(filename, b, file.BuildTokens(nil).Bytes())
+	return nil
+}
+
+// getProviderVersionRange returns the expression tokens of a provider
+// requirement and the start and end indices of its version constraint. A
+// requirement is either an object with a version attribute, or a version
+// constraint string as in Terraform 0.12.
+func getProviderVersionRange(provider requiredProvider, filename string) (hclwrite.Tokens, int, int, error) {
+	tokens := provider.body.GetAttribute(provider.name).Expr().BuildTokens(nil)
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenOBrace {
+		return tokens, 0, len(tokens), nil
+	}
+
+	item := findObjectItem(getObjectItems(tokens), "version")
+	if item == nil {
+		return nil, 0, 0, newVariableError(ErrVariableNotFound, provider.name,
+			"version constraint of provider: %s not found in %s", provider.name, filename)
+	}
+	start, end := getObjectItemValueRange(tokens, *item)
+	return tokens, start, end, nil
+}
+
+// findRequiredProviders returns the providers declared in the
+// required_providers blocks of the terraform blocks of file
+func findRequiredProviders(file *hclwrite.File) []requiredProvider {
+	var providers []requiredProvider
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "terraform" {
+			continue
+		}
+		for _, requiredProviders := range block.Body().Blocks() {
+			if requiredProviders.Type() != requiredProvidersConst {
+				continue
+			}
+			body := requiredProviders.Body()
+			var names []string
+			for name := range body.Attributes() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				providers = append(providers, requiredProvider{name: name, body: body})
+			}
+		}
+	}
+	return providers
+}
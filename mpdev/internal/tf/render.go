@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// RenderDiff returns a unified diff of the original and proposed contents of
+// each changed file of result, in sorted order, such as for printing the
+// changes of a dry run in CI logs. Files without original contents, such as a
+// main.tf created for the consumer label, are diffed against /dev/null.
+func RenderDiff(result *OverwriteResult) string {
+	var sb strings.Builder
+	for _, filename := range result.ChangedFiles() {
+		change := result.Files[filename]
+		oldName := filename
+		if change.Original == nil {
+			oldName = "/dev/null"
+		}
+		fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, filename)
+		renderHunks(&sb, diffLines(splitLines(change.Original), splitLines(change.Proposed)))
+	}
+	return sb.String()
+}
+
+// diffOp is a line of a diff. Its kind is ' ' for an unchanged line, '-' for
+// a removed line and '+' for an added line.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits b into lines, each ending with a newline unless it is the
+// last line of a file without a trailing newline
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b. The common prefix
+// and suffix are trimmed first, so that the longest common subsequence is only
+// computed for the changed lines.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	oldLines, newLines := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			ops = append(ops, diffOp{' ', oldLines[i]})
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', oldLines[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', newLines[j]})
+			j++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// renderHunks writes the changes of ops as unified diff hunks. Changes
+// separated by no more than twice the diffContext are in the same hunk.
+func renderHunks(sb *strings.Builder, ops []diffOp) {
+	// oldLine and newLine are the line counts of ops[:counted]
+	oldLine, newLine, counted := 0, 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		for _, op := range ops[counted:start] {
+			oldLine, newLine = advanceLines(op, oldLine, newLine)
+		}
+		oldStart, newStart := oldLine, newLine
+		var hunk strings.Builder
+		for _, op := range ops[start:end] {
+			oldLine, newLine = advanceLines(op, oldLine, newLine)
+			hunk.WriteByte(op.kind)
+			hunk.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLine-oldStart),
+			hunkRange(newStart, newLine-newStart))
+		sb.WriteString(hunk.String())
+		i, counted = end, end
+	}
+}
+
+// advanceLines returns the line counts of the old and new file after op
+func advanceLines(op diffOp, oldLine, newLine int) (int, int) {
+	if op.kind != '+' {
+		oldLine++
+	}
+	if op.kind != '-' {
+		newLine++
+	}
+	return oldLine, newLine
+}
+
+// hunkRange returns the `start,count` of a hunk header. As in diff -u, an
+// empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
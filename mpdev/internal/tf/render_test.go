@@ -0,0 +1,152 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderDiff(t *testing.T) {
+	testcases := []struct {
+		name         string
+		files        map[string]*FileChange
+		expectedDiff string
+	}{{
+		name: "Changed lines with context",
+		files: map[string]*FileChange{
+			"main.tf": {
+				Original: []byte(numberedLines(1, 20)),
+				Proposed: []byte("1\ntwo\n" + numberedLines(3, 9) + numberedLines(11, 16) +
+					"seventeen\n" + numberedLines(18, 20)),
+			},
+		},
+		expectedDiff: `--- main.tf
++++ main.tf
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -7,14 +7,13 @@
+ 7
+ 8
+ 9
+-10
+ 11
+ 12
+ 13
+ 14
+ 15
+ 16
+-17
++seventeen
+ 18
+ 19
+ 20
+`,
+	}, {
+		name: "Unchanged files are skipped",
+		files: map[string]*FileChange{
+			"main.tf": {
+				Original: []byte("a\n"),
+				Proposed: []byte("a\n"),
+			},
+			"metadata.yaml": {
+				Original: []byte("spec:\n  image: old-image\n"),
+				Proposed: []byte("spec:\n  image: new-image\n"),
+			},
+		},
+		expectedDiff: `--- metadata.yaml
++++ metadata.yaml
+@@ -1,2 +1,2 @@
+ spec:
+-  image: old-image
++  image: new-image
+`,
+	}, {
+		name: "Added file",
+		files: map[string]*FileChange{
+			"main.tf": {
+				Proposed: []byte("provider \"google\" {\n}\n"),
+			},
+		},
+		expectedDiff: `--- /dev/null
++++ main.tf
+@@ -0,0 +1,2 @@
++provider "google" {
++}
+`,
+	}, {
+		name: "Missing newline at end of file",
+		files: map[string]*FileChange{
+			"main.tf": {
+				Original: []byte("x\ny"),
+				Proposed: []byte("x\nz\n"),
+			},
+		},
+		expectedDiff: `--- main.tf
++++ main.tf
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++z
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := newOverwriteResult()
+			result.Files = tc.files
+			assert.Equal(t, tc.expectedDiff, RenderDiff(result))
+		})
+	}
+}
+
+func TestRenderDiffDryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+	assert.NoError(t, err)
+
+	result, err := OverwriteAll(&overwriteConfig{
+		DryRun: true,
+		NewValues: map[string]string{
+			"value_to_replace": "new-value",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	diff := RenderDiff(result)
+	assert.Contains(t, diff, "-  default = \"original-value\"\n+  default = \"new-value\"\n")
+}
+
+// numberedLines returns the numbers from first to last, one per line
+func numberedLines(first, last int) string {
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		sb.WriteString(strconv.Itoa(i) + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// replacementsCSVHeader is the header row of a replacements CSV file
+var replacementsCSVHeader = []string{"old_value", "new_value"}
+
+// LoadReplacementsCSV reads Replacements from a CSV file with the header
+// old_value,new_value followed by one row per replacement, such as a mapping
+// exported from a spreadsheet. Surrounding whitespace of values is trimmed.
+// Rows with an empty value and values mapped more than once are errors.
+func LoadReplacementsCSV(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failure reading replacements CSV: %s error: %w", path, err)
+	}
+	// Spreadsheets may prefix exported files with a byte order mark
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(b))
+	reader.FieldsPerRecord = len(replacementsCSVHeader)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header in replacements CSV: %s, must be: %s",
+			path, strings.Join(replacementsCSVHeader, ","))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing replacements CSV: %s error: %w", path, err)
+	}
+	for i, column := range header {
+		if strings.TrimSpace(column) != replacementsCSVHeader[i] {
+			return nil, fmt.Errorf("invalid header: %s in replacements CSV: %s, must be: %s",
+				strings.Join(header, ","), path, strings.Join(replacementsCSVHeader, ","))
+		}
+	}
+
+	replacements := map[string]string{}
+	lines := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failure parsing replacements CSV: %s error: %w", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		oldValue := strings.TrimSpace(record[0])
+		newValue := strings.TrimSpace(record[1])
+		if oldValue == "" || newValue == "" {
+			return nil, fmt.Errorf("empty value on line %d of replacements CSV: %s", line, path)
+		}
+		if previous, ok := lines[oldValue]; ok {
+			return nil, fmt.Errorf("duplicate old value: %s on lines %d and %d of replacements CSV: %s",
+				oldValue, previous, line, path)
+		}
+		lines[oldValue] = line
+		replacements[oldValue] = newValue
+	}
+	return replacements, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadReplacementsCSV(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		csv                  string
+		expectedReplacements map[string]string
+		errorContains        string
+	}{{
+		name: "Load replacements",
+		csv: `old_value,new_value
+projects/old/global/images/image-1,projects/new/global/images/image-1
+projects/old/global/images/image-2,projects/new/global/images/image-2
+`,
+		expectedReplacements: map[string]string{
+			"projects/old/global/images/image-1": "projects/new/global/images/image-1",
+			"projects/old/global/images/image-2": "projects/new/global/images/image-2",
+		},
+	}, {
+		name: "Load replacements exported from a spreadsheet",
+		csv: "\ufeffold_value,new_value\r\n" +
+			"\"old-image, v1\", new-image \r\n" +
+			"\r\n" +
+			"older-image,newer-image\r\n",
+		expectedReplacements: map[string]string{
+			"old-image, v1": "new-image",
+			"older-image":   "newer-image",
+		},
+	}, {
+		name:                 "Load no replacements",
+		csv:                  "old_value,new_value\n",
+		expectedReplacements: map[string]string{},
+	}, {
+		name:          "Fail on empty file",
+		csv:           "",
+		errorContains: "missing header in replacements CSV",
+	}, {
+		name:          "Fail on invalid header",
+		csv:           "old,new\nold-image,new-image\n",
+		errorContains: "invalid header: old,new in replacements CSV",
+	}, {
+		name:          "Fail on missing header",
+		csv:           "old-image,new-image\n",
+		errorContains: "invalid header: old-image,new-image in replacements CSV",
+	}, {
+		name:          "Fail on wrong number of fields with line number",
+		csv:           "old_value,new_value\nold-image,new-image\nolder-image\n",
+		errorContains: "record on line 3: wrong number of fields",
+	}, {
+		name:          "Fail on malformed quotes with line number",
+		csv:           "old_value,new_value\nold-image,\"new-image\n",
+		errorContains: "parse error on line 2",
+	}, {
+		name:          "Fail on empty value with line number",
+		csv:           "old_value,new_value\nold-image,new-image\nolder-image,\n",
+		errorContains: "empty value on line 3 of replacements CSV",
+	}, {
+		name:          "Fail on duplicate old value with line numbers",
+		csv:           "old_value,new_value\nold-image,new-image\nolder-image,newer-image\nold-image,other-image\n",
+		errorContains: "duplicate old value: old-image on lines 2 and 4 of replacements CSV",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			csvPath := path.Join(tmpDir, "replacements.csv")
+			err = os.WriteFile(csvPath, []byte(tc.csv), 0600)
+			assert.NoError(t, err)
+
+			replacements, err := LoadReplacementsCSV(csvPath)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedReplacements, replacements)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
+
+func TestLoadReplacementsCSVNoFile(t *testing.T) {
+	_, err := LoadReplacementsCSV(path.Join(os.TempDir(), "missing-replacements.csv"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "failure reading replacements CSV")
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import "context"
+
+// ChangeReport summarizes the variables changed by an overwrite of a
+// Terraform module
+type ChangeReport struct {
+	// Changes lists the variables whose default value was changed
+	Changes []VariableChange `json:"changes"`
+
+	// Matched lists the variables targeted by the overwrite config that
+	// were found in the module
+	Matched []string `json:"matched"`
+
+	// Skipped lists the matched variables, local values and resource
+	// attributes that were not changed. A value is skipped if it already
+	// equals the new value or was replaced by a previous run, or, for
+	// variables matched by VariableTypes, if it is sensitive, is not a string
+	// or has no replacement. A list or map default is skipped if none of its
+	// elements has a replacement.
+	Skipped []string `json:"skipped"`
+
+	// NoOpNewValues lists the NewValues entries that set a value to the
+	// value it already has, which often means the config is stale
+	NoOpNewValues []NoOpValue `json:"noOpNewValues"`
+}
+
+// NoOpValue is a NewValues entry that did not change the value in a file
+type NoOpValue struct {
+	File     string `json:"file"`
+	Variable string `json:"variable"`
+	Value    string `json:"value"`
+}
+
+// VariableChange describes a change to the default value of a variable
+type VariableChange struct {
+	File       string      `json:"file"`
+	Variable   string      `json:"variable"`
+	OldDefault interface{} `json:"oldDefault"`
+	NewDefault interface{} `json:"newDefault"`
+}
+
+// OverwriteTfWithReport replaces default variable values in Terraform modules
+// and returns a report of the variables that were changed
+func OverwriteTfWithReport(config *overwriteConfig, dir string) (*ChangeReport, error) {
+	_, report, err := overwriteTf(context.Background(), config, dir)
+	return report, err
+}
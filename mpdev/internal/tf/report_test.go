@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteTfWithReport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+	assert.NoError(t, err)
+	err = os.WriteFile(path.Join(tmpDir, "anyfilename.tf"), []byte(otherTf), 0600)
+	assert.NoError(t, err)
+
+	report, err := OverwriteTfWithReport(&overwriteConfig{
+		NewValues: map[string]string{
+			"value_to_replace":       "new-value",
+			"other_value_to_replace": "old-value",
+			"another_variable":       "newest-value",
+		},
+	}, tmpDir)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &ChangeReport{
+		Changes: []VariableChange{{
+			File:       path.Join(tmpDir, "anyfilename.tf"),
+			Variable:   "another_variable",
+			OldDefault: "oldest-value",
+			NewDefault: "newest-value",
+		}, {
+			File:       path.Join(tmpDir, "main.tf"),
+			Variable:   "value_to_replace",
+			OldDefault: "original-value",
+			NewDefault: "new-value",
+		}},
+		Matched: []string{"another_variable", "other_value_to_replace", "value_to_replace"},
+		Skipped: []string{"other_value_to_replace"},
+		NoOpNewValues: []NoOpValue{{
+			File:     path.Join(tmpDir, "main.tf"),
+			Variable: "other_value_to_replace",
+			Value:    "old-value",
+		}},
+	}, report)
+}
+
+func TestOverwriteTfWithReportError(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "tftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	err = os.WriteFile(path.Join(tmpDir, "main.tf"), []byte(mainTf), 0600)
+	assert.NoError(t, err)
+
+	report, err := OverwriteTfWithReport(&overwriteConfig{
+		NewValues: map[string]string{
+			"missing_variable": "new-value",
+		},
+	}, tmpDir)
+	assert.Nil(t, report)
+	assert.ErrorContains(t, err, "variable: missing_variable not found")
+}
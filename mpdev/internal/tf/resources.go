@@ -0,0 +1,223 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// resourceAttribute is an attribute of a resource block matched by one of the
+// ResourceAttributes
+type resourceAttribute struct {
+	// address is the resource type and name followed by the path of the
+	// attribute, such as google_compute_instance_template.web.disk.source_image
+	address   string
+	attribute *hclwrite.Attribute
+	body      *hclwrite.Body
+	name      string
+}
+
+// stageResourceAttributes adds the edits replacing the string literal values of
+// the ResourceAttributes of resource blocks in the Terraform files of the
+// module directories. Values that are not string literals, such as references
+// to variables, are left as is. Values without a replacement are skipped
+// unless Strict is set.
+func stageResourceAttributes(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, moduleDirs []string) error {
+	if len(config.ResourceAttributes) == 0 {
+		return nil
+	}
+	fmt.Printf("Replacing the values of the resource attributes: %s\n", config.ResourceAttributes)
+
+	for _, moduleDir := range moduleDirs {
+		filenames, err := getModuleFilenames(result.fsys, moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, filename := range filenames {
+			if isTfJSONFile(filename) {
+				continue
+			}
+			modified, err := isModifiedSince(result, config, filename)
+			if err != nil {
+				return err
+			}
+			if !modified {
+				continue
+			}
+			err = stageResourceAttributesFile(result, edits, report, config, filename)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func stageResourceAttributesFile(result *OverwriteResult, edits *fileEdits, report *ChangeReport,
+	config *overwriteConfig, filename string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	replacements := map[string]string{}
+	for _, attribute := range findResourceAttributes(file, config.ResourceAttributes) {
+		value, ok := getStringLiteral(attribute.attribute)
+		if !ok {
+			fmt.Printf("Value of resource attribute: %s in %s is not a string literal. Skipping\n",
+				attribute.address, filename)
+			continue
+		}
+
+		replaceVal, ok, err := config.replacementFor(attribute.address, value)
+		if err != nil {
+			return err
+		}
+		if !ok && config.isReplacementTarget(value) {
+			report.Skipped = append(report.Skipped, attribute.address)
+			continue
+		}
+		if !ok && config.Strict {
+			return newVariableError(ErrReplacementNotFound, attribute.address,
+				"value: %s of resource attribute: %s in %s not found in replacements",
+				value, attribute.address, filename)
+		}
+		if !ok {
+			fmt.Printf("Value: %s of resource attribute: %s in %s not found in replacements. Skipping\n",
+				value, attribute.address, filename)
+			continue
+		}
+
+		replacements[value] = replaceVal
+		report.Changes = append(report.Changes, VariableChange{
+			File:       filename,
+			Variable:   attribute.address,
+			OldDefault: value,
+			NewDefault: replaceVal,
+		})
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	edits.add(filename, func(result *OverwriteResult) error {
+		return overwriteResourceAttributesFile(result, config, filename, replacements)
+	})
+	return nil
+}
+
+// overwriteResourceAttributesFile replaces the string literal values of the
+// ResourceAttributes of a file that are keys of replacements
+func overwriteResourceAttributesFile(result *OverwriteResult, config *overwriteConfig, filename string,
+	replacements map[string]string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclwrite.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	for _, attribute := range findResourceAttributes(file, config.ResourceAttributes) {
+		value, ok := getStringLiteral(attribute.attribute)
+		if !ok {
+			continue
+		}
+		replaceVal, ok := replacements[value]
+		if !ok {
+			continue
+		}
+		_, err = setStringAttribute(attribute.body, attribute.name, replaceVal)
+		if err != nil {
+			return fmt.Errorf("failure overwriting resource attribute: %s error: %w", attribute.address, err)
+		}
+	}
+
+	result.stageFile(filename, b, file.BuildTokens(nil).Bytes())
+	return nil
+}
+
+// findResourceAttributes returns the attributes of the resource blocks of file
+// matching attributePaths. Each path is a resource type followed by the types
+// of nested blocks and the attribute name, such as
+// google_compute_instance_template.disk.source_image. Every resource of the
+// type and every nested block of the types is matched.
+func findResourceAttributes(file *hclwrite.File, attributePaths []string) []resourceAttribute {
+	var attributes []resourceAttribute
+	for _, attributePath := range attributePaths {
+		parts := strings.Split(attributePath, ".")
+		for _, block := range file.Body().Blocks() {
+			labels := block.Labels()
+			if block.Type() != "resource" || len(labels) != 2 || labels[0] != parts[0] {
+				continue
+			}
+			address := labels[0] + "." + labels[1]
+			attributes = append(attributes, findBlockAttributes(block.Body(), address, parts[1:])...)
+		}
+	}
+	return attributes
+}
+
+// findBlockAttributes returns the attributes at a path of nested block types
+// followed by an attribute name within body
+func findBlockAttributes(body *hclwrite.Body, address string, path []string) []resourceAttribute {
+	address += "." + path[0]
+	if len(path) == 1 {
+		attribute := body.GetAttribute(path[0])
+		if attribute == nil {
+			return nil
+		}
+		return []resourceAttribute{{address: address, attribute: attribute, body: body, name: path[0]}}
+	}
+
+	var attributes []resourceAttribute
+	for _, block := range body.Blocks() {
+		if block.Type() == path[0] {
+			attributes = append(attributes, findBlockAttributes(block.Body(), address, path[1:])...)
+		}
+	}
+	return attributes
+}
+
+// getStringLiteral returns the value of an attribute if it is a string literal
+func getStringLiteral(attribute *hclwrite.Attribute) (string, bool) {
+	return parseStringLiteral(attribute.Expr().BuildTokens(nil))
+}
+
+// parseStringLiteral returns the value of expression tokens if they are a
+// string literal
+func parseStringLiteral(tokens hclwrite.Tokens) (string, bool) {
+	expr, diag := hclsyntax.ParseExpression(tokens.Bytes(), "", hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return "", false
+	}
+	value, diag := expr.Value(nil)
+	if diag.HasErrors() || value.Type() != cty.String || !value.IsKnown() || value.IsNull() {
+		return "", false
+	}
+	return value.AsString(), true
+}
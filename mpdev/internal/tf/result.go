@@ -0,0 +1,288 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"gopkg.in/yaml.v3"
+)
+
+const backupExtension = ".orig"
+
+// OverwriteResult contains the files touched by an overwrite keyed by filename
+type OverwriteResult struct {
+	Files map[string]*FileChange
+
+	// scanned records the files read to stage the overwrite
+	scanned map[string]bool
+
+	// fsys is the file system the module files are read from
+	fsys moduleFS
+
+	// mu guards Files and scanned while files are staged concurrently
+	mu sync.Mutex
+}
+
+// FileChange contains the original and proposed contents of a file
+type FileChange struct {
+	Original []byte
+	Proposed []byte
+}
+
+func newOverwriteResult() *OverwriteResult {
+	return &OverwriteResult{Files: map[string]*FileChange{}, scanned: map[string]bool{}, fsys: osFS{}}
+}
+
+// readFile returns the proposed contents of a file if it has already been
+// staged, so that multiple changes to the same file accumulate.
+func (r *OverwriteResult) readFile(filename string) ([]byte, error) {
+	r.mu.Lock()
+	change, ok := r.Files[filename]
+	r.mu.Unlock()
+	if ok {
+		return change.Proposed, nil
+	}
+	return r.fsys.ReadFile(filename)
+}
+
+// markScanned records that a file was read to stage the overwrite
+func (r *OverwriteResult) markScanned(filename string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scanned[filename] = true
+}
+
+// stageFile records the proposed contents of a file. The original contents
+// are only recorded the first time a file is staged. The proposed contents keep
+// the line endings of the original contents.
+func (r *OverwriteResult) stageFile(filename string, original []byte, proposed []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if change, ok := r.Files[filename]; ok {
+		change.Proposed = withLineEndings(change.Original, proposed)
+		return
+	}
+	r.Files[filename] = &FileChange{Original: original, Proposed: withLineEndings(original, proposed)}
+}
+
+// filenames returns the staged filenames in sorted order
+func (r *OverwriteResult) filenames() []string {
+	var filenames []string
+	for filename := range r.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// ChangedFiles returns the staged files whose proposed contents differ from
+// their original contents, in sorted order
+func (r *OverwriteResult) ChangedFiles() []string {
+	var changed []string
+	for _, filename := range r.filenames() {
+		change := r.Files[filename]
+		if change.Original == nil || !bytes.Equal(change.Original, change.Proposed) {
+			changed = append(changed, filename)
+		}
+	}
+	return changed
+}
+
+// commit writes the proposed contents of the changed files unless DryRun is set.
+// The proposed contents are first validated, so that no file is written if any
+// of them fails to parse. If Backup is set, the original contents of changed
+// files are then copied to a sibling file with the backupExtension. All
+// contents are written to temporary files next to their targets and only then
+// renamed over the targets. If a rename fails, the files already renamed are
+// rolled back to their original contents, so that a failure part way through
+// does not leave a module half modified. If Lock is set, the files are written
+// while holding the lock file of the module in dir.
+func (r *OverwriteResult) commit(config *overwriteConfig, dir string) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+
+	if config.CheckOnly {
+		if changed := r.ChangedFiles(); len(changed) > 0 {
+			return fmt.Errorf("%w: %s", ErrWouldChange, strings.Join(changed, ", "))
+		}
+		return nil
+	}
+
+	changed := r.ChangedFiles()
+	if config.DryRun {
+		for _, filename := range changed {
+			fmt.Printf("Dry run. Not writing changes to %s\n", filename)
+		}
+		return nil
+	}
+
+	if config.Lock && len(changed) > 0 {
+		release, err := acquireLock(dir, config.lockTimeout())
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		err = r.checkUnmodified()
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Backup {
+		err := r.backup()
+		if err != nil {
+			return err
+		}
+	}
+
+	tmpFilenames := map[string]string{}
+	removeTmpFiles := func() {
+		for _, tmpFilename := range tmpFilenames {
+			os.Remove(tmpFilename)
+		}
+	}
+
+	for _, filename := range changed {
+		tmpFilename, err := writeTmpFile(filename, r.Files[filename].Proposed)
+		if err != nil {
+			removeTmpFiles()
+			return err
+		}
+		tmpFilenames[filename] = tmpFilename
+	}
+
+	var renamed []string
+	for _, filename := range changed {
+		err := os.Rename(tmpFilenames[filename], filename)
+		if err != nil {
+			removeTmpFiles()
+			return errors.Join(err, r.rollback(renamed))
+		}
+		delete(tmpFilenames, filename)
+		renamed = append(renamed, filename)
+	}
+
+	return config.runPostHook(dir)
+}
+
+// rollback restores the original contents of files that have already been
+// written. Files that did not exist before are removed.
+func (r *OverwriteResult) rollback(filenames []string) error {
+	var errs []error
+	for _, filename := range filenames {
+		fmt.Printf("Rolling back changes to %s\n", filename)
+		original := r.Files[filename].Original
+		if original == nil {
+			errs = append(errs, os.Remove(filename))
+			continue
+		}
+		tmpFilename, err := writeTmpFile(filename, original)
+		if err == nil {
+			err = os.Rename(tmpFilename, filename)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failure rolling back %s error: %w", filename, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks that the proposed contents of each staged Terraform, tfvars,
+// JSON and YAML file still parse
+func (r *OverwriteResult) validate() error {
+	for _, filename := range r.filenames() {
+		proposed := r.Files[filename].Proposed
+		var err error
+		switch {
+		case isTfJSONFile(filename) || strings.HasSuffix(filename, ".json"):
+			if !json.Valid(proposed) {
+				err = fmt.Errorf("invalid JSON")
+			}
+		case strings.HasSuffix(filename, ".tf") || strings.HasSuffix(filename, tfvarsExtension):
+			_, diag := hclsyntax.ParseConfig(proposed, filename, hcl.Pos{Line: 1, Column: 1})
+			if diag.HasErrors() {
+				err = newParseError(diag)
+			}
+		case strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml"):
+			var node yaml.Node
+			err = yaml.Unmarshal(proposed, &node)
+		}
+		if err != nil {
+			return fmt.Errorf("failure validating proposed contents of %s error: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// backup copies the original contents of changed files to a sibling file with
+// the backupExtension. Files that did not exist or are unchanged are skipped.
+func (r *OverwriteResult) backup() error {
+	for _, filename := range r.filenames() {
+		change := r.Files[filename]
+		if change.Original == nil || bytes.Equal(change.Original, change.Proposed) {
+			continue
+		}
+
+		backupFilename := filename + backupExtension
+		fmt.Printf("Backing up %s to %s\n", filename, backupFilename)
+		err := os.WriteFile(backupFilename, change.Original, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTmpFile writes contents to a temporary file in the same directory as
+// filename, keeping the permissions of filename if it exists.
+func writeTmpFile(filename string, contents []byte) (string, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	tmpFilename := tmpFile.Name()
+
+	_, err = tmpFile.Write(contents)
+	if err == nil {
+		err = tmpFile.Chmod(mode)
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return "", err
+	}
+	return tmpFilename, nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// moduleScan indexes the variables and local values declared in a set of
+// module directories. The files of all directories are parsed concurrently.
+type moduleScan struct {
+	dirs []string
+	// variables maps each directory to its variables by name
+	variables map[string]map[string]*tfconfig.Variable
+	// files maps each directory to its scanned files, sorted by filename
+	files map[string][]*scannedFile
+}
+
+// scannedFile is a Terraform file parsed as part of a moduleScan
+type scannedFile struct {
+	filename string
+	module   *tfconfig.Module
+	diags    hcl.Diagnostics
+
+	// locals are the local values declared in the file. localsErr is set
+	// if the file could not be parsed for local values. It is only reported
+	// if local values are looked up.
+	locals    map[string][]*moduleValue
+	localsErr error
+
+	// nonLiteralDefaults are the ranges of variable defaults that are not
+	// literals, by variable name
+	nonLiteralDefaults map[string]hcl.Range
+}
+
+// scanModules parses the Terraform files in dirs using at most GOMAXPROCS
+// goroutines. Variables are merged per directory in the same order as
+// tfconfig.LoadModule, which is used instead for directories with errors so
+// that the legacy HCL parser and its diagnostics are preserved.
+func scanModules(ctx context.Context, result *OverwriteResult, dirs []string) (*moduleScan, error) {
+	scan := &moduleScan{
+		dirs:      dirs,
+		variables: map[string]map[string]*tfconfig.Variable{},
+		files:     map[string][]*scannedFile{},
+	}
+
+	var files []*scannedFile
+	dirFiles := map[string][]*scannedFile{}
+	unreadableDirs := map[string]bool{}
+	for _, dir := range dirs {
+		filenames, err := getModuleFilenames(result.fsys, dir)
+		if err != nil {
+			unreadableDirs[dir] = true
+			continue
+		}
+		for _, filename := range filenames {
+			file := &scannedFile{filename: filename}
+			files = append(files, file)
+			dirFiles[dir] = append(dirFiles[dir], file)
+		}
+	}
+
+	runConcurrently(len(files), func(i int) {
+		if ctx.Err() == nil {
+			files[i].scan(result)
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		variables := map[string]*tfconfig.Variable{}
+		hasErrors := unreadableDirs[dir]
+		for _, file := range dirFiles[dir] {
+			if file.diags.HasErrors() {
+				hasErrors = true
+				break
+			}
+			// Like tfconfig, later files take precedence
+			for name, variable := range file.module.Variables {
+				variables[name] = variable
+			}
+		}
+
+		if _, onDisk := result.fsys.(osFS); hasErrors && !onDisk {
+			return nil, fmt.Errorf("failure parsing terraform module: %w",
+				newParseError(getDirDiagnostics(dirFiles[dir])))
+		}
+		if hasErrors {
+			module, diag := tfconfig.LoadModule(dir)
+			if diag.HasErrors() {
+				// Prefer the diagnostics of the scan, which include the column
+				if dirDiags := getDirDiagnostics(dirFiles[dir]); dirDiags.HasErrors() {
+					return nil, fmt.Errorf("failure parsing terraform module: %w", newParseError(dirDiags))
+				}
+				return nil, fmt.Errorf("failure parsing terraform module: %w", newModuleParseError(diag))
+			}
+			variables = module.Variables
+		}
+		scan.variables[dir] = variables
+
+		sorted := append([]*scannedFile{}, dirFiles[dir]...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].filename < sorted[j].filename
+		})
+		scan.files[dir] = sorted
+	}
+	return scan, nil
+}
+
+// scan parses the file for variables and local values
+func (f *scannedFile) scan(result *OverwriteResult) {
+	f.module = tfconfig.NewModule(filepath.Dir(f.filename))
+
+	b, err := result.readFile(f.filename)
+	if err != nil {
+		f.diags = hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   fmt.Sprintf("The configuration file %q could not be read.", f.filename),
+		}}
+		f.localsErr = err
+		return
+	}
+	result.markScanned(f.filename)
+
+	parser := hclparse.NewParser()
+	var file *hcl.File
+	if isTfJSONFile(f.filename) {
+		file, f.diags = parser.ParseJSON(b, f.filename)
+	} else {
+		file, f.diags = parser.ParseHCL(b, f.filename)
+	}
+	if file == nil {
+		f.localsErr = fmt.Errorf("failure parsing terraform module: %w", newParseError(f.diags))
+		return
+	}
+	// Defaults that are not literals can't be evaluated by tfconfig. They are
+	// only reported as an error if the variable is overwritten.
+	f.nonLiteralDefaults = getNonLiteralDefaults(file)
+	f.diags = append(f.diags, withoutDiagnosticsIn(tfconfig.LoadModuleFromFile(file, f.module),
+		f.nonLiteralDefaults)...)
+
+	// Local values are only overwritten in HCL files
+	if isTfJSONFile(f.filename) {
+		return
+	}
+	if f.diags.HasErrors() {
+		f.localsErr = fmt.Errorf("failure parsing terraform module: %w", newParseError(f.diags))
+		return
+	}
+	f.locals = getFileLocalValues(f.filename, file.Body.(*hclsyntax.Body))
+}
+
+// values returns the declarations of a variable in each of the scanned
+// directories. If a directory does not declare the variable, local values with
+// the same name are returned instead. At least one declaration must exist.
+func (s *moduleScan) values(varname string) ([]*moduleValue, error) {
+	var values []*moduleValue
+	for _, dir := range s.dirs {
+		variable, ok := s.variables[dir][varname]
+		if ok {
+			values = append(values, &moduleValue{
+				Name:       variable.Name,
+				Filename:   variable.Pos.Filename,
+				Type:       variable.Type,
+				Default:    variable.Default,
+				Sensitive:  variable.Sensitive,
+				NonLiteral: s.hasNonLiteralDefault(dir, variable),
+			})
+			continue
+		}
+
+		for _, file := range s.files[dir] {
+			if file.localsErr != nil {
+				return nil, file.localsErr
+			}
+			values = append(values, file.locals[varname]...)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, newVariableError(ErrVariableNotFound, varname,
+			"variable: %s not found in module. Searched directories: %s",
+			varname, s.dirs)
+	}
+
+	return values, nil
+}
+
+// hasNonLiteralDefault returns whether the default of a variable declared in dir
+// is not a literal
+func (s *moduleScan) hasNonLiteralDefault(dir string, variable *tfconfig.Variable) bool {
+	for _, file := range s.files[dir] {
+		if file.filename == variable.Pos.Filename {
+			_, ok := file.nonLiteralDefaults[variable.Name]
+			return ok
+		}
+	}
+	return false
+}
+
+// variableNames returns the names of the variables declared in the scanned
+// directories, sorted by name within each directory
+func (s *moduleScan) variableNames() []string {
+	var names []string
+	for _, dir := range s.dirs {
+		var dirNames []string
+		for name := range s.variables[dir] {
+			dirNames = append(dirNames, name)
+		}
+		sort.Strings(dirNames)
+		names = append(names, dirNames...)
+	}
+	return names
+}
+
+// variableNamesOfTypes returns the sorted names of the variables declared with
+// one of the types in the scanned directories. A type such as list matches
+// both list and the parameterized list(string).
+func (s *moduleScan) variableNamesOfTypes(types []string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, dir := range s.dirs {
+		for name, variable := range s.variables[dir] {
+			if seen[name] {
+				continue
+			}
+			for _, varType := range types {
+				if variable.Type == varType || strings.HasPrefix(variable.Type, varType+"(") {
+					seen[name] = true
+					names = append(names, name)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getModuleFilenames returns the Terraform files of a module directory in the
+// order tfconfig.LoadModule reads them: primary files sorted by name, followed
+// by override files. Hidden and editor backup files are ignored.
+func getModuleFilenames(fsys moduleFS, dir string) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary, override []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+			(strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#")) {
+			continue
+		}
+
+		var baseName string
+		if isTfJSONFile(name) {
+			baseName = strings.TrimSuffix(name, tfJSONExtension)
+		} else if strings.HasSuffix(name, ".tf") {
+			baseName = strings.TrimSuffix(name, ".tf")
+		} else {
+			continue
+		}
+
+		filename := filepath.Join(dir, name)
+		if baseName == "override" || strings.HasSuffix(baseName, "_override") {
+			override = append(override, filename)
+		} else {
+			primary = append(primary, filename)
+		}
+	}
+	return append(primary, override...), nil
+}
+
+// checkModuleFiles returns an error if none of the module directories of dir
+// contain Terraform files, which usually means dir is not the module root
+func checkModuleFiles(fsys moduleFS, dir string, moduleDirs []string) error {
+	for _, moduleDir := range moduleDirs {
+		filenames, err := getModuleFilenames(fsys, moduleDir)
+		if err != nil {
+			return fmt.Errorf("failure reading terraform module: %s error: %w", moduleDir, err)
+		}
+		if len(filenames) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("no terraform files found in %s", dir)
+}
+
+// getDirDiagnostics returns the diagnostics of the scanned files of a directory.
+// Files held in memory have no legacy HCL diagnostics to fall back on.
+func getDirDiagnostics(files []*scannedFile) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		diags = append(diags, file.diags...)
+	}
+	return diags
+}
+
+// runConcurrently calls fn for each index in [0, n) using at most GOMAXPROCS
+// goroutines, and returns once all calls have completed
+func runConcurrently(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// VariableScope limits the overwrite of Variables to the Terraform files
+// matching File. File is a filename or glob relative to the module directory,
+// such as `modules/db/*.tf`. A pattern without a directory also matches files
+// with that name in any directory.
+type VariableScope struct {
+	File      string
+	Variables []string
+}
+
+// getVariableScopes returns the file patterns the variable is scoped to. The
+// variable is not scoped if none are returned.
+func (config *overwriteConfig) getVariableScopes(varname string) []string {
+	var files []string
+	for _, scope := range config.Scopes {
+		for _, variable := range scope.Variables {
+			if variable == varname {
+				files = append(files, scope.File)
+			}
+		}
+	}
+	return files
+}
+
+// filterScopedValues returns the values declared in files matching the scopes
+// of the variable. At least one value must match.
+func filterScopedValues(config *overwriteConfig, dir string, varname string,
+	values []*moduleValue) ([]*moduleValue, error) {
+	files := config.getVariableScopes(varname)
+	if len(files) == 0 {
+		return values, nil
+	}
+
+	var scoped []*moduleValue
+	for _, value := range values {
+		rel, err := filepath.Rel(dir, value.Filename)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, file := range files {
+			if matchesScope(file, rel) {
+				scoped = append(scoped, value)
+				break
+			}
+		}
+	}
+
+	if len(scoped) == 0 {
+		return nil, newVariableError(ErrVariableNotFound, varname,
+			"variable: %s not found in files matching: %s", varname, files)
+	}
+	return scoped, nil
+}
+
+func matchesScope(file string, rel string) bool {
+	if ok, _ := path.Match(file, rel); ok {
+		return true
+	}
+	if path.Base(file) == file {
+		ok, _ := path.Match(file, path.Base(rel))
+		return ok
+	}
+	return false
+}
+
+// checkDuplicateValues returns an error if RejectDuplicates is set and the
+// values of a variable are declared in more than one file, unless the variable
+// is listed in AllowDuplicates
+func checkDuplicateValues(config *overwriteConfig, varname string, values []*moduleValue) error {
+	if !config.RejectDuplicates {
+		return nil
+	}
+	for _, allowed := range config.AllowDuplicates {
+		if allowed == varname {
+			return nil
+		}
+	}
+
+	var filenames []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value.Filename] {
+			seen[value.Filename] = true
+			filenames = append(filenames, value.Filename)
+		}
+	}
+	if len(filenames) > 1 {
+		sort.Strings(filenames)
+		return fmt.Errorf("variable: %s is declared in multiple files: %s", varname, filenames)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"sort"
+)
+
+// OverwriteStats summarizes how an overwrite config was applied
+type OverwriteStats struct {
+	// UnusedReplacements lists the Replacements keys that did not match any
+	// value, sorted. Keys whose replacement value was already in place count
+	// as used.
+	UnusedReplacements []string `json:"unusedReplacements"`
+
+	// FilesScanned counts the files read to stage the overwrite
+	FilesScanned int `json:"filesScanned"`
+	// FilesChanged counts the files whose contents were changed, or would be
+	// changed if DryRun is set
+	FilesChanged int `json:"filesChanged"`
+
+	// VariablesMatched counts the variables and local values targeted by the
+	// config that were found, and ReplacementsApplied counts the values that
+	// were changed. Both are only counted for Terraform modules.
+	VariablesMatched    int `json:"variablesMatched"`
+	ReplacementsApplied int `json:"replacementsApplied"`
+
+	// NoOpNewValues lists the NewValues entries that did not change a value
+	// because it already equals the new value. Only Terraform modules are
+	// checked.
+	NoOpNewValues []NoOpValue `json:"noOpNewValues"`
+}
+
+// OverwriteTfWithStats is like OverwriteTf but also returns stats about the
+// overwrite
+func OverwriteTfWithStats(config *overwriteConfig, dir string) (*OverwriteStats, error) {
+	return overwriteWithStats(config, dir,
+		func(config *overwriteConfig, dir string) (*OverwriteResult, *ChangeReport, error) {
+			return overwriteTf(context.Background(), config, dir)
+		})
+}
+
+// OverwriteMetadataWithStats is like OverwriteMetadata but also returns stats
+// about the overwrite
+func OverwriteMetadataWithStats(config *overwriteConfig, dir string) (*OverwriteStats, error) {
+	return overwriteWithStats(config, dir, withoutReport(OverwriteMetadata))
+}
+
+// OverwriteDisplayWithStats is like OverwriteDisplay but also returns stats
+// about the overwrite
+func OverwriteDisplayWithStats(config *overwriteConfig, dir string) (*OverwriteStats, error) {
+	return overwriteWithStats(config, dir, withoutReport(OverwriteDisplay))
+}
+
+func withoutReport(overwrite func(*overwriteConfig, string) (*OverwriteResult, error)) func(
+	*overwriteConfig, string) (*OverwriteResult, *ChangeReport, error) {
+	return func(config *overwriteConfig, dir string) (*OverwriteResult, *ChangeReport, error) {
+		result, err := overwrite(config, dir)
+		return result, &ChangeReport{}, err
+	}
+}
+
+func overwriteWithStats(config *overwriteConfig, dir string,
+	overwrite func(*overwriteConfig, string) (*OverwriteResult, *ChangeReport, error)) (*OverwriteStats, error) {
+	// Track used replacements on a copy so the caller's config is not modified
+	statsConfig := *config
+	statsConfig.usedReplacements = map[string]bool{}
+
+	result, report, err := overwrite(&statsConfig, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &OverwriteStats{
+		UnusedReplacements:  []string{},
+		FilesScanned:        len(result.scanned),
+		FilesChanged:        len(result.ChangedFiles()),
+		ReplacementsApplied: len(report.Changes),
+		NoOpNewValues:       report.NoOpNewValues,
+	}
+	matched := map[string]bool{}
+	for _, name := range report.Matched {
+		matched[name] = true
+	}
+	stats.VariablesMatched = len(matched)
+
+	for key := range config.Replacements {
+		if !statsConfig.usedReplacements[key] {
+			stats.UnusedReplacements = append(stats.UnusedReplacements, key)
+		}
+	}
+	sort.Strings(stats.UnusedReplacements)
+	return stats, nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteWithStats(t *testing.T) {
+	testcases := []struct {
+		name            string
+		files           map[string]string
+		overwrite       func(*overwriteConfig, string) (*OverwriteStats, error)
+		overwriteConfig overwriteConfig
+		expectedStats   OverwriteStats
+		errorContains   string
+	}{{
+		name: "Report unused replacements of Terraform module",
+		files: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwrite: OverwriteTfWithStats,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace", "other_value_to_replace"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+				"old-value":      "newer-value",
+				"oldest-value":   "newest-value",
+				"unused-value":   "other-value",
+			},
+		},
+		expectedStats: OverwriteStats{
+			UnusedReplacements:  []string{"oldest-value", "unused-value"},
+			FilesScanned:        1,
+			FilesChanged:        1,
+			VariablesMatched:    2,
+			ReplacementsApplied: 2,
+		},
+	}, {
+		name: "Count files and variables of Terraform module",
+		files: map[string]string{
+			"main.tf":        mainTf,
+			"anyfilename.tf": otherTf,
+		},
+		overwrite: OverwriteTfWithStats,
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"value_to_replace":       "new-value",
+				"other_value_to_replace": "newer-value",
+				"another_variable":       "newest-value",
+			},
+		},
+		expectedStats: OverwriteStats{
+			UnusedReplacements:  []string{},
+			FilesScanned:        2,
+			FilesChanged:        2,
+			VariablesMatched:    3,
+			ReplacementsApplied: 3,
+		},
+	}, {
+		name: "Already applied replacements are used",
+		files: map[string]string{
+			"main.tf": mainTfReplaced,
+		},
+		overwrite: OverwriteTfWithStats,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"value_to_replace", "other_value_to_replace"},
+			Replacements: map[string]string{
+				"original-value": "new-value",
+				"old-value":      "newer-value",
+			},
+		},
+		expectedStats: OverwriteStats{
+			UnusedReplacements: []string{},
+			FilesScanned:       1,
+			VariablesMatched:   2,
+		},
+	}, {
+		name: "Report unused replacements of metadata",
+		files: map[string]string{
+			"metadata.yaml": metadata,
+		},
+		overwrite: OverwriteMetadataWithStats,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"old-image":   "new-image",
+				"older-image": "newer-image",
+			},
+		},
+		expectedStats: OverwriteStats{
+			UnusedReplacements: []string{"older-image"},
+			FilesScanned:       1,
+			FilesChanged:       1,
+		},
+	}, {
+		name: "Report unused replacements of metadata display",
+		files: map[string]string{
+			"metadata.display.yaml": metadataDisplayWithEnumsSingle,
+		},
+		overwrite: OverwriteDisplayWithStats,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/click-to-deploy-images/global/images/wordpress-1": "projects/replacement/global/images/wordpress-1-new",
+				"projects/click-to-deploy-images/global/images/wordpress-9": "projects/replacement/global/images/wordpress-9-new",
+			},
+		},
+		expectedStats: OverwriteStats{
+			UnusedReplacements: []string{"projects/click-to-deploy-images/global/images/wordpress-9"},
+			FilesScanned:       1,
+			FilesChanged:       1,
+		},
+	}, {
+		name: "Fail when overwrite fails",
+		files: map[string]string{
+			"main.tf": mainTf,
+		},
+		overwrite: OverwriteTfWithStats,
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"missing_variable"},
+		},
+		errorContains: "variable: missing_variable not found",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			stats, err := tc.overwrite(&tc.overwriteConfig, tmpDir)
+
+			assert.Nil(t, tc.overwriteConfig.usedReplacements)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, &tc.expectedStats, stats)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
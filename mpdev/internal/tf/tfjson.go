@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// tfJSONExtension is the extension of Terraform files written in the JSON
+// variant of HCL
+const tfJSONExtension = ".tf.json"
+
+func isTfJSONFile(filename string) bool {
+	return strings.HasSuffix(filename, tfJSONExtension)
+}
+
+// overwriteJSONFile sets the default value of a variable in a .tf.json file.
+// Only the default value is rewritten, so the rest of the file is untouched.
+func overwriteJSONFile(result *OverwriteResult, filename string, varname string, value string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+	if !gjson.ValidBytes(b) {
+		return fmt.Errorf("failure parsing %s: invalid JSON", filename)
+	}
+
+	variableQuery, ok := getJSONVariableQuery(b, varname)
+	if !ok {
+		return fmt.Errorf("did not find block with variable: %s", varname)
+	}
+
+	proposed, err := sjson.SetBytes(b, variableQuery+".default", value)
+	if err != nil {
+		return fmt.Errorf("error setting default value of variable: %s. error: %w", varname, err)
+	}
+
+	result.stageFile(filename, b, proposed)
+	return nil
+}
+
+// overwriteJSONCollectionFile replaces the strings within the list or map
+// default value of a variable in a .tf.json file. Each element is set
+// separately, so the formatting of the list or map is untouched.
+func overwriteJSONCollectionFile(result *OverwriteResult, filename string, varname string,
+	replacements map[string]string) error {
+	b, err := result.readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	variableQuery, _ := getJSONVariableQuery(b, varname)
+	defaultQuery := variableQuery + ".default"
+	defaultVal := gjson.GetBytes(b, defaultQuery)
+	if !defaultVal.IsArray() && !defaultVal.IsObject() {
+		return fmt.Errorf("did not find default value of variable: %s", varname)
+	}
+
+	proposed := b
+	index := 0
+	defaultVal.ForEach(func(key, element gjson.Result) bool {
+		elementQuery := fmt.Sprintf("%s.%d", defaultQuery, index)
+		if defaultVal.IsObject() {
+			elementQuery = fmt.Sprintf("%s.%s", defaultQuery, escapeJSONPath(key.String()))
+		}
+		index++
+
+		replaceVal, ok := replacements[element.String()]
+		if !ok {
+			return true
+		}
+		proposed, err = sjson.SetBytes(proposed, elementQuery, replaceVal)
+		return err == nil
+	})
+	if err != nil {
+		return fmt.Errorf("error setting default value of variable: %s. error: %w", varname, err)
+	}
+
+	result.stageFile(filename, b, proposed)
+	return nil
+}
+
+// getJSONVariableQuery returns the gjson path of the block of a variable in a
+// .tf.json file. Both the object form, `"variable": {"name": {...}}`, and the
+// array form, `"variable": [{"name": {...}}]`, are supported, as is a block
+// given as an array of one object.
+func getJSONVariableQuery(b []byte, varname string) (string, bool) {
+	variables := gjson.GetBytes(b, "variable")
+	if !variables.IsArray() {
+		return getJSONBlockQuery(variables, "variable", varname)
+	}
+
+	query := ""
+	ok := false
+	variables.ForEach(func(key, element gjson.Result) bool {
+		query, ok = getJSONBlockQuery(element, fmt.Sprintf("variable.%d", key.Int()), varname)
+		return !ok
+	})
+	return query, ok
+}
+
+// getJSONBlockQuery returns the path of the block labeled name within the
+// object at parentQuery
+func getJSONBlockQuery(parent gjson.Result, parentQuery string, name string) (string, bool) {
+	block := parent.Get(escapeJSONPath(name))
+	query := parentQuery + "." + escapeJSONPath(name)
+	if block.IsObject() {
+		return query, true
+	}
+	if elements := block.Array(); block.IsArray() && len(elements) == 1 && elements[0].IsObject() {
+		return query + ".0", true
+	}
+	return "", false
+}
+
+// hasJSONGoogleProvider returns whether a .tf.json file declares a
+// `provider "google"` block, in either the object or the array form
+func hasJSONGoogleProvider(b []byte) bool {
+	providers := gjson.GetBytes(b, "provider")
+	if !providers.IsArray() {
+		return providers.Get("google").Exists()
+	}
+
+	found := false
+	providers.ForEach(func(_, element gjson.Result) bool {
+		found = element.Get("google").Exists()
+		return !found
+	})
+	return found
+}
+
+// escapeJSONPath escapes the characters with a special meaning in gjson and
+// sjson paths
+func escapeJSONPath(key string) string {
+	var escaped strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`\.*?|#@!=<>%`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,289 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const tfvarsExtension = ".tfvars"
+
+// tfvarsJSONExtension is the extension of tfvars files written in JSON
+const tfvarsJSONExtension = ".tfvars.json"
+
+func isTfvarsFile(filename string) bool {
+	return strings.HasSuffix(filename, tfvarsExtension) || strings.HasSuffix(filename, tfvarsJSONExtension)
+}
+
+// tfvarsValue is a variable assignment in a .tfvars file. Value is nil unless
+// the assignment is a string literal.
+type tfvarsValue struct {
+	Name     string
+	Filename string
+	Value    interface{}
+}
+
+// OverwriteTfvars replaces the values assigned to variables in the .tfvars and
+// .auto.tfvars files of dir, and of its nested modules if Recursive is set.
+// Assignments are matched by variable name if NewValues is set and otherwise by
+// their current value using Replacements. The JSON variants of the files, such
+// as terraform.tfvars.json, are overwritten alike. Only the values in a JSON
+// file are rewritten, so the rest of the file keeps its formatting.
+func OverwriteTfvars(config *overwriteConfig, dir string) error {
+	err := config.runPreHook(dir)
+	if err != nil {
+		return err
+	}
+
+	result := newOverwriteResult()
+	err = stageTfvars(result, config, dir)
+	if err != nil {
+		return err
+	}
+
+	return result.commit(config, dir)
+}
+
+func stageTfvars(result *OverwriteResult, config *overwriteConfig, dir string) error {
+	config, err := config.withVariablesManifest(result, dir)
+	if err != nil {
+		return err
+	}
+
+	moduleDirs, err := getModuleDirs(result.fsys, dir, config.Recursive)
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for _, moduleDir := range moduleDirs {
+		entries, err := result.fsys.ReadDir(moduleDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isTfvarsFile(entry.Name()) {
+				filenames = append(filenames, path.Join(moduleDir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(filenames)
+
+	assignments, err := getTfvarsValues(result, filenames)
+	if err != nil {
+		return err
+	}
+
+	if config.NewValues != nil {
+		fmt.Printf("Replacing the tfvars values of the variables: %s\n", config.NewValues)
+
+		for _, varName := range sortedKeys(config.NewValues) {
+			values, err := findTfvarsValues(assignments, varName, moduleDirs)
+			if err != nil {
+				return err
+			}
+
+			for _, value := range values {
+				if value.Value == nil {
+					return newVariableError(ErrWrongType, varName,
+						"image tfvars value: %s must be type string", varName)
+				}
+				err = overwriteTfvarsFile(result, value, config.NewValues[varName])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	fmt.Printf("Replacing the tfvars values of the variables: %s\n", config.Variables)
+
+	var names []string
+	for _, assignment := range assignments {
+		names = append(names, assignment.Name)
+	}
+	variables, err := expandVariablePatterns(config.Variables, names, "tfvars files")
+	if err != nil {
+		return err
+	}
+
+	for _, varname := range variables {
+		values, err := findTfvarsValues(assignments, varname, moduleDirs)
+		if err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			currValue, ok := value.Value.(string)
+			if !ok {
+				return newVariableError(ErrWrongType, varname,
+					"image tfvars value: %s must be type string", varname)
+			}
+
+			replaceVal, ok, err := config.replacementFor(varname, currValue)
+			if err != nil {
+				return err
+			}
+			if !ok && config.isReplacementTarget(currValue) {
+				// Already replaced by a previous run
+				continue
+			}
+			if !ok {
+				return newVariableError(ErrReplacementNotFound, varname,
+					"tfvars value: %s of variable: %s not found in replacements",
+					currValue, varname)
+			}
+
+			err = overwriteTfvarsFile(result, value, replaceVal)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getTfvarsValues returns the variable assignments in the tfvars files
+func getTfvarsValues(result *OverwriteResult, filenames []string) ([]*tfvarsValue, error) {
+	var values []*tfvarsValue
+	for _, filename := range filenames {
+		b, err := result.readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(filename, tfvarsJSONExtension) {
+			jsonValues, err := getTfvarsJSONValues(b, filename)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, jsonValues...)
+			continue
+		}
+
+		file, diag := hclsyntax.ParseConfig(b, filename, hcl.Pos{Line: 1, Column: 1})
+		if diag.HasErrors() {
+			return nil, fmt.Errorf("failure parsing tfvars file: %w", newParseError(diag))
+		}
+
+		attributes := file.Body.(*hclsyntax.Body).Attributes
+		for _, name := range sortedAttributeNames(attributes) {
+			value := &tfvarsValue{Name: name, Filename: filename}
+			ctyValue, diag := attributes[name].Expr.Value(nil)
+			if !diag.HasErrors() && ctyValue.Type() == cty.String && ctyValue.IsKnown() && !ctyValue.IsNull() {
+				value.Value = ctyValue.AsString()
+			}
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// getTfvarsJSONValues returns the variable assignments in a .tfvars.json file,
+// which holds a single object keyed by variable name
+func getTfvarsJSONValues(b []byte, filename string) ([]*tfvarsValue, error) {
+	if !gjson.ValidBytes(b) || !gjson.ParseBytes(b).IsObject() {
+		return nil, fmt.Errorf("failure parsing tfvars file: %s: must be a JSON object", filename)
+	}
+
+	assignments := gjson.ParseBytes(b).Map()
+	names := make([]string, 0, len(assignments))
+	for name := range assignments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var values []*tfvarsValue
+	for _, name := range names {
+		value := &tfvarsValue{Name: name, Filename: filename}
+		if assignments[name].Type == gjson.String {
+			value.Value = assignments[name].String()
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func sortedAttributeNames(attributes hclsyntax.Attributes) []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findTfvarsValues returns the assignments of varname
+func findTfvarsValues(assignments []*tfvarsValue, varname string, dirs []string) ([]*tfvarsValue, error) {
+	var values []*tfvarsValue
+	for _, assignment := range assignments {
+		if assignment.Name == varname {
+			values = append(values, assignment)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, newVariableError(ErrVariableNotFound, varname,
+			"variable: %s not found in tfvars files. Searched directories: %s",
+			varname, dirs)
+	}
+	return values, nil
+}
+
+// overwriteTfvarsFile sets the value assigned to a variable in a tfvars file.
+// Values that already equal the new value are skipped.
+func overwriteTfvarsFile(result *OverwriteResult, value *tfvarsValue, newValue string) error {
+	if value.Value == newValue {
+		return nil
+	}
+
+	b, err := result.readFile(value.Filename)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(value.Filename, tfvarsJSONExtension) {
+		proposed, err := sjson.SetBytes(b, escapeJSONPath(value.Name), newValue)
+		if err != nil {
+			return fmt.Errorf("failure overwriting tfvars value: %s error: %w", value.Name, err)
+		}
+		result.stageFile(value.Filename, b, proposed)
+		value.Value = newValue
+		return nil
+	}
+
+	file, diag := hclwrite.ParseConfig(b, value.Filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	_, err = setStringAttribute(file.Body(), value.Name, newValue)
+	if err != nil {
+		return fmt.Errorf("failure overwriting tfvars value: %s error: %w", value.Name, err)
+	}
+
+	result.stageFile(value.Filename, b, file.BuildTokens(nil).Bytes())
+	value.Value = newValue
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteTfvars(t *testing.T) {
+	testcases := []struct {
+		name            string
+		files           map[string]string
+		expectedFiles   map[string]string
+		overwriteConfig overwriteConfig
+		errorContains   string
+	}{{
+		name: "Overwrite tfvars values by replacement",
+		files: map[string]string{
+			"terraform.tfvars": tfvars,
+			"prod.auto.tfvars": autoTfvars,
+		},
+		expectedFiles: map[string]string{
+			"terraform.tfvars": tfvarsReplaced,
+			"prod.auto.tfvars": autoTfvarsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image", "*_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/web-1": "projects/mpi/global/images/web-1",
+				"projects/partner/global/images/db-1":  "projects/mpi/global/images/db-1",
+			},
+		},
+	}, {
+		name: "Re-running overwrite on replaced tfvars values is a no-op",
+		files: map[string]string{
+			"terraform.tfvars": tfvarsReplaced,
+		},
+		expectedFiles: map[string]string{
+			"terraform.tfvars": tfvarsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/web-1": "projects/mpi/global/images/web-1",
+			},
+		},
+	}, {
+		name: "Overwrite tfvars values by variable name",
+		files: map[string]string{
+			"terraform.tfvars": tfvars,
+			"prod.auto.tfvars": autoTfvars,
+		},
+		expectedFiles: map[string]string{
+			"terraform.tfvars": tfvarsReplaced,
+			"prod.auto.tfvars": autoTfvarsReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"source_image": "projects/mpi/global/images/web-1",
+				"db_image":     "projects/mpi/global/images/db-1",
+			},
+		},
+	}, {
+		name: "Overwrite tfvars and tfvars.json values in one call",
+		files: map[string]string{
+			"terraform.tfvars":      tfvars,
+			"terraform.tfvars.json": tfvarsJSON,
+		},
+		expectedFiles: map[string]string{
+			"terraform.tfvars":      tfvarsReplaced,
+			"terraform.tfvars.json": tfvarsJSONReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"*_image"},
+			Replacements: map[string]string{
+				"projects/partner/global/images/web-1": "projects/mpi/global/images/web-1",
+				"projects/partner/global/images/db-1":  "projects/mpi/global/images/db-1",
+			},
+		},
+	}, {
+		name: "Overwrite tfvars.json values by variable name",
+		files: map[string]string{
+			"terraform.tfvars.json": tfvarsJSON,
+		},
+		expectedFiles: map[string]string{
+			"terraform.tfvars.json": tfvarsJSONReplaced,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"db_image": "projects/mpi/global/images/db-1",
+			},
+		},
+	}, {
+		name: "Fail when tfvars.json value is not a string",
+		files: map[string]string{
+			"terraform.tfvars.json": tfvarsJSON,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"zones": "us-east1-b",
+			},
+		},
+		errorContains: "image tfvars value: zones must be type string",
+	}, {
+		name: "Invalid tfvars.json shows parsing error",
+		files: map[string]string{
+			"terraform.tfvars.json": `["db_image"]`,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"db_image"},
+		},
+		errorContains: "failure parsing tfvars file: ",
+	}, {
+		name: "Fail when variable is not assigned in tfvars files",
+		files: map[string]string{
+			"terraform.tfvars": tfvars,
+		},
+		overwriteConfig: overwriteConfig{
+			NewValues: map[string]string{
+				"missing_image": "new-image",
+			},
+		},
+		errorContains: "variable: missing_image not found in tfvars files",
+	}, {
+		name: "Fail when tfvars value is not a string",
+		files: map[string]string{
+			"terraform.tfvars": tfvars,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables:    []string{"machine_count"},
+			Replacements: map[string]string{},
+		},
+		errorContains: "image tfvars value: machine_count must be type string",
+	}, {
+		name: "Fail when tfvars value is not in replacements",
+		files: map[string]string{
+			"terraform.tfvars": tfvars,
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+			Replacements: map[string]string{
+				"non-existent": "new-value",
+			},
+		},
+		errorContains: "tfvars value: projects/partner/global/images/web-1 of variable: source_image not found in replacements",
+	}, {
+		name: "Invalid tfvars shows parsing error",
+		files: map[string]string{
+			"terraform.tfvars": "this is broken",
+		},
+		overwriteConfig: overwriteConfig{
+			Variables: []string{"source_image"},
+		},
+		errorContains: "failure parsing tfvars file",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			err = OverwriteTfvars(&tc.overwriteConfig, tmpDir)
+
+			actualFiles, dirErr := getDirContents(tmpDir)
+			assert.NoError(t, dirErr)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedFiles, actualFiles)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+				assert.Equal(t, tc.files, actualFiles)
+			}
+		})
+	}
+}
+
+var tfvars string = `# Images used by the deployment
+source_image  = "projects/partner/global/images/web-1"
+machine_count = 2
+`
+
+var tfvarsReplaced string = `# Images used by the deployment
+source_image  = "projects/mpi/global/images/web-1"
+machine_count = 2
+`
+
+var tfvarsJSON string = `{
+  "db_image": "projects/partner/global/images/db-1",
+  "zones": ["us-east1-b"]
+}
+`
+
+var tfvarsJSONReplaced string = `{
+  "db_image": "projects/mpi/global/images/db-1",
+  "zones": ["us-east1-b"]
+}
+`
+
+var autoTfvars string = `db_image = "projects/partner/global/images/db-1" # pinned
+`
+
+var autoTfvarsReplaced string = `db_image = "projects/mpi/global/images/db-1" # pinned
+`
@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import "fmt"
+
+// TransformFunc returns the new value of a candidate value of the named
+// variable, output or key path. Returning oldValue leaves the value as is, and
+// returning an error aborts the overwrite.
+type TransformFunc func(varName string, oldValue string) (string, error)
+
+// transformFor returns the value returned by the transform for a value of the
+// named variable. ok is false if the transform returned the value unchanged.
+func (config *overwriteConfig) transformFor(name string, value string) (string, bool, error) {
+	newValue, err := config.transform(name, value)
+	if err != nil {
+		return "", false, fmt.Errorf("failure transforming value: %s of: %s error: %w", value, name, err)
+	}
+	return newValue, newValue != value, nil
+}
+
+// withTransform returns a copy of config using transform in place of
+// Replacements, Digests, ProjectRemap and RegexReplacements
+func (config *overwriteConfig) withTransform(transform TransformFunc) *overwriteConfig {
+	transformConfig := *config
+	transformConfig.transform = transform
+	return &transformConfig
+}
+
+// OverwriteTfWithTransform is like OverwriteTf but replaces each candidate value
+// with the value returned by transform instead of using the static replacements
+func OverwriteTfWithTransform(config *overwriteConfig, dir string, transform TransformFunc) (*OverwriteResult, error) {
+	return OverwriteTf(config.withTransform(transform), dir)
+}
+
+// OverwriteMetadataWithTransform is like OverwriteMetadata but replaces each
+// candidate value with the value returned by transform
+func OverwriteMetadataWithTransform(config *overwriteConfig, dir string,
+	transform TransformFunc) (*OverwriteResult, error) {
+	return OverwriteMetadata(config.withTransform(transform), dir)
+}
+
+// OverwriteDisplayWithTransform is like OverwriteDisplay but replaces each
+// candidate value with the value returned by transform
+func OverwriteDisplayWithTransform(config *overwriteConfig, dir string,
+	transform TransformFunc) (*OverwriteResult, error) {
+	return OverwriteDisplay(config.withTransform(transform), dir)
+}
+
+// OverwriteAllWithTransform is like OverwriteAll but replaces each candidate
+// value with the value returned by transform
+func OverwriteAllWithTransform(config *overwriteConfig, dir string, transform TransformFunc) (*OverwriteResult, error) {
+	return OverwriteAll(config.withTransform(transform), dir)
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteAllWithTransform(t *testing.T) {
+	files := map[string]string{
+		"main.tf": mainTf,
+		"metadata.yaml": `spec:
+  interfaces:
+    variables:
+    - name: value_to_replace
+      varType: string
+      defaultValue: original-value
+    - name: other_value_to_replace
+      varType: string
+      defaultValue: old-value
+`,
+		"metadata.display.yaml": `spec:
+  ui:
+    input:
+      variables:
+        other_value_to_replace:
+          name: other_value_to_replace
+          title: Other Value
+        value_to_replace:
+          enumValueLabels:
+          - label: Original
+            value: original-value
+          - label: Other
+            value: other-value
+          name: value_to_replace
+          title: Value
+`,
+	}
+
+	testcases := []struct {
+		name          string
+		transform     TransformFunc
+		expectedFiles map[string]string
+		errorContains string
+	}{{
+		name: "Transform values of every file type",
+		transform: func(varName string, oldValue string) (string, error) {
+			if oldValue == "other-value" {
+				return oldValue, nil
+			}
+			return strings.ToUpper(varName) + "/" + oldValue, nil
+		},
+		expectedFiles: map[string]string{
+			"main.tf": strings.NewReplacer(
+				`"original-value"`, `"VALUE_TO_REPLACE/original-value"`,
+				`"old-value"`, `"OTHER_VALUE_TO_REPLACE/old-value"`).Replace(mainTf),
+			"metadata.yaml": `spec:
+  interfaces:
+    variables:
+    - name: value_to_replace
+      varType: string
+      defaultValue: VALUE_TO_REPLACE/original-value
+    - name: other_value_to_replace
+      varType: string
+      defaultValue: OTHER_VALUE_TO_REPLACE/old-value
+`,
+			"metadata.display.yaml": `spec:
+  ui:
+    input:
+      variables:
+        other_value_to_replace:
+          name: other_value_to_replace
+          title: Other Value
+        value_to_replace:
+          enumValueLabels:
+          - label: Original
+            value: VALUE_TO_REPLACE/original-value
+          - label: Other
+            value: other-value
+          name: value_to_replace
+          title: Value
+`,
+		},
+	}, {
+		name: "Fail when transform fails and do not write files",
+		transform: func(varName string, oldValue string) (string, error) {
+			if varName == "other_value_to_replace" {
+				return "", errors.New("no release for value")
+			}
+			return "new-value", nil
+		},
+		expectedFiles: files,
+		errorContains: "failure transforming value: old-value of: other_value_to_replace error: no release for value",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			config := &overwriteConfig{
+				Variables: []string{"value_to_replace", "other_value_to_replace"},
+				Replacements: map[string]string{
+					"original-value": "unused-value",
+				},
+			}
+			_, err = OverwriteAllWithTransform(config, tmpDir, tc.transform)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+			assert.Nil(t, config.transform)
+
+			actualContents, err := getDirContents(tmpDir)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, actualContents)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// validationFunctions are the Terraform functions available to validation
+// conditions. Conditions calling other functions are skipped.
+var validationFunctions = map[string]function.Function{
+	"can":      tryfunc.CanFunc,
+	"contains": stdlib.ContainsFunc,
+	"length":   stdlib.LengthFunc,
+	"lower":    stdlib.LowerFunc,
+	"regex":    stdlib.RegexFunc,
+	"regexall": stdlib.RegexAllFunc,
+	"substr":   stdlib.SubstrFunc,
+	"try":      tryfunc.TryFunc,
+	"upper":    stdlib.UpperFunc,
+}
+
+// checkValidations evaluates the conditions of the validation blocks of a
+// variable against its new default, if CheckValidations is set. A condition
+// that fails is an error. Conditions that cannot be evaluated without the rest
+// of the module are skipped with a warning.
+func checkValidations(result *OverwriteResult, config *overwriteConfig, moduleVal *moduleValue,
+	value string) error {
+	if !config.CheckValidations || moduleVal.Local || isTfJSONFile(moduleVal.Filename) {
+		return nil
+	}
+
+	b, err := result.readFile(moduleVal.Filename)
+	if err != nil {
+		return err
+	}
+	file, diag := hclsyntax.ParseConfig(b, moduleVal.Filename, hcl.Pos{Line: 1, Column: 1})
+	if diag.HasErrors() {
+		return newParseError(diag)
+	}
+
+	var variableBlock *hclsyntax.Block
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type == "variable" && len(block.Labels) == 1 && block.Labels[0] == moduleVal.Name {
+			variableBlock = block
+			break
+		}
+	}
+	if variableBlock == nil {
+		return nil
+	}
+
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{moduleVal.Name: cty.StringVal(value)}),
+		},
+		Functions: validationFunctions,
+	}
+	for _, block := range variableBlock.Body.Blocks {
+		condition, ok := block.Body.Attributes["condition"]
+		if block.Type != "validation" || !ok {
+			continue
+		}
+
+		conditionVal, diag := condition.Expr.Value(evalContext)
+		if diag.HasErrors() || conditionVal.Type() != cty.Bool || !conditionVal.IsKnown() ||
+			conditionVal.IsNull() {
+			fmt.Printf("Warning: skipping validation of variable: %s in %s. Its condition cannot be evaluated\n",
+				moduleVal.Name, moduleVal.Filename)
+			continue
+		}
+		if conditionVal.True() {
+			continue
+		}
+
+		message := "condition failed"
+		if errorMessage, ok := block.Body.Attributes["error_message"]; ok {
+			messageVal, diag := errorMessage.Expr.Value(nil)
+			if !diag.HasErrors() && messageVal.Type() == cty.String && !messageVal.IsNull() {
+				message = messageVal.AsString()
+			}
+		}
+		return newVariableError(ErrValidationFailed, moduleVal.Name,
+			"new default: %s of variable: %s fails validation: %s", value, moduleVal.Name, message)
+	}
+	return nil
+}
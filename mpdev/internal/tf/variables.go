@@ -0,0 +1,59 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// ModuleVariable is a variable declared in a Terraform module
+type ModuleVariable struct {
+	Name     string      `json:"name"`
+	Filename string      `json:"filename"`
+	Type     string      `json:"type,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+
+	// HasDefault is false if the variable is required. A variable with a
+	// `null` default has a default but a nil Default.
+	HasDefault bool `json:"hasDefault"`
+}
+
+// ParseModuleVariables returns the variables declared in the Terraform module
+// in dir, sorted by name
+func ParseModuleVariables(dir string) ([]ModuleVariable, error) {
+	module, diag := tfconfig.LoadModule(dir)
+	if diag.HasErrors() {
+		return nil, fmt.Errorf("failure parsing terraform module: %w", newModuleParseError(diag))
+	}
+
+	var variables []ModuleVariable
+	for _, variable := range module.Variables {
+		variables = append(variables, ModuleVariable{
+			Name:       variable.Name,
+			Filename:   variable.Pos.Filename,
+			Type:       variable.Type,
+			Default:    variable.Default,
+			HasDefault: !variable.Required,
+		})
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variables, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseModuleVariables(t *testing.T) {
+	testcases := []struct {
+		name              string
+		tfFiles           map[string]string
+		expectedVariables []ModuleVariable
+		errorContains     string
+	}{{
+		name: "Parse variables with and without defaults",
+		tfFiles: map[string]string{
+			"main.tf":        tfNoDefault,
+			"anyfilename.tf": otherTf,
+		},
+		expectedVariables: []ModuleVariable{{
+			Name:       "another_variable",
+			Filename:   "anyfilename.tf",
+			Type:       "string",
+			Default:    "oldest-value",
+			HasDefault: true,
+		}, {
+			Name:     "value_to_replace",
+			Filename: "main.tf",
+			Type:     "string",
+		}},
+	}, {
+		name: "Parse variable with non-string default",
+		tfFiles: map[string]string{
+			"main.tf": tfDefaultWrongType,
+		},
+		expectedVariables: []ModuleVariable{{
+			Name:     "value_to_replace",
+			Filename: "main.tf",
+			Type:     "map(number)",
+			Default: map[string]interface{}{
+				"foo": float64(2),
+				"bar": float64(4),
+			},
+			HasDefault: true,
+		}},
+	}, {
+		name: "Invalid HCL shows parsing error",
+		tfFiles: map[string]string{
+			"main.tf": "variable {",
+		},
+		errorContains: "failure parsing terraform module",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.tfFiles {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			variables, err := ParseModuleVariables(tmpDir)
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				for i := range tc.expectedVariables {
+					tc.expectedVariables[i].Filename = path.Join(tmpDir, tc.expectedVariables[i].Filename)
+				}
+				assert.Equal(t, tc.expectedVariables, variables)
+			} else {
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"sigs.k8s.io/yaml"
+)
+
+// Finding is a placeholder found in the value of a variable
+type Finding struct {
+	File        string `json:"file"`
+	Variable    string `json:"variable"`
+	Value       string `json:"value"`
+	Placeholder string `json:"placeholder"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("variable: %s in %s has value: %s containing placeholder: %s",
+		f.Variable, f.File, f.Value, f.Placeholder)
+}
+
+// VerifyNoPlaceholders scans the variable defaults of the Terraform module, the
+// defaultValues of Blueprints Metadata and the enum values of the Blueprints
+// Metadata display file in dir for any of the placeholder substrings. Missing
+// metadata files are ignored.
+func VerifyNoPlaceholders(dir string, placeholders []string) ([]Finding, error) {
+	var findings []Finding
+
+	variables, err := ParseModuleVariables(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, variable := range variables {
+		findings = appendFindings(findings, variable.Filename, variable.Name,
+			variable.Default, placeholders)
+	}
+
+	metadataFullPath := path.Join(dir, metadataFile)
+	data, err := os.ReadFile(metadataFullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		metadataVariables, err := getMetadataVariables(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, metadataVar := range metadataVariables {
+			findings = appendFindings(findings, metadataFullPath, metadataVar.Name,
+				metadataVar.DefaultValue, placeholders)
+		}
+	}
+
+	displayFullPath := path.Join(dir, metadataDisplayFile)
+	data, err = os.ReadFile(displayFullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		json, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failure parsing %s error: %w", metadataDisplayFile, err)
+		}
+
+		var displayFindings []Finding
+		gjson.GetBytes(json, "spec.ui.input.variables").ForEach(func(key, variableInfo gjson.Result) bool {
+			for _, enumValueLabel := range variableInfo.Get("enumValueLabels").Array() {
+				displayFindings = appendFindings(displayFindings, displayFullPath, key.String(),
+					enumValueLabel.Get("value").Value(), placeholders)
+			}
+			return true
+		})
+		sort.SliceStable(displayFindings, func(i, j int) bool {
+			return displayFindings[i].Variable < displayFindings[j].Variable
+		})
+		findings = append(findings, displayFindings...)
+	}
+
+	return findings, nil
+}
+
+// appendFindings appends a finding for each string nested in value that
+// contains one of the placeholders
+func appendFindings(findings []Finding, filename string, varname string,
+	value interface{}, placeholders []string) []Finding {
+	switch v := value.(type) {
+	case string:
+		for _, placeholder := range placeholders {
+			if placeholder != "" && strings.Contains(v, placeholder) {
+				findings = append(findings, Finding{
+					File:        filename,
+					Variable:    varname,
+					Value:       v,
+					Placeholder: placeholder,
+				})
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			findings = appendFindings(findings, filename, varname, v[key], placeholders)
+		}
+	case []interface{}:
+		for _, element := range v {
+			findings = appendFindings(findings, filename, varname, element, placeholders)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tf
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyNoPlaceholders(t *testing.T) {
+	testcases := []struct {
+		name             string
+		files            map[string]string
+		placeholders     []string
+		expectedFindings []Finding
+		errorContains    string
+	}{{
+		name: "No findings in overwritten module",
+		files: map[string]string{
+			"main.tf":               mainTfReplaced,
+			"metadata.yaml":         metadataReplaced,
+			"metadata.display.yaml": metadataDisplayWithEnumsDoubleReplaced,
+		},
+		placeholders: []string{"original-value", "old-image", "click-to-deploy-images"},
+	}, {
+		name: "No findings without metadata",
+		files: map[string]string{
+			"main.tf": mainTfReplaced,
+		},
+		placeholders: []string{"original-value"},
+	}, {
+		name: "Report placeholders in all file types",
+		files: map[string]string{
+			"main.tf":               mainTf,
+			"metadata.yaml":         metadata,
+			"metadata.display.yaml": metadataDisplayWithEnumsDouble,
+		},
+		placeholders: []string{"original-value", "old-image", "wordpress-2"},
+		expectedFindings: []Finding{{
+			File:        "main.tf",
+			Variable:    "value_to_replace",
+			Value:       "original-value",
+			Placeholder: "original-value",
+		}, {
+			File:        "metadata.yaml",
+			Variable:    "source_image",
+			Value:       "old-image",
+			Placeholder: "old-image",
+		}, {
+			File:        "metadata.display.yaml",
+			Variable:    "source_image",
+			Value:       "projects/click-to-deploy-images/global/images/wordpress-2",
+			Placeholder: "wordpress-2",
+		}},
+	}, {
+		name: "Report placeholders in collection defaults",
+		files: map[string]string{
+			"main.tf": tfCollections,
+		},
+		placeholders: []string{"old-image-b"},
+		expectedFindings: []Finding{{
+			File:        "main.tf",
+			Variable:    "images",
+			Value:       "old-image-b",
+			Placeholder: "old-image-b",
+		}, {
+			File:        "main.tf",
+			Variable:    "images_by_zone",
+			Value:       "old-image-b",
+			Placeholder: "old-image-b",
+		}},
+	}, {
+		name: "Invalid display shows parsing error",
+		files: map[string]string{
+			"main.tf":               mainTf,
+			"metadata.display.yaml": "- not validyaml\ninvalid-",
+		},
+		errorContains: "failure parsing metadata.display.yaml",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "tftest")
+			assert.NoError(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			for file, content := range tc.files {
+				err = os.WriteFile(path.Join(tmpDir, file), []byte(content), 0600)
+				assert.NoError(t, err)
+			}
+
+			findings, err := VerifyNoPlaceholders(tmpDir, tc.placeholders)
+
+			if tc.errorContains == "" {
+				assert.NoError(t, err)
+				var expectedFindings []Finding
+				for _, finding := range tc.expectedFindings {
+					finding.File = path.Join(tmpDir, finding.File)
+					expectedFindings = append(expectedFindings, finding)
+				}
+				assert.Equal(t, expectedFindings, findings)
+			} else {
+				assert.Error(t, err)
+				assert.ErrorContains(t, err, tc.errorContains)
+			}
+		})
+	}
+}
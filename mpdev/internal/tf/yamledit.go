@@ -0,0 +1,545 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setMetadataVariableField sets a field of the entry for a variable under
+// spec.interfaces.variables in Blueprints Metadata.
+//
+// Where possible, only the bytes of the field's value are replaced, or a line
+// is added for a new field, so that comments, key order, and formatting in
+// the rest of the file are untouched. Values that span multiple lines are
+// instead set on the parsed document, which is re-encoded with its comments
+// and key order.
+func setMetadataVariableField(data []byte, varName string, field string, value interface{}) ([]byte, error) {
+	return setMetadataEntryField(data, "variables", varName, field, value)
+}
+
+// setMetadataEntryField sets a field of the entry with the given name in a
+// list under spec.interfaces, such as variables or outputs, in the same way as
+// setMetadataVariableField
+func setMetadataEntryField(data []byte, interfaceKey string, name string, field string,
+	value interface{}) ([]byte, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing %s error: %w", metadataFile, err)
+	}
+
+	entry := findMetadataEntryNode(&root, interfaceKey, name)
+	if entry == nil {
+		return nil, fmt.Errorf("missing %s entry: %s in %s", interfaceKey, name, metadataFile)
+	}
+
+	valueNode, err := encodeYAMLValue(value)
+	if err != nil {
+		return nil, err
+	}
+	inlineValue, err := marshalInlineYAML(valueNode)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := getMappingValue(entry, field)
+	if existing != nil {
+		start, end, ok := getInlineSpan(data, existing)
+		if ok {
+			return spliceBytes(data, start, end, []byte(inlineValue)), nil
+		}
+	} else if end, ok := getMappingEnd(data, entry); ok {
+		indent := strings.Repeat(" ", entry.Content[0].Column-1)
+		line := fmt.Sprintf("%s%s: %s\n", indent, field, inlineValue)
+		return insertLineAfter(data, end, line), nil
+	}
+
+	// Fall back to editing the parsed document
+	valueNode.Style = 0
+	if existing != nil {
+		*existing = *valueNode
+	} else {
+		entry.Content = append(entry.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field}, valueNode)
+	}
+
+	return encodeYAMLDocument(&root)
+}
+
+// addMetadataVariable appends an entry for a string variable with a default
+// value to spec.interfaces.variables in Blueprints Metadata. As with
+// setMetadataVariableField, the rest of the file is left untouched.
+func addMetadataVariable(data []byte, varName string, value string) ([]byte, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("failure parsing %s error: %w", metadataFile, err)
+	}
+
+	variables := findMetadataVariablesNode(&root)
+	if variables == nil {
+		return nil, fmt.Errorf("missing spec.interfaces.variables in %s", metadataFile)
+	}
+
+	entry := &yaml.Node{Kind: yaml.MappingNode}
+	for _, field := range [][2]string{{"name", varName}, {"varType", "string"}, {"defaultValue", value}} {
+		entry.Content = append(entry.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field[0]},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field[1]})
+	}
+
+	if len(variables.Content) > 0 && variables.Style&yaml.FlowStyle == 0 {
+		last := variables.Content[len(variables.Content)-1]
+		if end, ok := getMappingEnd(data, last); ok {
+			lastStart := getOffset(data, last.Line, last.Column)
+			dashStart, lineStart := -1, 0
+			if lastStart >= 0 {
+				dashStart = bytes.LastIndexByte(data[:lastStart], '-')
+				lineStart = bytes.LastIndexByte(data[:lastStart], '\n') + 1
+			}
+			if dashStart >= lineStart && lastStart > dashStart {
+				dashIndent := strings.Repeat(" ", dashStart-lineStart)
+				keyIndent := strings.Repeat(" ", lastStart-lineStart)
+
+				var lines strings.Builder
+				for i := 0; i+1 < len(entry.Content); i += 2 {
+					inlineValue, err := marshalInlineYAML(entry.Content[i+1])
+					if err != nil {
+						return nil, err
+					}
+					indent := keyIndent
+					if i == 0 {
+						indent = dashIndent + "-" + keyIndent[len(dashIndent)+1:]
+					}
+					lines.WriteString(fmt.Sprintf("%s%s: %s\n", indent, entry.Content[i].Value, inlineValue))
+				}
+				return insertLineAfter(data, end, lines.String()), nil
+			}
+		}
+	}
+
+	// Fall back to editing the parsed document
+	variables.Content = append(variables.Content, entry)
+	return encodeYAMLDocument(&root)
+}
+
+// removeMetadataVariableField removes a field from every entry under
+// spec.interfaces.variables in Blueprints Metadata, and returns whether any
+// entry had the field. As with setMetadataVariableField, only the lines of the
+// removed fields are deleted where possible. If a field is not on a line of its
+// own, such as the first field of an entry, the parsed document is instead
+// re-encoded with its comments and key order.
+func removeMetadataVariableField(data []byte, field string) ([]byte, bool, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, false, fmt.Errorf("failure parsing %s error: %w", metadataFile, err)
+	}
+
+	variables := findMetadataVariablesNode(&root)
+	if variables == nil {
+		return data, false, nil
+	}
+
+	// spans are the byte offsets of the lines of the fields in reverse order
+	var spans [][2]int
+	inline := true
+	for _, entry := range variables.Content {
+		if entry.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(entry.Content); i += 2 {
+			if entry.Content[i].Value != field {
+				continue
+			}
+			start, end, ok := getFieldLineSpan(data, entry, entry.Content[i], entry.Content[i+1])
+			if !ok {
+				inline = false
+			}
+			spans = append([][2]int{{start, end}}, spans...)
+			entry.Content = append(entry.Content[:i:i], entry.Content[i+2:]...)
+			break
+		}
+	}
+	if len(spans) == 0 {
+		return data, false, nil
+	}
+
+	if !inline {
+		// Fall back to re-encoding the parsed document
+		b, err := encodeYAMLDocument(&root)
+		return b, true, err
+	}
+	for _, span := range spans {
+		data = spliceBytes(data, span[0], span[1], nil)
+	}
+	return data, true, nil
+}
+
+// getFieldLineSpan returns the byte offsets of the line of a field of a block
+// mapping, including its line break. ok is false if the line holds anything but
+// the field, such as the dash of a list entry, or if the value spans multiple
+// lines.
+func getFieldLineSpan(data []byte, mapping *yaml.Node, key *yaml.Node, value *yaml.Node) (int, int, bool) {
+	if mapping.Style&yaml.FlowStyle != 0 || key.Line != value.Line {
+		return 0, 0, false
+	}
+	_, valueEnd, ok := getInlineSpan(data, value)
+	if !ok {
+		return 0, 0, false
+	}
+
+	keyStart := getOffset(data, key.Line, key.Column)
+	if keyStart < 0 {
+		return 0, 0, false
+	}
+	lineStart := bytes.LastIndexByte(data[:keyStart], '\n') + 1
+	if strings.TrimSpace(string(data[lineStart:keyStart])) != "" {
+		return 0, 0, false
+	}
+	lineEnd := bytes.IndexByte(data[valueEnd:], '\n')
+	if lineEnd == -1 {
+		return lineStart, len(data), true
+	}
+	return lineStart, valueEnd + lineEnd + 1, true
+}
+
+// getMappingEnd returns the offset of the end of the last value in a block
+// mapping, if that value is written on a single line
+func getMappingEnd(data []byte, mapping *yaml.Node) (int, bool) {
+	if mapping.Kind != yaml.MappingNode || mapping.Style&yaml.FlowStyle != 0 || len(mapping.Content) == 0 {
+		return 0, false
+	}
+	_, end, ok := getInlineSpan(data, mapping.Content[len(mapping.Content)-1])
+	return end, ok
+}
+
+// insertLineAfter inserts lines after the line containing offset
+func insertLineAfter(data []byte, offset int, lines string) []byte {
+	insertAt := bytes.IndexByte(data[offset:], '\n')
+	if insertAt == -1 {
+		return append(append(append([]byte{}, data...), '\n'), lines...)
+	}
+	return spliceBytes(data, offset+insertAt+1, offset+insertAt+1, []byte(lines))
+}
+
+func encodeYAMLDocument(root *yaml.Node) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err := encoder.Encode(root)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// findMetadataVariablesNode returns the sequence node of
+// spec.interfaces.variables
+func findMetadataVariablesNode(root *yaml.Node) *yaml.Node {
+	return findMetadataInterfaceNode(root, "variables")
+}
+
+// findMetadataInterfaceNode returns the sequence node of a list under
+// spec.interfaces, such as variables or outputs
+func findMetadataInterfaceNode(root *yaml.Node, interfaceKey string) *yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+
+	node := root.Content[0]
+	for _, key := range []string{"spec", "interfaces", interfaceKey} {
+		node = getMappingValue(node, key)
+		if node == nil {
+			return nil
+		}
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node
+}
+
+// findMetadataVariableNode returns the mapping node of the variable named
+// varName under spec.interfaces.variables
+func findMetadataVariableNode(root *yaml.Node, varName string) *yaml.Node {
+	return findMetadataEntryNode(root, "variables", varName)
+}
+
+// findMetadataEntryNode returns the mapping node of the entry with the given
+// name in a list under spec.interfaces
+func findMetadataEntryNode(root *yaml.Node, interfaceKey string, entryName string) *yaml.Node {
+	node := findMetadataInterfaceNode(root, interfaceKey)
+	if node == nil {
+		return nil
+	}
+
+	for _, entry := range node.Content {
+		name := getMappingValue(entry, "name")
+		if name != nil && name.Value == entryName {
+			return entry
+		}
+	}
+	return nil
+}
+
+// findYAMLPathNode returns the node at a path of mapping keys within a
+// document, or nil if the path does not exist
+func findYAMLPathNode(root *yaml.Node, keyPath []string) *yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+	node := root.Content[0]
+	for _, key := range keyPath {
+		node = getMappingValue(node, key)
+	}
+	return node
+}
+
+// setYAMLScalar sets a scalar node of the document parsed from data to a
+// string value. Where possible, only the bytes of the value are replaced, so
+// that comments and formatting in the rest of the file are untouched.
+// Otherwise, the value is set on the parsed document, which is re-encoded.
+func setYAMLScalar(data []byte, root *yaml.Node, node *yaml.Node, value string) ([]byte, error) {
+	replaced := *node
+	replaced.Value = value
+	replaced.Tag = "!!str"
+	if start, end, ok := getInlineSpan(data, node); ok {
+		// Comments are outside the replaced bytes
+		inline := replaced
+		inline.HeadComment, inline.LineComment, inline.FootComment = "", "", ""
+		inlineValue, err := marshalInlineYAML(&inline)
+		if err == nil {
+			return spliceBytes(data, start, end, []byte(inlineValue)), nil
+		}
+	}
+
+	// Fall back to editing the parsed document
+	*node = replaced
+	return encodeYAMLDocument(root)
+}
+
+// addYAMLPath adds a string value at a path of mapping keys of the document
+// parsed from data, along with the mappings of the path that are missing. Where
+// possible, the lines of the new keys are inserted after the last value of the
+// deepest existing mapping, or before its first key if the last value spans
+// multiple lines, so that the rest of the file is untouched. Otherwise, the
+// keys are added to the parsed document, which is re-encoded.
+func addYAMLPath(data []byte, root *yaml.Node, keyPath []string, value string) ([]byte, error) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+
+	parent := root.Content[0]
+	depth := 0
+	for ; depth < len(keyPath)-1; depth++ {
+		node := getMappingValue(parent, keyPath[depth])
+		if node == nil {
+			break
+		}
+		parent = node
+	}
+	if parent.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a mapping", strings.Join(keyPath[:depth], "."))
+	}
+
+	// The missing keys, each as a mapping holding the next one
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	for i := len(keyPath) - 1; i > depth; i-- {
+		valueNode = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: keyPath[i]}, valueNode}}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keyPath[depth]}
+
+	inlineValue, err := marshalInlineYAML(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+	if err == nil && len(parent.Content) > 0 && parent.Style&yaml.FlowStyle == 0 {
+		indent := strings.Repeat(" ", parent.Content[0].Column-1)
+		var lines strings.Builder
+		for i, key := range keyPath[depth:] {
+			lineIndent := indent + strings.Repeat("  ", i)
+			if depth+i == len(keyPath)-1 {
+				lines.WriteString(fmt.Sprintf("%s%s: %s\n", lineIndent, key, inlineValue))
+			} else {
+				lines.WriteString(fmt.Sprintf("%s%s:\n", lineIndent, key))
+			}
+		}
+
+		if end, ok := getMappingEnd(data, parent); ok {
+			return insertLineAfter(data, end, lines.String()), nil
+		}
+		firstStart := getOffset(data, parent.Content[0].Line, parent.Content[0].Column)
+		if firstStart >= 0 {
+			lineStart := bytes.LastIndexByte(data[:firstStart], '\n') + 1
+			if strings.TrimSpace(string(data[lineStart:firstStart])) == "" {
+				return spliceBytes(data, lineStart, lineStart, []byte(lines.String())), nil
+			}
+		}
+	}
+
+	// Fall back to editing the parsed document
+	parent.Content = append(parent.Content, keyNode, valueNode)
+	return encodeYAMLDocument(root)
+}
+
+// getMappingValue returns the value node of a key in a mapping node
+func getMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// encodeYAMLValue encodes a value as a node. Lists and maps use flow style so
+// that they fit on a single line.
+func encodeYAMLValue(value interface{}) (*yaml.Node, error) {
+	var node yaml.Node
+	err := node.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.SequenceNode || node.Kind == yaml.MappingNode {
+		node.Style = yaml.FlowStyle
+	}
+	return &node, nil
+}
+
+func marshalInlineYAML(node *yaml.Node) (string, error) {
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	inline := strings.TrimSuffix(string(b), "\n")
+	if strings.Contains(inline, "\n") {
+		return "", fmt.Errorf("value: %s does not fit on a single line", inline)
+	}
+	return inline, nil
+}
+
+// getInlineSpan returns the byte offsets of a scalar or flow style list or map
+// written on a single line. ok is false for other nodes, whose extent cannot be
+// determined from the node, and for empty values, which have no bytes to
+// replace.
+func getInlineSpan(data []byte, node *yaml.Node) (start int, end int, ok bool) {
+	if strings.Contains(node.Value, "\n") {
+		return 0, 0, false
+	}
+
+	start = getOffset(data, node.Line, node.Column)
+	if start < 0 || start >= len(data) {
+		return 0, 0, false
+	}
+
+	switch {
+	case node.Kind != yaml.ScalarNode:
+		if node.Style&yaml.FlowStyle == 0 {
+			return 0, 0, false
+		}
+		end = getFlowEnd(data, start)
+	case node.Style == 0 && node.Value == "":
+		return 0, 0, false
+	case node.Style == 0:
+		end = start + len(node.Value)
+		if end > len(data) || string(data[start:end]) != node.Value {
+			return 0, 0, false
+		}
+	case node.Style == yaml.DoubleQuotedStyle:
+		end = getQuotedEnd(data, start, '"')
+	case node.Style == yaml.SingleQuotedStyle:
+		end = getQuotedEnd(data, start, '\'')
+	default:
+		return 0, 0, false
+	}
+	if end < 0 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// getOffset returns the byte offset of a 1-based line and column, or -1 if
+// the position is not within data
+func getOffset(data []byte, line int, column int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := bytes.IndexByte(data[offset:], '\n')
+		if next == -1 {
+			return -1
+		}
+		offset += next + 1
+	}
+	offset += column - 1
+	if column < 1 || offset > len(data) || bytes.IndexByte(data[offset-column+1:offset], '\n') != -1 {
+		return -1
+	}
+	return offset
+}
+
+// getQuotedEnd returns the offset after the closing quote of a quoted scalar
+// starting at start, or -1 if it does not close on the same line
+func getQuotedEnd(data []byte, start int, quote byte) int {
+	if data[start] != quote {
+		return -1
+	}
+	for i := start + 1; i < len(data) && data[i] != '\n'; i++ {
+		switch {
+		case quote == '"' && data[i] == '\\':
+			i++
+		case data[i] == quote && quote == '\'' && i+1 < len(data) && data[i+1] == '\'':
+			i++
+		case data[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// getFlowEnd returns the offset after the closing bracket of a flow style list
+// or map starting at start, or -1 if it does not close on the same line
+func getFlowEnd(data []byte, start int) int {
+	depth := 0
+	for i := start; i < len(data) && data[i] != '\n'; i++ {
+		switch data[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			end := getQuotedEnd(data, i, data[i])
+			if end < 0 {
+				return -1
+			}
+			i = end - 1
+		}
+	}
+	return -1
+}
+
+func spliceBytes(data []byte, start int, end int, replacement []byte) []byte {
+	spliced := append([]byte{}, data[:start]...)
+	spliced = append(spliced, replacement...)
+	return append(spliced, data[end:]...)
+}